@@ -0,0 +1,300 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"crypto/cipher"
+	"encoding/asn1"
+	"errors"
+	"io"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/mgm"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+var (
+	kdfTreeLabel = []byte("kdf tree")
+
+	OIDMagmaMGM      = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 5, 1, 3}
+	OIDKuznyechikMGM = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 5, 2, 3}
+)
+
+// AEAD used for AuthEnvelopedData content encryption.
+type AuthCipher int
+
+const (
+	KuznyechikMGM AuthCipher = iota
+	MagmaMGM
+)
+
+func (c AuthCipher) String() string {
+	switch c {
+	case KuznyechikMGM:
+		return "Kuznyechik MGM"
+	case MagmaMGM:
+		return "Magma MGM"
+	}
+	return "unknown"
+}
+
+func (c AuthCipher) oid() asn1.ObjectIdentifier {
+	if c == MagmaMGM {
+		return OIDMagmaMGM
+	}
+	return OIDKuznyechikMGM
+}
+
+func (c AuthCipher) keyWrap() *keyWrap {
+	if c == MagmaMGM {
+		return &magmaKeyWrap
+	}
+	return &kuznyechikKeyWrap
+}
+
+// MGM with full blocksize ICV. Its key is derived from CEK with
+// KDF_TREE_GOSTR3411_2012_256 over the KDF seed part of ukm.
+func (c AuthCipher) newAEAD(cek, ukm []byte) (cipher.AEAD, error) {
+	key := gost34112012256.KDFTree(cek, kdfTreeLabel, ukm[len(ukm)-kdfSeedSize:], 1, 8*cekSize)
+	defer wipe.Bytes(key)
+	var block cipher.Block
+	if c == MagmaMGM {
+		block = gost341264.NewCipher(key)
+	} else {
+		block = gost3412128.NewCipher(key)
+	}
+	return mgm.NewMGM(block, block.BlockSize())
+}
+
+// Size of ukm: MGM's nonce (blocksize) followed by 8 bytes of KDF seed.
+func (c AuthCipher) ukmSize() int {
+	if c == MagmaMGM {
+		return gost341264.BlockSize + kdfSeedSize
+	}
+	return gost3412128.BlockSize + kdfSeedSize
+}
+
+type authEnvelopedData struct {
+	Version                  int
+	OriginatorInfo           asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos           []asn1.RawValue `asn1:"set"`
+	AuthEncryptedContentInfo encryptedContentInfo
+	AuthAttrs                asn1.RawValue `asn1:"optional,tag:1"`
+	MAC                      []byte
+	UnauthAttrs              asn1.RawValue `asn1:"optional,tag:2"`
+}
+
+// Additional authenticated data: DER encoding of authenticated
+// attributes with the SET OF tag (RFC 5083).
+func authAttrsAD(attrs []byte) ([]byte, error) {
+	if attrs == nil {
+		return nil, nil
+	}
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      attrs,
+	})
+}
+
+type AuthEnvelopeOptions struct {
+	Cipher AuthCipher
+	// Use KeyAgreeRecipientInfo instead of KeyTransRecipientInfo
+	KeyAgreement bool
+	// Authenticated, but not encrypted attributes
+	AuthAttributes []Attribute
+}
+
+// CMS AuthEnvelopedData.
+type AuthEnvelopedData struct {
+	// Type of the encrypted content
+	ContentType    asn1.ObjectIdentifier
+	Cipher         AuthCipher
+	AuthAttributes []Attribute
+
+	ukm              []byte
+	recipientInfos   []asn1.RawValue
+	encryptedContent []byte
+	rawAuthAttrs     []byte
+	mac              []byte
+}
+
+// Encrypt id-data content with MGM AEAD to the recipients' certificates
+// and return DER-encoded ContentInfo with AuthEnvelopedData. Random
+// content encryption key is transported or agreed with ephemeral keys and
+// wrapped with KExp15. Authenticated attributes are authenticated with
+// the content. If opts is nil, then Kuznyechik MGM with key agreement is
+// used.
+func AuthEncrypt(
+	rand io.Reader,
+	content []byte,
+	recipients []*x509gost.Certificate,
+	opts *AuthEnvelopeOptions,
+) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if opts == nil {
+		opts = &AuthEnvelopeOptions{Cipher: KuznyechikMGM, KeyAgreement: true}
+	}
+	c := opts.Cipher
+	if c != KuznyechikMGM && c != MagmaMGM {
+		return nil, errors.New("unknown cipher")
+	}
+	var attrs []byte
+	if len(opts.AuthAttributes) > 0 {
+		var err error
+		if attrs, err = marshalAttributes(opts.AuthAttributes); err != nil {
+			return nil, err
+		}
+	}
+	ad, err := authAttrsAD(attrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 && len(ad) == 0 {
+		return nil, errors.New("empty content without authenticated attributes")
+	}
+	ukm := make([]byte, c.ukmSize())
+	if _, err = io.ReadFull(rand, ukm); err != nil {
+		return nil, err
+	}
+	ukm[0] &= 0x7F // MGM's nonce must not have higher bit set
+	cek := make([]byte, cekSize)
+	if _, err = io.ReadFull(rand, cek); err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(cek)
+	ris, err := c.keyWrap().recipientInfos(rand, cek, recipients, opts.KeyAgreement)
+	if err != nil {
+		return nil, err
+	}
+	ai, err := algorithmIdentifier(c.oid(), gost3412Params{ukm})
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.newAEAD(cek, ukm)
+	if err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nil, ukm[:aead.NonceSize()], content, ad)
+	aead.(*mgm.MGM).Destroy()
+	aed := authEnvelopedData{
+		RecipientInfos: ris,
+		AuthEncryptedContentInfo: encryptedContentInfo{
+			ContentType:                OIDData,
+			ContentEncryptionAlgorithm: ai,
+			EncryptedContent: asn1.RawValue{
+				Class: asn1.ClassContextSpecific,
+				Tag:   0,
+				Bytes: sealed[:len(content)],
+			},
+		},
+		MAC: sealed[len(content):],
+	}
+	if attrs != nil {
+		aed.AuthAttrs = tagged(1, attrs)
+	}
+	return marshalContentInfo(OIDAuthEnvelopedData, aed)
+}
+
+// Parse BER/DER-encoded ContentInfo with AuthEnvelopedData.
+func ParseAuthEnvelopedData(data []byte) (*AuthEnvelopedData, error) {
+	var in authEnvelopedData
+	if err := parseContentInfo(data, OIDAuthEnvelopedData, &in); err != nil {
+		return nil, err
+	}
+	eci := &in.AuthEncryptedContentInfo
+	var c AuthCipher
+	switch {
+	case eci.ContentEncryptionAlgorithm.Algorithm.Equal(OIDKuznyechikMGM):
+		c = KuznyechikMGM
+	case eci.ContentEncryptionAlgorithm.Algorithm.Equal(OIDMagmaMGM):
+		c = MagmaMGM
+	default:
+		return nil, errors.New("unsupported content encryption algorithm")
+	}
+	var params gost3412Params
+	if err := unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if len(params.UKM) != c.ukmSize() || params.UKM[0]&0x80 > 0 {
+		return nil, errors.New("invalid ukm")
+	}
+	if len(eci.EncryptedContent.FullBytes) == 0 {
+		return nil, errors.New("detached encrypted content is not supported")
+	}
+	ciphertext, err := octets(eci.EncryptedContent)
+	if err != nil {
+		return nil, err
+	}
+	aed := AuthEnvelopedData{
+		ContentType:      eci.ContentType,
+		Cipher:           c,
+		ukm:              params.UKM,
+		recipientInfos:   in.RecipientInfos,
+		encryptedContent: ciphertext,
+		mac:              in.MAC,
+	}
+	if len(in.AuthAttrs.FullBytes) > 0 {
+		aed.rawAuthAttrs = in.AuthAttrs.Bytes
+		if aed.AuthAttributes, err = parseAttributes(aed.rawAuthAttrs); err != nil {
+			return nil, err
+		}
+	}
+	return &aed, nil
+}
+
+// Decrypt the content with the recipient's private key, verifying the
+// content and authenticated attributes integrity.
+func (aed *AuthEnvelopedData) Decrypt(cert *x509gost.Certificate, prv *gost3410.PrivateKey) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	cek, err := aed.Cipher.keyWrap().decryptCEK(aed.recipientInfos, cert, prv)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(cek)
+	if len(cek) != cekSize {
+		return nil, errors.New("invalid content encryption key size")
+	}
+	ad, err := authAttrsAD(aed.rawAuthAttrs)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := aed.Cipher.newAEAD(cek, aed.ukm)
+	if err != nil {
+		return nil, err
+	}
+	defer aead.(*mgm.MGM).Destroy()
+	if len(aed.mac) != aead.Overhead() {
+		return nil, errors.New("invalid MAC size")
+	}
+	if len(aed.encryptedContent) == 0 && len(ad) == 0 {
+		return nil, errors.New("empty content without authenticated attributes")
+	}
+	sealed := make([]byte, 0, len(aed.encryptedContent)+len(aed.mac))
+	sealed = append(append(sealed, aed.encryptedContent...), aed.mac...)
+	return aead.Open(nil, aed.ukm[:aead.NonceSize()], sealed, ad)
+}
@@ -0,0 +1,179 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/mgm"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+func TestAuthEnvelopedData(t *testing.T) {
+	ca := newCA(t)
+	rs := ca.recipients(t)
+	certs := make([]*x509gost.Certificate, 0, len(rs))
+	for _, r := range rs {
+		certs = append(certs, r.cert)
+	}
+	content := make([]byte, 1000)
+	rand.Read(content)
+	attr, err := NewAttribute(oidAttributeSigningTime, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []AuthCipher{KuznyechikMGM, MagmaMGM} {
+		for _, keyAgreement := range []bool{false, true} {
+			der, err := AuthEncrypt(rand.Reader, content, certs, &AuthEnvelopeOptions{
+				Cipher:         c,
+				KeyAgreement:   keyAgreement,
+				AuthAttributes: []Attribute{attr},
+			})
+			if err != nil {
+				t.Fatal(c, keyAgreement, err)
+			}
+			aed, err := ParseAuthEnvelopedData(der)
+			if err != nil {
+				t.Fatal(c, keyAgreement, err)
+			}
+			if aed.Cipher != c || !aed.ContentType.Equal(OIDData) {
+				t.FailNow()
+			}
+			if len(aed.AuthAttributes) != 1 || !aed.AuthAttributes[0].Type.Equal(oidAttributeSigningTime) {
+				t.FailNow()
+			}
+			for _, r := range rs {
+				got, err := aed.Decrypt(r.cert, r.prv)
+				if err != nil {
+					t.Fatal(c, keyAgreement, r.cert.Subject.CommonName, err)
+				}
+				if bytes.Compare(got, content) != 0 {
+					t.FailNow()
+				}
+			}
+		}
+	}
+}
+
+func TestAuthEnvelopedDataDefault(t *testing.T) {
+	ca := newCA(t)
+	r := ca.recipients(t)[2]
+	content := []byte("платёжное поручение")
+	der, err := AuthEncrypt(rand.Reader, content, []*x509gost.Certificate{r.cert}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aed, err := ParseAuthEnvelopedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aed.Cipher != KuznyechikMGM || aed.AuthAttributes != nil {
+		t.FailNow()
+	}
+	got, err := aed.Decrypt(r.cert, r.prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, content) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestAuthEnvelopedDataTampered(t *testing.T) {
+	ca := newCA(t)
+	r := ca.recipients(t)[0]
+	attr, err := NewAttribute(oidAttributeSigningTime, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := AuthEncrypt(rand.Reader, []byte("content"), []*x509gost.Certificate{r.cert}, &AuthEnvelopeOptions{
+		AuthAttributes: []Attribute{attr},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aed, err := ParseAuthEnvelopedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aed.encryptedContent[0] ^= 0x01
+	if _, err = aed.Decrypt(r.cert, r.prv); err == nil {
+		t.FailNow()
+	}
+	aed.encryptedContent[0] ^= 0x01
+	aed.rawAuthAttrs[len(aed.rawAuthAttrs)-1] ^= 0x01
+	if _, err = aed.Decrypt(r.cert, r.prv); err == nil {
+		t.FailNow()
+	}
+	aed.rawAuthAttrs[len(aed.rawAuthAttrs)-1] ^= 0x01
+	if _, err = aed.Decrypt(r.cert, r.prv); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthEnvelopedDataEmpty(t *testing.T) {
+	ca := newCA(t)
+	r := ca.recipients(t)[0]
+	if _, err := AuthEncrypt(rand.Reader, nil, []*x509gost.Certificate{r.cert}, nil); err == nil {
+		t.FailNow()
+	}
+}
+
+// MGM key is derived from CEK and ukm's KDF seed, not taken as is.
+func TestAuthKeyDerivation(t *testing.T) {
+	cek := make([]byte, cekSize)
+	rand.Read(cek)
+	for _, c := range []AuthCipher{KuznyechikMGM, MagmaMGM} {
+		ukm := make([]byte, c.ukmSize())
+		rand.Read(ukm)
+		ukm[0] &= 0x7F
+		aead, err := c.newAEAD(cek, ukm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := ukm[:aead.NonceSize()]
+		sealed := aead.Seal(nil, nonce, []byte("content"), nil)
+		key := gost34112012256.KDFTree(cek, []byte("kdf tree"), ukm[len(ukm)-8:], 1, 256)
+		var block cipher.Block
+		if c == MagmaMGM {
+			block = gost341264.NewCipher(key)
+		} else {
+			block = gost3412128.NewCipher(key)
+		}
+		expected, err := mgm.NewMGM(block, block.BlockSize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(sealed, expected.Seal(nil, nonce, []byte("content"), nil)) != 0 {
+			t.Fatal(c)
+		}
+		ukm[len(ukm)-1] ^= 0x01
+		other, err := c.newAEAD(cek, ukm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(sealed, other.Seal(nil, nonce, []byte("content"), nil)) == 0 {
+			t.Fatal(c, "seed ignored")
+		}
+	}
+}
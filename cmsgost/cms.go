@@ -0,0 +1,155 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Cryptographic Message Syntax (RFC 5652) with GOST algorithms (RFC 4490,
+// RFC 9337).
+package cmsgost
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"sort"
+
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+var (
+	OIDData              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OIDEnvelopedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	OIDAuthEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 23}
+
+	oidAttributeContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidAttributeCountersignature     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+	oidGost341194 = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 9}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// CMS attribute. Values are DER-encoded.
+type Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// Create attribute with single value.
+func NewAttribute(oid asn1.ObjectIdentifier, value interface{}) (Attribute, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return Attribute{}, err
+	}
+	return Attribute{oid, []asn1.RawValue{{FullBytes: der}}}, nil
+}
+
+func findAttribute(attrs []Attribute, oid asn1.ObjectIdentifier) *Attribute {
+	for i := range attrs {
+		if attrs[i].Type.Equal(oid) {
+			return &attrs[i]
+		}
+	}
+	return nil
+}
+
+// Unmarshal the only value of the attribute.
+func (attr *Attribute) unmarshal(v interface{}) error {
+	if len(attr.Values) != 1 {
+		return errors.New("attribute must have single value")
+	}
+	return unmarshal(attr.Values[0].FullBytes, v)
+}
+
+// DER-encoded contents of SET OF attributes: encodings are sorted.
+func marshalAttributes(attrs []Attribute) ([]byte, error) {
+	encoded := make([][]byte, 0, len(attrs))
+	for _, attr := range attrs {
+		der, err := asn1.Marshal(attr)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, der)
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+	return bytes.Join(encoded, nil), nil
+}
+
+func parseAttributes(der []byte) ([]Attribute, error) {
+	var attrs []Attribute
+	for len(der) > 0 {
+		var attr Attribute
+		rest, err := asn1.Unmarshal(der, &attr)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+		der = rest
+	}
+	return attrs, nil
+}
+
+func unmarshal(der []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+// Context-specific constructed element with given contents.
+func tagged(tag int, der []byte) asn1.RawValue {
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      der,
+	}
+}
+
+func marshalContentInfo(contentType asn1.ObjectIdentifier, content interface{}) ([]byte, error) {
+	der, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{contentType, tagged(0, der)})
+}
+
+// Parse BER/DER-encoded ContentInfo of expected type into content.
+func parseContentInfo(data []byte, contentType asn1.ObjectIdentifier, content interface{}) error {
+	der, err := gostasn1.BER2DER(data)
+	if err != nil {
+		return err
+	}
+	var ci contentInfo
+	if err = unmarshal(der, &ci); err != nil {
+		return err
+	}
+	if !ci.ContentType.Equal(contentType) {
+		return errors.New("unexpected content type")
+	}
+	return unmarshal(ci.Content.Bytes, content)
+}
@@ -0,0 +1,343 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"crypto/cipher"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/pkcs5gost"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+const cekSize = 32
+
+type envelopedData struct {
+	Version              int
+	OriginatorInfo       asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+	UnprotectedAttrs     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type gost28147Params struct {
+	IV                 []byte
+	EncryptionParamSet asn1.ObjectIdentifier
+}
+
+// GostR3412-15-Encryption-Parameters: ukm is IV (half of the blocksize)
+// followed by 8 bytes of KDF seed.
+type gost3412Params struct {
+	UKM []byte
+}
+
+const kdfSeedSize = 8
+
+func newKuznyechik(key []byte) cipher.Block {
+	return gost3412128.NewCipher(key)
+}
+
+func newMagma(key []byte) cipher.Block {
+	return gost341264.NewCipher(key)
+}
+
+var (
+	magmaKeyWrap = keyWrap{
+		newCipher: newMagma,
+		blockSize: gost341264.BlockSize,
+		kexp15OID: oidMagmaKExp15,
+	}
+	kuznyechikKeyWrap = keyWrap{
+		newCipher: newKuznyechik,
+		blockSize: gost3412128.BlockSize,
+		kexp15OID: oidKuznyechikKExp15,
+	}
+)
+
+// Content encryption algorithm with its parameters.
+type contentEncryption struct {
+	cipher pkcs5gost.Cipher
+	// 28147-89 CFB's IV or 34.12-2015 ukm
+	iv   []byte
+	sbox *gost28147.Sbox
+}
+
+func newContentEncryption(rand io.Reader, c pkcs5gost.Cipher, sbox *gost28147.Sbox) (*contentEncryption, error) {
+	ce := contentEncryption{cipher: c}
+	switch c {
+	case pkcs5gost.Gost28147CFB:
+		ce.iv = make([]byte, gost28147.BlockSize)
+		ce.sbox = sbox
+		if sbox == nil {
+			ce.sbox = &gost28147.SboxIdtc26gost28147paramZ
+		}
+	case pkcs5gost.MagmaCTRACPKM, pkcs5gost.KuznyechikCTRACPKM:
+		ce.iv = make([]byte, c.IVSize()+kdfSeedSize)
+	default:
+		return nil, errors.New("unknown cipher")
+	}
+	if _, err := io.ReadFull(rand, ce.iv); err != nil {
+		return nil, err
+	}
+	return &ce, nil
+}
+
+func parseContentEncryption(ai pkix.AlgorithmIdentifier) (*contentEncryption, error) {
+	var ce contentEncryption
+	switch {
+	case ai.Algorithm.Equal(pkcs5gost.OIDGost28147):
+		var params gost28147Params
+		if err := unmarshal(ai.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		if len(params.IV) != gost28147.BlockSize {
+			return nil, errors.New("invalid IV size")
+		}
+		sbox, err := gostasn1.SboxByOID(params.EncryptionParamSet)
+		if err != nil {
+			return nil, err
+		}
+		ce = contentEncryption{pkcs5gost.Gost28147CFB, params.IV, sbox}
+	case ai.Algorithm.Equal(pkcs5gost.OIDMagmaCTRACPKM):
+		ce.cipher = pkcs5gost.MagmaCTRACPKM
+	case ai.Algorithm.Equal(pkcs5gost.OIDKuznyechikCTRACPKM):
+		ce.cipher = pkcs5gost.KuznyechikCTRACPKM
+	default:
+		return nil, errors.New("unsupported content encryption algorithm")
+	}
+	if ce.cipher != pkcs5gost.Gost28147CFB {
+		var params gost3412Params
+		if err := unmarshal(ai.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		if len(params.UKM) != ce.cipher.IVSize()+kdfSeedSize {
+			return nil, errors.New("invalid ukm size")
+		}
+		ce.iv = params.UKM
+	}
+	return &ce, nil
+}
+
+func (ce *contentEncryption) algorithmIdentifier() (pkix.AlgorithmIdentifier, error) {
+	switch ce.cipher {
+	case pkcs5gost.Gost28147CFB:
+		oid, err := gostasn1.SboxOID(ce.sbox)
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, err
+		}
+		return algorithmIdentifier(pkcs5gost.OIDGost28147, gost28147Params{ce.iv, oid})
+	case pkcs5gost.MagmaCTRACPKM:
+		return algorithmIdentifier(pkcs5gost.OIDMagmaCTRACPKM, gost3412Params{ce.iv})
+	}
+	return algorithmIdentifier(pkcs5gost.OIDKuznyechikCTRACPKM, gost3412Params{ce.iv})
+}
+
+func (ce *contentEncryption) keyWrap() *keyWrap {
+	switch ce.cipher {
+	case pkcs5gost.Gost28147CFB:
+		return &keyWrap{sbox: ce.sbox}
+	case pkcs5gost.MagmaCTRACPKM:
+		return &magmaKeyWrap
+	}
+	return &kuznyechikKeyWrap
+}
+
+// Encrypt or decrypt the content. GOST 28147-89 CFB uses CryptoPro key
+// meshing with all parameter sets except the test one.
+func (ce *contentEncryption) xorKeyStream(cek, dst, src []byte, encrypt bool) {
+	switch ce.cipher {
+	case pkcs5gost.Gost28147CFB:
+		c := gost28147.NewCipher(cek, ce.sbox)
+		defer c.Destroy()
+		if *ce.sbox == gost28147.SboxIdGost2814789TestParamSet {
+			if encrypt {
+				c.NewCFBEncrypter(ce.iv).XORKeyStream(dst, src)
+			} else {
+				c.NewCFBDecrypter(ce.iv).XORKeyStream(dst, src)
+			}
+			return
+		}
+		var s *gost28147.CFBMeshing
+		if encrypt {
+			s = c.NewCFBMeshingEncrypter(ce.iv)
+		} else {
+			s = c.NewCFBMeshingDecrypter(ce.iv)
+		}
+		s.XORKeyStream(dst, src)
+		s.Destroy()
+	case pkcs5gost.MagmaCTRACPKM:
+		s := gost3413.NewCTRACPKM(newMagma, cek, ce.iv[:ce.cipher.IVSize()], pkcs5gost.MagmaSectionSize)
+		s.XORKeyStream(dst, src)
+		s.Destroy()
+	case pkcs5gost.KuznyechikCTRACPKM:
+		s := gost3413.NewCTRACPKM(newKuznyechik, cek, ce.iv[:ce.cipher.IVSize()], pkcs5gost.KuznyechikSectionSize)
+		s.XORKeyStream(dst, src)
+		s.Destroy()
+	}
+}
+
+// Contents of primitive or constructed [n] IMPLICIT OCTET STRING.
+func octets(v asn1.RawValue) ([]byte, error) {
+	if !v.IsCompound {
+		return v.Bytes, nil
+	}
+	var out []byte
+	for rest := v.Bytes; len(rest) > 0; {
+		var chunk []byte
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &chunk); err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+type EnvelopeOptions struct {
+	Cipher pkcs5gost.Cipher
+	// S-box for GOST 28147-89, id-tc26-gost-28147-param-Z by default
+	Sbox *gost28147.Sbox
+	// Use KeyAgreeRecipientInfo instead of KeyTransRecipientInfo
+	KeyAgreement bool
+}
+
+// CMS EnvelopedData.
+type EnvelopedData struct {
+	// Type of the encrypted content
+	ContentType asn1.ObjectIdentifier
+	Cipher      pkcs5gost.Cipher
+
+	ce               *contentEncryption
+	recipientInfos   []asn1.RawValue
+	encryptedContent []byte
+}
+
+// Encrypt id-data content to the recipients' certificates and return
+// DER-encoded ContentInfo with EnvelopedData. Random content encryption
+// key is transported or agreed with ephemeral keys: with CryptoPro key
+// wrap (RFC 4490) for GOST 28147-89 and KExp15 for 34.12-2015 ciphers.
+// If opts is nil, then Kuznyechik CTR-ACPKM with key transport is used.
+func Encrypt(
+	rand io.Reader,
+	content []byte,
+	recipients []*x509gost.Certificate,
+	opts *EnvelopeOptions,
+) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if opts == nil {
+		opts = &EnvelopeOptions{Cipher: pkcs5gost.KuznyechikCTRACPKM}
+	}
+	ce, err := newContentEncryption(rand, opts.Cipher, opts.Sbox)
+	if err != nil {
+		return nil, err
+	}
+	cek := make([]byte, cekSize)
+	if _, err = io.ReadFull(rand, cek); err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(cek)
+	ris, err := ce.keyWrap().recipientInfos(rand, cek, recipients, opts.KeyAgreement)
+	if err != nil {
+		return nil, err
+	}
+	ai, err := ce.algorithmIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(content))
+	ce.xorKeyStream(cek, ciphertext, content, true)
+	ed := envelopedData{
+		RecipientInfos: ris,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                OIDData,
+			ContentEncryptionAlgorithm: ai,
+			EncryptedContent: asn1.RawValue{
+				Class: asn1.ClassContextSpecific,
+				Tag:   0,
+				Bytes: ciphertext,
+			},
+		},
+	}
+	if opts.KeyAgreement {
+		ed.Version = 2
+	}
+	return marshalContentInfo(OIDEnvelopedData, ed)
+}
+
+// Parse BER/DER-encoded ContentInfo with EnvelopedData.
+func ParseEnvelopedData(data []byte) (*EnvelopedData, error) {
+	var in envelopedData
+	if err := parseContentInfo(data, OIDEnvelopedData, &in); err != nil {
+		return nil, err
+	}
+	eci := &in.EncryptedContentInfo
+	ce, err := parseContentEncryption(eci.ContentEncryptionAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if len(eci.EncryptedContent.FullBytes) == 0 {
+		return nil, errors.New("detached encrypted content is not supported")
+	}
+	ciphertext, err := octets(eci.EncryptedContent)
+	if err != nil {
+		return nil, err
+	}
+	return &EnvelopedData{
+		ContentType:      eci.ContentType,
+		Cipher:           ce.cipher,
+		ce:               ce,
+		recipientInfos:   in.RecipientInfos,
+		encryptedContent: ciphertext,
+	}, nil
+}
+
+// Decrypt the content with the recipient's private key.
+func (ed *EnvelopedData) Decrypt(cert *x509gost.Certificate, prv *gost3410.PrivateKey) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	cek, err := ed.ce.keyWrap().decryptCEK(ed.recipientInfos, cert, prv)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(cek)
+	if len(cek) != cekSize {
+		return nil, errors.New("invalid content encryption key size")
+	}
+	plaintext := make([]byte, len(ed.encryptedContent))
+	ed.ce.xorKeyStream(cek, plaintext, ed.encryptedContent, false)
+	return plaintext, nil
+}
@@ -0,0 +1,259 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/pkcs5gost"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+type testRecipient struct {
+	cert *x509gost.Certificate
+	prv  *gost3410.PrivateKey
+}
+
+func (ca *testCA) recipients(t *testing.T) []testRecipient {
+	var rs []testRecipient
+	for _, r := range []struct {
+		curve *gost3410.Curve
+		mode  gost3410.Mode
+	}{
+		{gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001},
+		{gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001},
+		{gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012},
+	} {
+		cert, prv := ca.issue(t, r.curve.Name, r.curve, r.mode, x509.KeyUsageKeyEncipherment)
+		rs = append(rs, testRecipient{cert, prv})
+	}
+	return rs
+}
+
+func TestEnvelopedData(t *testing.T) {
+	ca := newCA(t)
+	rs := ca.recipients(t)
+	certs := make([]*x509gost.Certificate, 0, len(rs))
+	for _, r := range rs {
+		certs = append(certs, r.cert)
+	}
+	content := make([]byte, 3000)
+	rand.Read(content)
+	for _, c := range []pkcs5gost.Cipher{
+		pkcs5gost.Gost28147CFB,
+		pkcs5gost.MagmaCTRACPKM,
+		pkcs5gost.KuznyechikCTRACPKM,
+	} {
+		for _, keyAgreement := range []bool{false, true} {
+			der, err := Encrypt(rand.Reader, content, certs, &EnvelopeOptions{
+				Cipher:       c,
+				KeyAgreement: keyAgreement,
+			})
+			if err != nil {
+				t.Fatal(c, keyAgreement, err)
+			}
+			ed, err := ParseEnvelopedData(der)
+			if err != nil {
+				t.Fatal(c, keyAgreement, err)
+			}
+			if ed.Cipher != c || !ed.ContentType.Equal(OIDData) {
+				t.FailNow()
+			}
+			for _, r := range rs {
+				got, err := ed.Decrypt(r.cert, r.prv)
+				if err != nil {
+					t.Fatal(c, keyAgreement, r.cert.Subject.CommonName, err)
+				}
+				if bytes.Compare(got, content) != 0 {
+					t.FailNow()
+				}
+			}
+		}
+	}
+}
+
+func TestEnvelopedDataDefault(t *testing.T) {
+	ca := newCA(t)
+	r := ca.recipients(t)[0]
+	content := []byte("платёжное поручение")
+	der, err := Encrypt(rand.Reader, content, []*x509gost.Certificate{r.cert}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := ParseEnvelopedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ed.Cipher != pkcs5gost.KuznyechikCTRACPKM {
+		t.FailNow()
+	}
+	got, err := ed.Decrypt(r.cert, r.prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, content) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestEnvelopedDataTestParamSet(t *testing.T) {
+	ca := newCA(t)
+	r := ca.recipients(t)[1]
+	content := make([]byte, 2*gost28147.MeshingSize+3)
+	rand.Read(content)
+	der, err := Encrypt(rand.Reader, content, []*x509gost.Certificate{r.cert}, &EnvelopeOptions{
+		Cipher: pkcs5gost.Gost28147CFB,
+		Sbox:   &gost28147.SboxIdGost2814789TestParamSet,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := ParseEnvelopedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ed.Decrypt(r.cert, r.prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, content) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestEnvelopedDataWrongRecipient(t *testing.T) {
+	ca := newCA(t)
+	rs := ca.recipients(t)
+	der, err := Encrypt(rand.Reader, []byte("content"), []*x509gost.Certificate{rs[0].cert}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := ParseEnvelopedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ed.Decrypt(rs[2].cert, rs[2].prv); err == nil {
+		t.FailNow()
+	}
+	// Right certificate, but another private key
+	if _, err = ed.Decrypt(rs[0].cert, genKey(
+		t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001,
+	)); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestEnvelopedDataTampered(t *testing.T) {
+	ca := newCA(t)
+	r := ca.recipients(t)[0]
+	for _, c := range []pkcs5gost.Cipher{
+		pkcs5gost.Gost28147CFB,
+		pkcs5gost.KuznyechikCTRACPKM,
+	} {
+		der, err := Encrypt(rand.Reader, []byte("content"), []*x509gost.Certificate{r.cert}, &EnvelopeOptions{
+			Cipher: c,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ed, err := ParseEnvelopedData(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// RecipientInfo ends with the encrypted key: its UKM or MAC
+		ri := ed.recipientInfos[0].FullBytes
+		ri[len(ri)-1] ^= 0x01
+		if _, err = ed.Decrypt(r.cert, r.prv); err == nil {
+			t.Fatal(c)
+		}
+	}
+}
+
+func TestUnwrapCurveMismatch(t *testing.T) {
+	prv, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost34102012256paramSetA(),
+		gost3410.Mode2001,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdGostR34102001CryptoProAParamSet(),
+		gost3410.Mode2001,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := other.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kuznyechikKeyWrap.unwrap(nil, prv, pub, make([]byte, kegUKMSize), false); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestOriginatorKeyOffCurve(t *testing.T) {
+	prv, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost34102012256paramSetA(),
+		gost3410.Mode2001,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	originator := func() asn1.RawValue {
+		spki, err := gostasn1.MarshalPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spkiDER, err := asn1.Marshal(spki)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var key asn1.RawValue
+		if err = unmarshal(spkiDER, &key); err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := asn1.Marshal(tagged(1, key.Bytes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tagged(0, keyDER)
+	}
+	if _, err = originatorPublicKey(originator(), prv); err != nil {
+		t.Fatal(err)
+	}
+	pub.Y.Add(pub.Y, big.NewInt(1))
+	if _, err = originatorPublicKey(originator(), prv); err == nil {
+		t.Fatal("originator key off the curve accepted")
+	}
+}
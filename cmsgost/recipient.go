@@ -0,0 +1,554 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"crypto/cipher"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+var (
+	oidKeyWrapCryptoPro         = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 13, 1}
+	oidAgreementGost34102012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 6, 1}
+	oidAgreementGost34102012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 6, 2}
+	oidMagmaKExp15              = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 7, 1, 1}
+	oidKuznyechikKExp15         = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 7, 2, 1}
+)
+
+// UKM sizes: VKO's one for CryptoPro key wrap and KEG's one for KExp15.
+const (
+	legacyUKMSize = gost28147.UKMSize
+	kegUKMSize    = 32
+)
+
+type keyTransRecipientInfo struct {
+	Version                int
+	RID                    asn1.RawValue
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type keyAgreeRecipientInfo struct {
+	Version                int
+	Originator             asn1.RawValue
+	UKM                    []byte `asn1:"explicit,tag:1,optional"`
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	RecipientEncryptedKeys []recipientEncryptedKey
+}
+
+type recipientEncryptedKey struct {
+	RID          asn1.RawValue
+	EncryptedKey []byte
+}
+
+// RFC 4490 key transport of GOST 28147-89 keys.
+type gost28147EncryptedKey struct {
+	EncryptedKey []byte
+	MaskKey      []byte `asn1:"optional,tag:0"`
+	MACKey       []byte
+}
+
+type transportParameters struct {
+	EncryptionParamSet asn1.ObjectIdentifier
+	EphemeralPublicKey gostasn1.SubjectPublicKeyInfo `asn1:"optional,tag:0"`
+	UKM                []byte
+}
+
+type legacyKeyTransport struct {
+	SessionEncryptedKey gost28147EncryptedKey
+	TransportParameters transportParameters `asn1:"optional,tag:0"`
+}
+
+type keyWrapParameters struct {
+	EncryptionParamSet asn1.ObjectIdentifier
+	UKM                []byte `asn1:"optional"`
+}
+
+// Key transport with KExp15-exported key, the same as TLS's
+// GostKeyTransport.
+type keyTransport struct {
+	EncryptedKey       []byte
+	EphemeralPublicKey gostasn1.SubjectPublicKeyInfo
+	UKM                []byte `asn1:"optional"`
+}
+
+// How content encryption key is wrapped for recipients: either with
+// CryptoPro key wrap (for GOST 28147-89 content encryption) or with
+// KExp15 using the same cipher as content.
+type keyWrap struct {
+	sbox      *gost28147.Sbox
+	newCipher func(key []byte) cipher.Block
+	blockSize int
+	kexp15OID asn1.ObjectIdentifier
+}
+
+func (w *keyWrap) legacy() bool {
+	return w.sbox != nil
+}
+
+// Recipient's public key info and whether it is GOST R 34.10-2001 key,
+// requiring VKO 34.10-2001.
+func recipientKey(cert *x509gost.Certificate) (*gostasn1.SubjectPublicKeyInfo, bool, error) {
+	var spki gostasn1.SubjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, false, err
+	}
+	return &spki, spki.Algorithm.Algorithm.Equal(gostasn1.OIDGost34102001), nil
+}
+
+func algorithmIdentifier(oid asn1.ObjectIdentifier, params interface{}) (pkix.AlgorithmIdentifier, error) {
+	ai := pkix.AlgorithmIdentifier{Algorithm: oid}
+	der, err := asn1.Marshal(params)
+	if err != nil {
+		return ai, err
+	}
+	ai.Parameters.FullBytes = der
+	return ai, nil
+}
+
+// Ephemeral key on the recipient's curve. Its public key info has the
+// same algorithm identifier as the recipient's one.
+func ephemeralKey(rand io.Reader, cert *x509gost.Certificate, spki *gostasn1.SubjectPublicKeyInfo) (*gost3410.PrivateKey, gostasn1.SubjectPublicKeyInfo, error) {
+	var ephSPKI gostasn1.SubjectPublicKeyInfo
+	prv, err := gost3410.GenPrivateKey(cert.PublicKey.C, cert.PublicKey.Mode, rand)
+	if err != nil {
+		return nil, ephSPKI, err
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, ephSPKI, err
+	}
+	if ephSPKI, err = gostasn1.MarshalPublicKey(pub); err != nil {
+		return nil, ephSPKI, err
+	}
+	ephSPKI.Algorithm = spki.Algorithm
+	return prv, ephSPKI, nil
+}
+
+// VKO key for CryptoPro key wrap.
+func legacyKEK(prv *gost3410.PrivateKey, pub *gost3410.PublicKey, ukm []byte, vko2001 bool) ([]byte, error) {
+	if vko2001 {
+		return prv.KEK2001(pub, gost3410.NewUKM(ukm))
+	}
+	return prv.KEK2012256(pub, gost3410.NewUKM(ukm))
+}
+
+// Wrap the key: encryption key agreed between prv and pub with ukm is
+// used. Result is Gost28147-89-EncryptedKey for the legacy wrap or
+// KExp15's output.
+func (w *keyWrap) wrap(
+	cek []byte,
+	prv *gost3410.PrivateKey,
+	pub *gost3410.PublicKey,
+	ukm []byte,
+	vko2001 bool,
+) ([]byte, error) {
+	if w.legacy() {
+		kek, err := legacyKEK(prv, pub, ukm, vko2001)
+		if err != nil {
+			return nil, err
+		}
+		defer wipe.Bytes(kek)
+		wrapped, err := gost28147.WrapCryptoPro(kek, ukm, cek, w.sbox)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(gost28147EncryptedKey{
+			EncryptedKey: wrapped[legacyUKMSize : legacyUKMSize+gost28147.KeySize],
+			MACKey:       wrapped[legacyUKMSize+gost28147.KeySize:],
+		})
+	}
+	kExp, err := prv.KEG(pub, ukm)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(kExp)
+	return gost3413.KExp15(w.newCipher, cek, kExp[:32], kExp[32:], ukm[24:24+w.blockSize/2])
+}
+
+// Reverse of wrap.
+func (w *keyWrap) unwrap(
+	encrypted []byte,
+	prv *gost3410.PrivateKey,
+	pub *gost3410.PublicKey,
+	ukm []byte,
+	vko2001 bool,
+) ([]byte, error) {
+	if pub.C.Name != prv.C.Name || pub.Mode != prv.Mode {
+		return nil, errors.New("peer key on different curve")
+	}
+	if w.legacy() {
+		var ek gost28147EncryptedKey
+		if err := unmarshal(encrypted, &ek); err != nil {
+			return nil, err
+		}
+		if len(ukm) != legacyUKMSize {
+			return nil, errors.New("invalid UKM size")
+		}
+		kek, err := legacyKEK(prv, pub, ukm, vko2001)
+		if err != nil {
+			return nil, err
+		}
+		defer wipe.Bytes(kek)
+		wrapped := append(append(append([]byte{}, ukm...), ek.EncryptedKey...), ek.MACKey...)
+		return gost28147.UnwrapCryptoPro(kek, wrapped, w.sbox)
+	}
+	if len(ukm) != kegUKMSize {
+		return nil, errors.New("invalid UKM size")
+	}
+	kExp, err := prv.KEG(pub, ukm)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(kExp)
+	return gost3413.KImp15(w.newCipher, encrypted, kExp[:32], kExp[32:], ukm[24:24+w.blockSize/2])
+}
+
+func (w *keyWrap) newUKM(rand io.Reader) ([]byte, error) {
+	ukm := make([]byte, kegUKMSize)
+	if w.legacy() {
+		ukm = ukm[:legacyUKMSize]
+	}
+	_, err := io.ReadFull(rand, ukm)
+	return ukm, err
+}
+
+func issuerAndSerial(cert *x509gost.Certificate) (asn1.RawValue, error) {
+	der, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+		SerialNumber: cert.SerialNumber,
+	})
+	return asn1.RawValue{FullBytes: der}, err
+}
+
+// KeyTransRecipientInfo with ephemeral key.
+func (w *keyWrap) keyTrans(rand io.Reader, cek []byte, cert *x509gost.Certificate) (asn1.RawValue, error) {
+	var ri asn1.RawValue
+	spki, vko2001, err := recipientKey(cert)
+	if err != nil {
+		return ri, err
+	}
+	eph, ephSPKI, err := ephemeralKey(rand, cert, spki)
+	if err != nil {
+		return ri, err
+	}
+	defer eph.Destroy()
+	ukm, err := w.newUKM(rand)
+	if err != nil {
+		return ri, err
+	}
+	encrypted, err := w.wrap(cek, eph, cert.PublicKey, ukm, vko2001)
+	if err != nil {
+		return ri, err
+	}
+	ktri := keyTransRecipientInfo{Version: 0}
+	if ktri.KeyEncryptionAlgorithm, err = algorithmIdentifier(
+		spki.Algorithm.Algorithm, spki.Algorithm.Parameters,
+	); err != nil {
+		return ri, err
+	}
+	if ktri.RID, err = issuerAndSerial(cert); err != nil {
+		return ri, err
+	}
+	if w.legacy() {
+		var ek gost28147EncryptedKey
+		if err = unmarshal(encrypted, &ek); err != nil {
+			return ri, err
+		}
+		sboxOID, err := gostasn1.SboxOID(w.sbox)
+		if err != nil {
+			return ri, err
+		}
+		ktri.EncryptedKey, err = asn1.Marshal(legacyKeyTransport{
+			SessionEncryptedKey: ek,
+			TransportParameters: transportParameters{
+				EncryptionParamSet: sboxOID,
+				EphemeralPublicKey: ephSPKI,
+				UKM:                ukm,
+			},
+		})
+	} else {
+		ktri.EncryptedKey, err = asn1.Marshal(keyTransport{encrypted, ephSPKI, ukm})
+	}
+	if err != nil {
+		return ri, err
+	}
+	der, err := asn1.Marshal(ktri)
+	return asn1.RawValue{FullBytes: der}, err
+}
+
+// KeyAgreeRecipientInfo with ephemeral originator's key.
+func (w *keyWrap) keyAgree(rand io.Reader, cek []byte, cert *x509gost.Certificate) (asn1.RawValue, error) {
+	var ri asn1.RawValue
+	spki, vko2001, err := recipientKey(cert)
+	if err != nil {
+		return ri, err
+	}
+	eph, ephSPKI, err := ephemeralKey(rand, cert, spki)
+	if err != nil {
+		return ri, err
+	}
+	defer eph.Destroy()
+	ukm, err := w.newUKM(rand)
+	if err != nil {
+		return ri, err
+	}
+	encrypted, err := w.wrap(cek, eph, cert.PublicKey, ukm, vko2001)
+	if err != nil {
+		return ri, err
+	}
+	kari := keyAgreeRecipientInfo{
+		Version:                3,
+		UKM:                    ukm,
+		RecipientEncryptedKeys: []recipientEncryptedKey{{EncryptedKey: encrypted}},
+	}
+	if kari.RecipientEncryptedKeys[0].RID, err = issuerAndSerial(cert); err != nil {
+		return ri, err
+	}
+	// OriginatorPublicKey has the same fields as SubjectPublicKeyInfo
+	spkiDER, err := asn1.Marshal(ephSPKI)
+	if err != nil {
+		return ri, err
+	}
+	var originatorKey asn1.RawValue
+	if err = unmarshal(spkiDER, &originatorKey); err != nil {
+		return ri, err
+	}
+	originatorKeyDER, err := asn1.Marshal(tagged(1, originatorKey.Bytes))
+	if err != nil {
+		return ri, err
+	}
+	kari.Originator = tagged(0, originatorKeyDER)
+	if w.legacy() {
+		sboxOID, err := gostasn1.SboxOID(w.sbox)
+		if err != nil {
+			return ri, err
+		}
+		wrapAlgo, err := algorithmIdentifier(oidKeyWrapCryptoPro, keyWrapParameters{
+			EncryptionParamSet: sboxOID,
+		})
+		if err != nil {
+			return ri, err
+		}
+		kari.KeyEncryptionAlgorithm, err = algorithmIdentifier(spki.Algorithm.Algorithm, wrapAlgo)
+	} else {
+		agreement := oidAgreementGost34102012256
+		if cert.PublicKey.Mode == gost3410.Mode2012 {
+			agreement = oidAgreementGost34102012512
+		}
+		kari.KeyEncryptionAlgorithm, err = algorithmIdentifier(
+			agreement, pkix.AlgorithmIdentifier{Algorithm: w.kexp15OID},
+		)
+	}
+	if err != nil {
+		return ri, err
+	}
+	der, err := asn1.Marshal(kari)
+	if err != nil {
+		return ri, err
+	}
+	// [1] IMPLICIT instead of SEQUENCE
+	der[0] = 0xA1
+	return asn1.RawValue{FullBytes: der}, nil
+}
+
+func (w *keyWrap) recipientInfos(
+	rand io.Reader,
+	cek []byte,
+	recipients []*x509gost.Certificate,
+	keyAgreement bool,
+) ([]asn1.RawValue, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	ris := make([]asn1.RawValue, 0, len(recipients))
+	for _, cert := range recipients {
+		var ri asn1.RawValue
+		var err error
+		if keyAgreement {
+			ri, err = w.keyAgree(rand, cek, cert)
+		} else {
+			ri, err = w.keyTrans(rand, cek, cert)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ris = append(ris, ri)
+	}
+	return ris, nil
+}
+
+// Does KeyAgreeRecipientIdentifier refer to the certificate.
+func matchRID(rid asn1.RawValue, cert *x509gost.Certificate) bool {
+	if rid.Class == asn1.ClassContextSpecific && rid.Tag == 0 {
+		// rKeyId RecipientKeyIdentifier
+		var keyID asn1.RawValue
+		if _, err := asn1.Unmarshal(rid.Bytes, &keyID); err != nil {
+			return false
+		}
+		rid = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: keyID.Bytes}
+	}
+	return matchSID(rid, cert)
+}
+
+func (w *keyWrap) decryptKeyTrans(ktri *keyTransRecipientInfo, vko2001 bool, prv *gost3410.PrivateKey) ([]byte, error) {
+	if w.legacy() {
+		var kt legacyKeyTransport
+		if err := unmarshal(ktri.EncryptedKey, &kt); err != nil {
+			return nil, err
+		}
+		params := &kt.TransportParameters
+		eph, err := gostasn1.ParsePublicKey(&params.EphemeralPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		sbox, err := gostasn1.SboxByOID(params.EncryptionParamSet)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := asn1.Marshal(kt.SessionEncryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		w = &keyWrap{sbox: sbox}
+		return w.unwrap(encrypted, prv, eph, params.UKM, vko2001)
+	}
+	var kt keyTransport
+	if err := unmarshal(ktri.EncryptedKey, &kt); err != nil {
+		return nil, err
+	}
+	eph, err := gostasn1.ParsePublicKey(&kt.EphemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return w.unwrap(kt.EncryptedKey, prv, eph, kt.UKM, vko2001)
+}
+
+// Originator's public key, on the recipient's curve if parameters are
+// absent.
+func originatorPublicKey(originator asn1.RawValue, prv *gost3410.PrivateKey) (*gost3410.PublicKey, error) {
+	var key asn1.RawValue
+	if originator.Class != asn1.ClassContextSpecific || originator.Tag != 0 {
+		return nil, errors.New("invalid originator")
+	}
+	if err := unmarshal(originator.Bytes, &key); err != nil {
+		return nil, err
+	}
+	if key.Class != asn1.ClassContextSpecific || key.Tag != 1 {
+		return nil, errors.New("unsupported originator identifier")
+	}
+	var ai pkix.AlgorithmIdentifier
+	rest, err := asn1.Unmarshal(key.Bytes, &ai)
+	if err != nil {
+		return nil, err
+	}
+	spki := gostasn1.SubjectPublicKeyInfo{}
+	if err = unmarshal(rest, &spki.SubjectPublicKey); err != nil {
+		return nil, err
+	}
+	spki.Algorithm.Algorithm = ai.Algorithm
+	if len(ai.Parameters.FullBytes) > 0 {
+		err = unmarshal(ai.Parameters.FullBytes, &spki.Algorithm.Parameters)
+	} else {
+		spki.Algorithm.Parameters.PublicKeyParamSet, err = gostasn1.CurveOID(prv.C)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gostasn1.ParsePublicKey(&spki)
+}
+
+func (w *keyWrap) decryptKeyAgree(
+	kari *keyAgreeRecipientInfo,
+	encrypted []byte,
+	vko2001 bool,
+	prv *gost3410.PrivateKey,
+) ([]byte, error) {
+	pub, err := originatorPublicKey(kari.Originator, prv)
+	if err != nil {
+		return nil, err
+	}
+	var wrapAlgo pkix.AlgorithmIdentifier
+	if err = unmarshal(kari.KeyEncryptionAlgorithm.Parameters.FullBytes, &wrapAlgo); err != nil {
+		return nil, err
+	}
+	if w.legacy() {
+		if !wrapAlgo.Algorithm.Equal(oidKeyWrapCryptoPro) {
+			return nil, errors.New("unsupported key wrap algorithm")
+		}
+		var params keyWrapParameters
+		if err = unmarshal(wrapAlgo.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		sbox, err := gostasn1.SboxByOID(params.EncryptionParamSet)
+		if err != nil {
+			return nil, err
+		}
+		w = &keyWrap{sbox: sbox}
+	} else if !wrapAlgo.Algorithm.Equal(w.kexp15OID) {
+		return nil, errors.New("unsupported key wrap algorithm")
+	}
+	return w.unwrap(encrypted, prv, pub, kari.UKM, vko2001)
+}
+
+// Find recipient info for the certificate and decrypt content
+// encryption key with the private key.
+func (w *keyWrap) decryptCEK(
+	ris []asn1.RawValue,
+	cert *x509gost.Certificate,
+	prv *gost3410.PrivateKey,
+) ([]byte, error) {
+	_, vko2001, err := recipientKey(cert)
+	if err != nil {
+		return nil, err
+	}
+	for _, ri := range ris {
+		switch {
+		case ri.Class == asn1.ClassUniversal && ri.Tag == asn1.TagSequence:
+			var ktri keyTransRecipientInfo
+			if err = unmarshal(ri.FullBytes, &ktri); err != nil {
+				return nil, err
+			}
+			if matchSID(ktri.RID, cert) {
+				return w.decryptKeyTrans(&ktri, vko2001, prv)
+			}
+		case ri.Class == asn1.ClassContextSpecific && ri.Tag == 1:
+			der := append([]byte{}, ri.FullBytes...)
+			der[0] = 0x30
+			var kari keyAgreeRecipientInfo
+			if err = unmarshal(der, &kari); err != nil {
+				return nil, err
+			}
+			for _, rek := range kari.RecipientEncryptedKeys {
+				if matchRID(rek.RID, cert) {
+					return w.decryptKeyAgree(&kari, rek.EncryptedKey, vko2001, prv)
+				}
+			}
+		}
+	}
+	return nil, errors.New("no recipient info for the certificate")
+}
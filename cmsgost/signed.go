@@ -0,0 +1,638 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     []asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             []asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo    `asn1:"set"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+type essCertIDv2 struct {
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+	IssuerSerial  issuerSerial `asn1:"optional"`
+}
+
+type issuerSerial struct {
+	Issuer       []asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// Signer or countersigner of the SignedData.
+type SignerInfo struct {
+	// Signer's certificate, nil if it is not found among SignedData's
+	// certificates
+	Certificate        *x509gost.Certificate
+	SignatureAlgorithm x509gost.SignatureAlgorithm
+	// s||r, both big-endian
+	Signature []byte
+
+	// DER-encoded SET OF signed attributes exactly as they were signed,
+	// nil if there are no signed attributes
+	RawSignedAttributes []byte
+	SignedAttributes    []Attribute
+	// Values of the signed attributes, zero if absent
+	SigningTime   time.Time
+	MessageDigest []byte
+
+	// Unsigned attributes except countersignatures
+	UnsignedAttributes []Attribute
+	Countersignatures  []*SignerInfo
+
+	version            int
+	sid                asn1.RawValue
+	digestAlgorithm    pkix.AlgorithmIdentifier
+	signatureAlgorithm pkix.AlgorithmIdentifier
+}
+
+// CMS SignedData.
+type SignedData struct {
+	ContentType asn1.ObjectIdentifier
+	// Encapsulated content, nil if signature is detached
+	Content      []byte
+	Certificates []*x509gost.Certificate
+	Signers      []*SignerInfo
+
+	crls []asn1.RawValue
+}
+
+type SignerOptions struct {
+	// Chosen by the private key's mode if unknown
+	SignatureAlgorithm x509gost.SignatureAlgorithm
+	// Current time if zero
+	SigningTime time.Time
+	// Additional signed attributes
+	ExtraAttributes []Attribute
+}
+
+func digestOID(algo x509gost.SignatureAlgorithm) asn1.ObjectIdentifier {
+	switch algo {
+	case x509gost.GostR34102001WithGostR341194:
+		return oidGost341194
+	case x509gost.GostR34102012WithStreebog256:
+		return gostasn1.OIDGost34112012256
+	case x509gost.GostR34102012WithStreebog512:
+		return gostasn1.OIDGost34112012512
+	}
+	return nil
+}
+
+// SignerInfo's signatureAlgorithm is the public key algorithm identifier
+// (RFC 4490, RFC 9337).
+func keyAlgorithmOID(algo x509gost.SignatureAlgorithm) asn1.ObjectIdentifier {
+	switch algo {
+	case x509gost.GostR34102001WithGostR341194:
+		return gostasn1.OIDGost34102001
+	case x509gost.GostR34102012WithStreebog256:
+		return gostasn1.OIDGost34102012256
+	case x509gost.GostR34102012WithStreebog512:
+		return gostasn1.OIDGost34102012512
+	}
+	return nil
+}
+
+func signatureAlgorithmByDigest(oid asn1.ObjectIdentifier) x509gost.SignatureAlgorithm {
+	for _, algo := range []x509gost.SignatureAlgorithm{
+		x509gost.GostR34102001WithGostR341194,
+		x509gost.GostR34102012WithStreebog256,
+		x509gost.GostR34102012WithStreebog512,
+	} {
+		if digestOID(algo).Equal(oid) {
+			return algo
+		}
+	}
+	return x509gost.UnknownSignatureAlgorithm
+}
+
+func digest(algo x509gost.SignatureAlgorithm, data []byte) []byte {
+	h := algo.Hash()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Create SignedData of id-data content.
+func NewSignedData(content []byte) *SignedData {
+	return &SignedData{ContentType: OIDData, Content: content}
+}
+
+func (sd *SignedData) addCertificate(cert *x509gost.Certificate) {
+	for _, c := range sd.Certificates {
+		if c.Equal(cert) {
+			return
+		}
+	}
+	sd.Certificates = append(sd.Certificates, cert)
+}
+
+func signingCertificateAttribute(algo x509gost.SignatureAlgorithm, cert *x509gost.Certificate) (Attribute, error) {
+	return NewAttribute(oidAttributeSigningCertificateV2, signingCertificateV2{
+		Certs: []essCertIDv2{{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: digestOID(algo)},
+			CertHash:      digest(algo, cert.Raw),
+			IssuerSerial: issuerSerial{
+				Issuer:       []asn1.RawValue{tagged(4, cert.RawIssuer)},
+				SerialNumber: cert.SerialNumber,
+			},
+		}},
+	})
+}
+
+// Sign the message (content or countersigned signature) with signed
+// attributes. contentType is nil for countersignatures.
+func newSignerInfo(
+	rand io.Reader,
+	cert *x509gost.Certificate,
+	prv *gost3410.PrivateKey,
+	opts *SignerOptions,
+	contentType asn1.ObjectIdentifier,
+	message []byte,
+) (*SignerInfo, error) {
+	if opts == nil {
+		opts = &SignerOptions{}
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if pub.X.Cmp(cert.PublicKey.X) != 0 || pub.Y.Cmp(cert.PublicKey.Y) != 0 {
+		return nil, errors.New("private key does not match certificate")
+	}
+	algo := opts.SignatureAlgorithm
+	if algo == x509gost.UnknownSignatureAlgorithm {
+		algo = x509gost.GostR34102012WithStreebog256
+		if prv.Mode == gost3410.Mode2012 {
+			algo = x509gost.GostR34102012WithStreebog512
+		}
+	}
+	if digestOID(algo) == nil {
+		return nil, errors.New("unsupported signature algorithm")
+	}
+	signingTime := opts.SigningTime
+	if signingTime.IsZero() {
+		signingTime = time.Now()
+	}
+	signingTime = signingTime.UTC().Truncate(time.Second)
+	si := SignerInfo{
+		Certificate:        cert,
+		SignatureAlgorithm: algo,
+		SigningTime:        signingTime,
+		MessageDigest:      digest(algo, message),
+		version:            1,
+		digestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: digestOID(algo)},
+		signatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: keyAlgorithmOID(algo)},
+	}
+	var attrs []Attribute
+	if contentType != nil {
+		attr, err := NewAttribute(oidAttributeContentType, contentType)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	attr, err := NewAttribute(oidAttributeSigningTime, signingTime)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, attr)
+	if attr, err = NewAttribute(oidAttributeMessageDigest, si.MessageDigest); err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, attr)
+	if attr, err = signingCertificateAttribute(algo, cert); err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, attr)
+	si.SignedAttributes = append(attrs, opts.ExtraAttributes...)
+	der, err := marshalAttributes(si.SignedAttributes)
+	if err != nil {
+		return nil, err
+	}
+	if si.RawSignedAttributes, err = asn1.Marshal(asn1.RawValue{
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      der,
+	}); err != nil {
+		return nil, err
+	}
+	if si.Signature, err = prv.Sign(rand, algo.Digest(si.RawSignedAttributes), nil); err != nil {
+		return nil, err
+	}
+	sid, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+		SerialNumber: cert.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	si.sid = asn1.RawValue{FullBytes: sid}
+	return &si, nil
+}
+
+// Sign the content and add signer's certificate.
+func (sd *SignedData) AddSigner(
+	rand io.Reader,
+	cert *x509gost.Certificate,
+	prv *gost3410.PrivateKey,
+	opts *SignerOptions,
+) (*SignerInfo, error) {
+	if sd.Content == nil {
+		return nil, errors.New("no content to sign")
+	}
+	si, err := newSignerInfo(rand, cert, prv, opts, sd.ContentType, sd.Content)
+	if err != nil {
+		return nil, err
+	}
+	sd.Signers = append(sd.Signers, si)
+	sd.addCertificate(cert)
+	return si, nil
+}
+
+// Countersign the signature of the signer (which itself may be a
+// countersigner) and add countersigner's certificate.
+func (sd *SignedData) AddCountersigner(
+	rand io.Reader,
+	signer *SignerInfo,
+	cert *x509gost.Certificate,
+	prv *gost3410.PrivateKey,
+	opts *SignerOptions,
+) (*SignerInfo, error) {
+	si, err := newSignerInfo(rand, cert, prv, opts, nil, signer.Signature)
+	if err != nil {
+		return nil, err
+	}
+	signer.Countersignatures = append(signer.Countersignatures, si)
+	sd.addCertificate(cert)
+	return si, nil
+}
+
+func (si *SignerInfo) marshal() (signerInfo, error) {
+	out := signerInfo{
+		Version:            si.version,
+		SID:                si.sid,
+		DigestAlgorithm:    si.digestAlgorithm,
+		SignatureAlgorithm: si.signatureAlgorithm,
+		Signature:          si.Signature,
+	}
+	if si.RawSignedAttributes != nil {
+		var set asn1.RawValue
+		if err := unmarshal(si.RawSignedAttributes, &set); err != nil {
+			return out, err
+		}
+		out.SignedAttrs = tagged(0, set.Bytes)
+	}
+	attrs := si.UnsignedAttributes
+	if len(si.Countersignatures) > 0 {
+		countersignatures := Attribute{Type: oidAttributeCountersignature}
+		for _, cs := range si.Countersignatures {
+			csInfo, err := cs.marshal()
+			if err != nil {
+				return out, err
+			}
+			der, err := asn1.Marshal(csInfo)
+			if err != nil {
+				return out, err
+			}
+			countersignatures.Values = append(countersignatures.Values, asn1.RawValue{FullBytes: der})
+		}
+		attrs = append(append([]Attribute{}, attrs...), countersignatures)
+	}
+	if len(attrs) > 0 {
+		der, err := marshalAttributes(attrs)
+		if err != nil {
+			return out, err
+		}
+		out.UnsignedAttrs = tagged(1, der)
+	}
+	return out, nil
+}
+
+// DER-encoded ContentInfo with SignedData. Content is omitted if detached.
+func (sd *SignedData) Marshal(detached bool) ([]byte, error) {
+	out := signedData{
+		Version:          1,
+		EncapContentInfo: encapsulatedContentInfo{EContentType: sd.ContentType},
+		CRLs:             sd.crls,
+	}
+	if !sd.ContentType.Equal(OIDData) {
+		out.Version = 3
+	}
+	if !detached {
+		if sd.Content == nil {
+			return nil, errors.New("no content")
+		}
+		der, err := asn1.Marshal(sd.Content)
+		if err != nil {
+			return nil, err
+		}
+		out.EncapContentInfo.EContent = tagged(0, der)
+	}
+	for _, cert := range sd.Certificates {
+		out.Certificates = append(out.Certificates, asn1.RawValue{FullBytes: cert.Raw})
+	}
+	out.DigestAlgorithms = []pkix.AlgorithmIdentifier{}
+	for _, si := range sd.Signers {
+		info, err := si.marshal()
+		if err != nil {
+			return nil, err
+		}
+		if info.Version == 3 {
+			out.Version = 3
+		}
+		out.SignerInfos = append(out.SignerInfos, info)
+		known := false
+		for _, ai := range out.DigestAlgorithms {
+			known = known || ai.Algorithm.Equal(si.digestAlgorithm.Algorithm)
+		}
+		if !known {
+			out.DigestAlgorithms = append(out.DigestAlgorithms, si.digestAlgorithm)
+		}
+	}
+	if len(out.SignerInfos) == 0 {
+		return nil, errors.New("no signers")
+	}
+	return marshalContentInfo(OIDSignedData, out)
+}
+
+// Does SignerIdentifier refer to the certificate.
+func matchSID(sid asn1.RawValue, cert *x509gost.Certificate) bool {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		return len(cert.SubjectKeyID) > 0 && bytes.Equal(sid.Bytes, cert.SubjectKeyID)
+	}
+	var ias issuerAndSerialNumber
+	if err := unmarshal(sid.FullBytes, &ias); err != nil {
+		return false
+	}
+	return bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) &&
+		ias.SerialNumber.Cmp(cert.SerialNumber) == 0
+}
+
+func parseSignerInfo(info *signerInfo, certs []*x509gost.Certificate) (*SignerInfo, error) {
+	si := SignerInfo{
+		SignatureAlgorithm: signatureAlgorithmByDigest(info.DigestAlgorithm.Algorithm),
+		Signature:          info.Signature,
+		version:            info.Version,
+		sid:                info.SID,
+		digestAlgorithm:    info.DigestAlgorithm,
+		signatureAlgorithm: info.SignatureAlgorithm,
+	}
+	for _, cert := range certs {
+		if matchSID(info.SID, cert) {
+			si.Certificate = cert
+			break
+		}
+	}
+	var err error
+	if len(info.SignedAttrs.FullBytes) > 0 {
+		si.RawSignedAttributes = append([]byte{}, info.SignedAttrs.FullBytes...)
+		si.RawSignedAttributes[0] = 0x31 // SET OF instead of [0] IMPLICIT
+		if si.SignedAttributes, err = parseAttributes(info.SignedAttrs.Bytes); err != nil {
+			return nil, err
+		}
+		if attr := findAttribute(si.SignedAttributes, oidAttributeSigningTime); attr != nil {
+			if err = attr.unmarshal(&si.SigningTime); err != nil {
+				return nil, err
+			}
+		}
+		if attr := findAttribute(si.SignedAttributes, oidAttributeMessageDigest); attr != nil {
+			if err = attr.unmarshal(&si.MessageDigest); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(info.UnsignedAttrs.FullBytes) > 0 {
+		attrs, err := parseAttributes(info.UnsignedAttrs.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range attrs {
+			if !attr.Type.Equal(oidAttributeCountersignature) {
+				si.UnsignedAttributes = append(si.UnsignedAttributes, attr)
+				continue
+			}
+			for _, value := range attr.Values {
+				var csInfo signerInfo
+				if err = unmarshal(value.FullBytes, &csInfo); err != nil {
+					return nil, err
+				}
+				cs, err := parseSignerInfo(&csInfo, certs)
+				if err != nil {
+					return nil, err
+				}
+				si.Countersignatures = append(si.Countersignatures, cs)
+			}
+		}
+	}
+	return &si, nil
+}
+
+// Parse BER/DER-encoded ContentInfo with SignedData. Signatures are not
+// verified.
+func ParseSignedData(data []byte) (*SignedData, error) {
+	var in signedData
+	if err := parseContentInfo(data, OIDSignedData, &in); err != nil {
+		return nil, err
+	}
+	sd := SignedData{ContentType: in.EncapContentInfo.EContentType, crls: in.CRLs}
+	if len(in.EncapContentInfo.EContent.FullBytes) > 0 {
+		if err := unmarshal(in.EncapContentInfo.EContent.Bytes, &sd.Content); err != nil {
+			return nil, err
+		}
+		if sd.Content == nil {
+			sd.Content = []byte{}
+		}
+	}
+	for _, raw := range in.Certificates {
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			continue // other certificate formats
+		}
+		cert, err := x509gost.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		sd.Certificates = append(sd.Certificates, cert)
+	}
+	for i := range in.SignerInfos {
+		si, err := parseSignerInfo(&in.SignerInfos[i], sd.Certificates)
+		if err != nil {
+			return nil, err
+		}
+		sd.Signers = append(sd.Signers, si)
+	}
+	return &sd, nil
+}
+
+func checkSigningCertificate(attr *Attribute, cert *x509gost.Certificate) error {
+	var sc signingCertificateV2
+	if err := attr.unmarshal(&sc); err != nil {
+		return err
+	}
+	if len(sc.Certs) == 0 {
+		return errors.New("empty signingCertificateV2")
+	}
+	// The first certificate identifies the signer
+	id := sc.Certs[0]
+	var h hash.Hash
+	if len(id.HashAlgorithm.Algorithm) == 0 {
+		h = sha256.New()
+	} else if algo := signatureAlgorithmByDigest(id.HashAlgorithm.Algorithm); algo != x509gost.UnknownSignatureAlgorithm {
+		h = algo.Hash()
+	} else {
+		return errors.New("unsupported signingCertificateV2 hash algorithm")
+	}
+	h.Write(cert.Raw)
+	if !bytes.Equal(h.Sum(nil), id.CertHash) {
+		return errors.New("signingCertificateV2 does not match signer's certificate")
+	}
+	return nil
+}
+
+// Verify signer's signature over the message (content or countersigned
+// signature), its countersignatures and certificate chains.
+func (sd *SignedData) verifySigner(
+	si *SignerInfo,
+	contentType asn1.ObjectIdentifier,
+	message []byte,
+	opts *x509gost.VerifyOptions,
+) error {
+	cert := si.Certificate
+	if cert == nil {
+		return errors.New("signer's certificate not found")
+	}
+	if si.SignatureAlgorithm == x509gost.UnknownSignatureAlgorithm {
+		return errors.New("unsupported digest algorithm")
+	}
+	signed := message
+	if si.RawSignedAttributes != nil {
+		attr := findAttribute(si.SignedAttributes, oidAttributeContentType)
+		if contentType == nil {
+			if attr != nil {
+				return errors.New("content type attribute in countersignature")
+			}
+		} else {
+			var ct asn1.ObjectIdentifier
+			if attr == nil {
+				return errors.New("no content type attribute")
+			}
+			if err := attr.unmarshal(&ct); err != nil {
+				return err
+			}
+			if !ct.Equal(contentType) {
+				return errors.New("content type attribute mismatch")
+			}
+		}
+		if !bytes.Equal(si.MessageDigest, digest(si.SignatureAlgorithm, message)) {
+			return errors.New("message digest mismatch")
+		}
+		if attr = findAttribute(si.SignedAttributes, oidAttributeSigningCertificateV2); attr != nil {
+			if err := checkSigningCertificate(attr, cert); err != nil {
+				return err
+			}
+		}
+		signed = si.RawSignedAttributes
+	} else if contentType != nil && !contentType.Equal(OIDData) {
+		return errors.New("signed attributes are required")
+	}
+	if err := cert.CheckSignature(si.SignatureAlgorithm, signed, si.Signature); err != nil {
+		return err
+	}
+	if opts != nil {
+		o := *opts
+		o.Intermediates = o.Intermediates.Clone()
+		for _, c := range sd.Certificates {
+			o.Intermediates.AddCert(c)
+		}
+		if _, err := cert.Verify(o); err != nil {
+			return err
+		}
+	}
+	for _, cs := range si.Countersignatures {
+		if err := sd.verifySigner(cs, nil, si.Signature, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify all signatures and countersignatures over the encapsulated
+// content. If opts is not nil, signers' certificate chains are also
+// verified and SignedData's certificates are used as intermediates.
+// Chains are checked at the current time if opts.CurrentTime is zero:
+// signing time attribute is controlled by the signer, so validation at
+// that time must be requested explicitly by setting CurrentTime to it.
+func (sd *SignedData) Verify(opts *x509gost.VerifyOptions) error {
+	if sd.Content == nil {
+		return errors.New("detached signature: no content")
+	}
+	return sd.VerifyDetached(sd.Content, opts)
+}
+
+// Verify signatures over the detached content, see Verify.
+func (sd *SignedData) VerifyDetached(content []byte, opts *x509gost.VerifyOptions) error {
+	if len(sd.Signers) == 0 {
+		return errors.New("no signers")
+	}
+	for _, si := range sd.Signers {
+		if err := sd.verifySigner(si, sd.ContentType, content, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmsgost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/x509gost"
+)
+
+var now = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type testCA struct {
+	cert *x509gost.Certificate
+	prv  *gost3410.PrivateKey
+	pool *x509gost.CertPool
+}
+
+func genKey(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode) *gost3410.PrivateKey {
+	prv, err := gost3410.GenPrivateKey(curve, mode, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prv
+}
+
+func newCA(t *testing.T) *testCA {
+	prv := genKey(t, gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012)
+	pub, _ := prv.PublicKey()
+	template := x509gost.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            -1,
+	}
+	der, err := x509gost.CreateCertificate(rand.Reader, &template, &template, pub, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509gost.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509gost.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert, prv, pool}
+}
+
+// Issue end-entity certificate for the new key of given curve and mode.
+func (ca *testCA) issue(
+	t *testing.T,
+	name string,
+	curve *gost3410.Curve,
+	mode gost3410.Mode,
+	ku x509.KeyUsage,
+) (*x509gost.Certificate, *gost3410.PrivateKey) {
+	prv := genKey(t, curve, mode)
+	pub, _ := prv.PublicKey()
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509gost.CreateCertificate(rand.Reader, &x509gost.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     ku,
+	}, ca.cert, pub, ca.prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509gost.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, prv
+}
+
+func (ca *testCA) signer(t *testing.T, name string, mode gost3410.Mode) (*x509gost.Certificate, *gost3410.PrivateKey) {
+	curve := gost3410.CurveIdtc26gost34102012256paramSetA()
+	if mode == gost3410.Mode2012 {
+		curve = gost3410.CurveIdtc26gost34102012512paramSetC()
+	}
+	return ca.issue(t, name, curve, mode, x509.KeyUsageDigitalSignature)
+}
+
+func TestSignedDataAttached(t *testing.T) {
+	ca := newCA(t)
+	cert256, prv256 := ca.signer(t, "256", gost3410.Mode2001)
+	cert512, prv512 := ca.signer(t, "512", gost3410.Mode2012)
+	content := []byte("платёжное поручение")
+	sd := NewSignedData(content)
+	if _, err := sd.AddSigner(rand.Reader, cert256, prv256, &SignerOptions{SigningTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sd.AddSigner(rand.Reader, cert512, prv512, &SignerOptions{SigningTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Marshal(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseSignedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.ContentType.Equal(OIDData) ||
+		bytes.Compare(got.Content, content) != 0 ||
+		len(got.Certificates) != 2 ||
+		len(got.Signers) != 2 {
+		t.FailNow()
+	}
+	for i, expected := range []struct {
+		cert *x509gost.Certificate
+		algo x509gost.SignatureAlgorithm
+	}{
+		{cert256, x509gost.GostR34102012WithStreebog256},
+		{cert512, x509gost.GostR34102012WithStreebog512},
+	} {
+		si := got.Signers[i]
+		if si.Certificate == nil || !si.Certificate.Equal(expected.cert) ||
+			si.SignatureAlgorithm != expected.algo ||
+			!si.SigningTime.Equal(now) ||
+			len(si.Signature) != 2*int(expected.cert.PublicKey.Mode) {
+			t.FailNow()
+		}
+	}
+	if err = got.Verify(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = got.Verify(&x509gost.VerifyOptions{Roots: ca.pool, CurrentTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Verify(&x509gost.VerifyOptions{Roots: newCA(t).pool, CurrentTime: now}) == nil {
+		t.FailNow()
+	}
+	// Verification time is out of certificate's validity
+	if got.Verify(&x509gost.VerifyOptions{
+		Roots:       ca.pool,
+		CurrentTime: now.Add(2 * time.Hour),
+	}) == nil {
+		t.FailNow()
+	}
+
+	reencoded, err := got.Marshal(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(reencoded, der) != 0 {
+		t.FailNow()
+	}
+
+	got.Content[0] ^= 1
+	if got.Verify(nil) == nil {
+		t.FailNow()
+	}
+}
+
+func TestSignedDataDetached(t *testing.T) {
+	ca := newCA(t)
+	cert, prv := ca.signer(t, "signer", gost3410.Mode2001)
+	content := []byte("detached")
+	sd := NewSignedData(content)
+	if _, err := sd.AddSigner(rand.Reader, cert, prv, &SignerOptions{
+		SignatureAlgorithm: x509gost.GostR34102001WithGostR341194,
+		SigningTime:        now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Marshal(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseSignedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != nil ||
+		got.Signers[0].SignatureAlgorithm != x509gost.GostR34102001WithGostR341194 {
+		t.FailNow()
+	}
+	if got.Verify(nil) == nil {
+		t.FailNow()
+	}
+	if err = got.VerifyDetached(content, &x509gost.VerifyOptions{Roots: ca.pool, CurrentTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	if got.VerifyDetached([]byte("another"), nil) == nil {
+		t.FailNow()
+	}
+}
+
+func TestSignedDataCountersignature(t *testing.T) {
+	ca := newCA(t)
+	cert, prv := ca.signer(t, "signer", gost3410.Mode2001)
+	notary, notaryPrv := ca.signer(t, "notary", gost3410.Mode2012)
+	auditor, auditorPrv := ca.signer(t, "auditor", gost3410.Mode2001)
+	opts := &SignerOptions{SigningTime: now}
+	sd := NewSignedData([]byte("contract"))
+	si, err := sd.AddSigner(rand.Reader, cert, prv, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Marshal(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Countersign already parsed SignedData
+	parsed, err := ParseSignedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := parsed.AddCountersigner(rand.Reader, parsed.Signers[0], notary, notaryPrv, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = parsed.AddCountersigner(rand.Reader, cs, auditor, auditorPrv, opts); err != nil {
+		t.Fatal(err)
+	}
+	if der, err = parsed.Marshal(false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseSignedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Certificates) != 3 || len(got.Signers) != 1 ||
+		bytes.Compare(got.Signers[0].Signature, si.Signature) != 0 ||
+		len(got.Signers[0].Countersignatures) != 1 ||
+		len(got.Signers[0].UnsignedAttributes) != 0 {
+		t.FailNow()
+	}
+	cs = got.Signers[0].Countersignatures[0]
+	if !cs.Certificate.Equal(notary) ||
+		findAttribute(cs.SignedAttributes, oidAttributeContentType) != nil ||
+		len(cs.Countersignatures) != 1 ||
+		!cs.Countersignatures[0].Certificate.Equal(auditor) {
+		t.FailNow()
+	}
+	// Signing time attribute does not extend certificates' validity
+	if got.Verify(&x509gost.VerifyOptions{Roots: ca.pool}) == nil {
+		t.FailNow()
+	}
+	if err = got.Verify(&x509gost.VerifyOptions{Roots: ca.pool, CurrentTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	cs.Countersignatures[0].Signature[0] ^= 1
+	if got.Verify(nil) == nil {
+		t.FailNow()
+	}
+}
+
+func TestSignedDataSigningCertificate(t *testing.T) {
+	ca := newCA(t)
+	cert, prv := ca.signer(t, "signer", gost3410.Mode2001)
+	other, _ := ca.signer(t, "signer", gost3410.Mode2001)
+	sd := NewSignedData([]byte("data"))
+	if _, err := sd.AddSigner(rand.Reader, cert, prv, nil); err != nil {
+		t.Fatal(err)
+	}
+	si := sd.Signers[0]
+	attr := findAttribute(si.SignedAttributes, oidAttributeSigningCertificateV2)
+	if attr == nil {
+		t.FailNow()
+	}
+	if err := checkSigningCertificate(attr, cert); err != nil {
+		t.Fatal(err)
+	}
+	if checkSigningCertificate(attr, other) == nil {
+		t.FailNow()
+	}
+	if _, err := sd.AddSigner(rand.Reader, other, prv, nil); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestSignedDataExtraAttributes(t *testing.T) {
+	ca := newCA(t)
+	cert, prv := ca.signer(t, "signer", gost3410.Mode2001)
+	extra, err := NewAttribute([]int{1, 2, 643, 100, 1}, "комментарий")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd := NewSignedData([]byte{})
+	si, err := sd.AddSigner(rand.Reader, cert, prv, &SignerOptions{
+		ExtraAttributes: []Attribute{extra},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	si.UnsignedAttributes = []Attribute{extra}
+	der, err := sd.Marshal(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseSignedData(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content == nil || len(got.Content) != 0 {
+		t.FailNow()
+	}
+	si = got.Signers[0]
+	if len(si.SignedAttributes) != 5 || len(si.UnsignedAttributes) != 1 {
+		t.FailNow()
+	}
+	var comment string
+	if err = findAttribute(si.SignedAttributes, extra.Type).unmarshal(&comment); err != nil {
+		t.Fatal(err)
+	}
+	if comment != "комментарий" {
+		t.FailNow()
+	}
+	if err = got.Verify(nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,355 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// CryptoPro CSP file key containers reader.
+//
+// Container is a directory with header.key, primary.key, masks.key and
+// other files. The private key stored in primary.key is blinded with the
+// mask and encrypted with GOST 28147-89 in ECB mode on the key derived
+// from the password and the salt from masks.key. Containers with GOST R 34.10-2001 keys use GOST R 34.11-94
+// and CryptoPro-A S-box, 34.10-2012 ones use Streebog-256 and
+// id-tc26-gost-28147-param-Z S-box.
+//
+// Format is not publicly documented: the reader follows the scheme used
+// by open source key recovery tools. Containers whose primary key is
+// protected in CFB mode (with an IV stored next to the ciphertext) are
+// not supported and rejected with ErrUnsupported.
+package cryptopro
+
+import (
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost341194"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+const (
+	SaltSize        = 12
+	FingerprintSize = 8
+
+	// Iterations count of password key derivation
+	Iterations = 2000
+)
+
+var (
+	ErrUnsupported = errors.New("cryptopro: unsupported container: only ECB protected primary key is supported")
+
+	oidGost34102001DH    = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 98}
+	oidGost34102012256DH = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 6, 1}
+	oidGost34102012512DH = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 6, 2}
+
+	pwdKeyInit = []byte("DENEFH028.760246785.IUEFHWUIO.EF")
+)
+
+// Header of the container.
+type Header struct {
+	// Private key's algorithm
+	Algorithm asn1.ObjectIdentifier
+	Curve     *gost3410.Curve
+	Mode      gost3410.Mode
+	// First bytes of the little-endian public key's X coordinate
+	Fingerprint []byte
+}
+
+// 2012 keys use Streebog and TC26 S-box.
+func (h *Header) is2012() bool {
+	return !h.Algorithm.Equal(gostasn1.OIDGost34102001) &&
+		!h.Algorithm.Equal(oidGost34102001DH)
+}
+
+func (h *Header) newHash() hash.Hash {
+	if h.is2012() {
+		return gost34112012256.New()
+	}
+	return gost341194.New(&gost28147.SboxIdGostR341194CryptoProParamSet)
+}
+
+func (h *Header) sbox() *gost28147.Sbox {
+	if h.is2012() {
+		return &gost28147.SboxIdtc26gost28147paramZ
+	}
+	return &gost28147.SboxIdGost2814789CryptoProAParamSet
+}
+
+func keyMode(oid asn1.ObjectIdentifier) (gost3410.Mode, bool) {
+	switch {
+	case oid.Equal(gostasn1.OIDGost34102001),
+		oid.Equal(oidGost34102001DH),
+		oid.Equal(gostasn1.OIDGost34102012256),
+		oid.Equal(oidGost34102012256DH):
+		return gost3410.Mode2001, true
+	case oid.Equal(gostasn1.OIDGost34102012512),
+		oid.Equal(oidGost34102012512DH):
+		return gost3410.Mode2012, true
+	}
+	return 0, false
+}
+
+// Search for the private key's AlgorithmIdentifier: element whose first
+// child is known key algorithm identifier followed by the parameters.
+func findAlgorithm(der []byte, depth int) (oid asn1.ObjectIdentifier, params asn1.RawValue, found bool) {
+	if depth > 8 {
+		return
+	}
+	for len(der) > 0 {
+		var v asn1.RawValue
+		var err error
+		if der, err = asn1.Unmarshal(der, &v); err != nil || !v.IsCompound {
+			continue
+		}
+		rest, err := asn1.Unmarshal(v.Bytes, &oid)
+		if err == nil && len(rest) > 0 {
+			if _, ok := keyMode(oid); ok {
+				if _, err = asn1.Unmarshal(rest, &params); err == nil {
+					found = true
+					return
+				}
+			}
+		}
+		if oid, params, found = findAlgorithm(v.Bytes, depth+1); found {
+			return
+		}
+	}
+	return
+}
+
+// Parse header.key contents.
+func ParseHeader(data []byte) (*Header, error) {
+	var outer struct {
+		Content asn1.RawValue
+		HMAC    []byte
+	}
+	rest, err := asn1.Unmarshal(data, &outer)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after header")
+	}
+	var h Header
+	oid, rawParams, found := findAlgorithm(outer.Content.Bytes, 0)
+	if !found {
+		return nil, errors.New("no private key algorithm in header")
+	}
+	h.Algorithm = oid
+	h.Mode, _ = keyMode(oid)
+	var params gostasn1.PublicKeyParameters
+	if _, err = asn1.Unmarshal(rawParams.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if h.Curve, err = gostasn1.CurveByOID(params.PublicKeyParamSet); err != nil {
+		return nil, err
+	}
+	// primaryFP [10] IMPLICIT OCTET STRING
+	for content := outer.Content.Bytes; len(content) > 0; {
+		var v asn1.RawValue
+		if content, err = asn1.Unmarshal(content, &v); err != nil {
+			return nil, err
+		}
+		if v.Class == asn1.ClassContextSpecific && v.Tag == 10 && !v.IsCompound {
+			h.Fingerprint = v.Bytes
+			break
+		}
+	}
+	if len(h.Fingerprint) != FingerprintSize {
+		return nil, errors.New("no public key fingerprint in header")
+	}
+	return &h, nil
+}
+
+// Take OCTET STRING either directly or as the first element of SEQUENCE.
+func octets(data []byte) ([][]byte, error) {
+	var raw asn1.RawValue
+	rest, err := asn1.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data")
+	}
+	if raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagOctetString {
+		return [][]byte{raw.Bytes}, nil
+	}
+	if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+		return nil, errors.New("unexpected ASN.1 structure")
+	}
+	var os [][]byte
+	for rest = raw.Bytes; len(rest) > 0; {
+		var o []byte
+		if rest, err = asn1.Unmarshal(rest, &o); err != nil {
+			return nil, err
+		}
+		os = append(os, o)
+	}
+	return os, nil
+}
+
+// Derive the key encryption key from password and salt.
+func (h *Header) passwordKey(password string, salt []byte) []byte {
+	pin := make([]byte, 4*len(password))
+	defer wipe.Bytes(pin)
+	for i := 0; i < len(password); i++ {
+		pin[4*i] = password[i]
+	}
+	iterations := 2
+	if len(password) > 0 {
+		iterations = Iterations
+	}
+	hsh := h.newHash()
+	hsh.Write(salt)
+	hsh.Write(pin)
+	hashed := hsh.Sum(nil)
+	defer wipe.Bytes(hashed)
+
+	current := make([]byte, len(pwdKeyInit))
+	copy(current, pwdKeyInit)
+	m36 := make([]byte, len(current))
+	m5c := make([]byte, len(current))
+	defer wipe.Bytes(m36)
+	defer wipe.Bytes(m5c)
+	xor := func() {
+		for i, b := range current {
+			m36[i] = b ^ 0x36
+			m5c[i] = b ^ 0x5C
+		}
+	}
+	for i := 0; i < iterations; i++ {
+		xor()
+		hsh.Reset()
+		hsh.Write(m36)
+		hsh.Write(hashed)
+		hsh.Write(m5c)
+		hsh.Write(hashed)
+		wipe.Bytes(current)
+		current = hsh.Sum(current[:0])
+	}
+	xor()
+	hsh.Reset()
+	hsh.Write(m36)
+	hsh.Write(salt)
+	hsh.Write(m5c)
+	hsh.Write(pin)
+	wipe.Bytes(current)
+	current = hsh.Sum(current[:0])
+	hsh.Reset()
+	hsh.Write(current)
+	wipe.Bytes(current)
+	return hsh.Sum(nil)
+}
+
+func leBig(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[i] = b[len(b)-i-1]
+	}
+	defer wipe.Bytes(be)
+	return new(big.Int).SetBytes(be)
+}
+
+// Decode private key from the contents of header.key, primary.key and
+// masks.key files. Resulting key is checked against the public key's
+// fingerprint stored in the header.
+func Decode(header, primary, masks []byte, password string) (*gost3410.PrivateKey, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	h, err := ParseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := octets(primary)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := octets(masks)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) < 2 || len(ms[1]) < SaltSize {
+		return nil, errors.New("invalid masks")
+	}
+	keySize := int(h.Mode)
+	if len(ms[0]) != keySize {
+		return nil, errors.New("invalid key size")
+	}
+	if len(ps[0]) != keySize {
+		return nil, ErrUnsupported
+	}
+	kek := h.passwordKey(password, ms[1][:SaltSize])
+	defer wipe.Bytes(kek)
+	c := gost28147.NewCipher(kek, h.sbox())
+	defer c.Destroy()
+	masked := make([]byte, keySize)
+	defer wipe.Bytes(masked)
+	c.NewECBDecrypter().CryptBlocks(masked, ps[0])
+
+	k := leBig(masked)
+	defer wipe.BigInt(k)
+	mask := leBig(ms[0])
+	defer wipe.BigInt(mask)
+	if mask.ModInverse(mask, h.Curve.Q) == nil {
+		return nil, errors.New("invalid mask")
+	}
+	k.Mul(k, mask)
+	k.Mod(k, h.Curve.Q)
+	if k.Sign() == 0 {
+		return nil, errors.New("invalid private key")
+	}
+	prv := &gost3410.PrivateKey{C: h.Curve, Mode: h.Mode, Key: new(big.Int).Set(k)}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if !equalFingerprint(pub.Raw(), h.Fingerprint) {
+		prv.Destroy()
+		return nil, errors.New("fingerprint mismatch: wrong password or corrupted container")
+	}
+	return prv, nil
+}
+
+func equalFingerprint(pubRaw, fp []byte) bool {
+	for i := 0; i < FingerprintSize; i++ {
+		if pubRaw[i] != fp[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Read and decode private key from the container's directory.
+func Open(dir, password string) (*gost3410.PrivateKey, error) {
+	var files [3][]byte
+	for i, name := range []string{"header.key", "primary.key", "masks.key"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		files[i] = data
+	}
+	defer wipe.Bytes(files[1])
+	defer wipe.Bytes(files[2])
+	return Decode(files[0], files[1], files[2], password)
+}
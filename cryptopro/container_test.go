@@ -0,0 +1,233 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cryptopro
+
+import (
+	"crypto/rand"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+func reversed(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[i] = b[len(b)-i-1]
+	}
+	return r
+}
+
+func pad(b []byte, size int) []byte {
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// Create container files the same way CSP does.
+func makeContainer(t *testing.T, algo asn1.ObjectIdentifier, prv *gost3410.PrivateKey, password string) (header, primary, masks []byte) {
+	// Unmasking yields key reduced modulo Q
+	prv.Key.Mod(prv.Key, prv.C.Q)
+	curveOID, err := gostasn1.CurveOID(prv.C)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(gostasn1.PublicKeyParameters{
+		PublicKeyParamSet: curveOID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	algoOID, err := asn1.Marshal(algo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyParams, err := asn1.Marshal(struct {
+		Attributes asn1.BitString
+		Algorithm  asn1.RawValue
+	}{
+		asn1.BitString{Bytes: []byte{0x80}, BitLength: 1},
+		asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      append(algoOID, params...),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err = asn1.Marshal(struct {
+		Content struct {
+			Attributes asn1.BitString
+			KeyParams  asn1.RawValue
+			FP         asn1.RawValue
+		}
+		HMAC []byte
+	}{
+		struct {
+			Attributes asn1.BitString
+			KeyParams  asn1.RawValue
+			FP         asn1.RawValue
+		}{
+			asn1.BitString{Bytes: []byte{0x80}, BitLength: 1},
+			asn1.RawValue{FullBytes: keyParams},
+			asn1.RawValue{
+				Class: asn1.ClassContextSpecific,
+				Tag:   10,
+				Bytes: pub.Raw()[:FingerprintSize],
+			},
+		},
+		[]byte{1, 2, 3, 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keySize := int(prv.Mode)
+	mask, err := rand.Int(rand.Reader, prv.C.Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := make([]byte, 32)
+	rand.Read(salt)
+	masks, err = asn1.Marshal(struct {
+		Mask         []byte
+		RandomStatus []byte
+		HMACRandom   []byte
+	}{
+		reversed(pad(mask.Bytes(), keySize)),
+		salt,
+		[]byte{1, 2, 3, 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	masked := new(big.Int).Mul(prv.Key, mask)
+	masked.Mod(masked, prv.C.Q)
+	h, err := ParseHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kek := h.passwordKey(password, salt[:SaltSize])
+	encrypted := make([]byte, keySize)
+	gost28147.NewCipher(kek, h.sbox()).NewECBEncrypter().CryptBlocks(
+		encrypted, reversed(pad(masked.Bytes(), keySize)),
+	)
+	primary, err = asn1.Marshal(struct{ Key []byte }{encrypted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestDecode(t *testing.T) {
+	for _, c := range []struct {
+		algo  asn1.ObjectIdentifier
+		curve *gost3410.Curve
+		mode  gost3410.Mode
+	}{
+		{gostasn1.OIDGost34102001, gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001},
+		{oidGost34102001DH, gost3410.CurveIdGostR34102001CryptoProXchAParamSet(), gost3410.Mode2001},
+		{gostasn1.OIDGost34102012256, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001},
+		{gostasn1.OIDGost34102012512, gost3410.CurveIdtc26gost341012512paramSetB(), gost3410.Mode2012},
+	} {
+		prv, err := gost3410.GenPrivateKey(c.curve, c.mode, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, password := range []string{"", "12345678"} {
+			header, primary, masks := makeContainer(t, c.algo, prv, password)
+			got, err := Decode(header, primary, masks, password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Key.Cmp(prv.Key) != 0 || got.Mode != prv.Mode || got.C.Name != prv.C.Name {
+				t.FailNow()
+			}
+			if _, err = Decode(header, primary, masks, "wrong"); err == nil {
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	prv, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost34102012256paramSetA(),
+		gost3410.Mode2001,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, primary, masks := makeContainer(t, gostasn1.OIDGost34102012256, prv, "password")
+	dir, err := ioutil.TempDir("", "cryptopro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string][]byte{
+		"header.key":  header,
+		"primary.key": primary,
+		"masks.key":   masks,
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Open(dir, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Key.Cmp(prv.Key) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestCFBUnsupported(t *testing.T) {
+	prv, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost34102012256paramSetA(),
+		gost3410.Mode2001,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, primary, masks := makeContainer(t, gostasn1.OIDGost34102012256, prv, "")
+	var p struct{ Key []byte }
+	if _, err = asn1.Unmarshal(primary, &p); err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, gost28147.BlockSize)
+	primary, err = asn1.Marshal(struct{ Key []byte }{append(iv, p.Key...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decode(header, primary, masks, ""); err != ErrUnsupported {
+		t.FailNow()
+	}
+}
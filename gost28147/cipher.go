@@ -18,6 +18,13 @@
 // RFC 5830.
 package gost28147
 
+import (
+	"crypto/cipher"
+
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
 const (
 	BlockSize = 8
 	KeySize   = 32
@@ -55,10 +62,22 @@ var (
 type Cipher struct {
 	key  [KeySize]byte
 	sbox *Sbox
+	ct   *sboxSliced
 	x    [8]nv
 }
 
+func init() {
+	strict.NewGost28147 = func(key []byte, sbox interface{}) cipher.Block {
+		return newCipher(key, sbox.(*Sbox))
+	}
+}
+
 func NewCipher(key []byte, sbox *Sbox) *Cipher {
+	strict.Check()
+	return newCipher(key, sbox)
+}
+
+func newCipher(key []byte, sbox *Sbox) *Cipher {
 	if len(key) != KeySize {
 		panic("invalid key size")
 	}
@@ -77,6 +96,23 @@ func NewCipher(key []byte, sbox *Sbox) *Cipher {
 	return &c
 }
 
+// Create cipher using bitsliced constant-time Sbox substitution. It is
+// several times slower, but does not leak key and data through the
+// cache timings.
+func NewCipherConstantTime(key []byte, sbox *Sbox) *Cipher {
+	c := NewCipher(key, sbox)
+	c.ct = sbox.sliced()
+	return c
+}
+
+// Zero the key material. Cipher must not be used after that.
+func (c *Cipher) Destroy() {
+	wipe.Bytes(c.key[:])
+	for i := 0; i < len(c.x); i++ {
+		c.x[i] = 0
+	}
+}
+
 func (c *Cipher) BlockSize() int {
 	return BlockSize
 }
@@ -101,6 +137,12 @@ func nvs2block(n1, n2 nv, b []byte) {
 }
 
 func (c *Cipher) xcrypt(seq Seq, n1, n2 nv) (nv, nv) {
+	if c.ct != nil {
+		for _, i := range seq {
+			n1, n2 = c.ct.k(n1+c.x[i]).shift11()^n2, n1
+		}
+		return n1, n2
+	}
 	for _, i := range seq {
 		n1, n2 = c.sbox.k(n1+c.x[i]).shift11()^n2, n1
 	}
@@ -38,3 +38,13 @@ func BenchmarkCipher(b *testing.B) {
 		c.Encrypt(dst, src)
 	}
 }
+
+func TestDestroy(t *testing.T) {
+	var key [KeySize]byte
+	rand.Read(key[:])
+	c := NewCipherConstantTime(key[:], SboxDefault)
+	c.Destroy()
+	if c.key != [KeySize]byte{} || c.x != [8]nv{} {
+		t.FailNow()
+	}
+}
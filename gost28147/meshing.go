@@ -0,0 +1,108 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost28147
+
+import (
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+// Amount of data processed with a single key in CryptoPro key meshing.
+const MeshingSize = 1024
+
+// CryptoPro key meshing constant C (RFC 4357 2.3.1).
+var meshingKey = []byte{
+	0x69, 0x00, 0x72, 0x22, 0x64, 0xC9, 0x04, 0x23,
+	0x8D, 0x3A, 0xDB, 0x96, 0x46, 0xE9, 0x2A, 0xC4,
+	0x18, 0xFE, 0xAC, 0x94, 0x00, 0xED, 0x07, 0x12,
+	0xC0, 0x86, 0xDC, 0xC2, 0xEF, 0x4C, 0xA9, 0x2B,
+}
+
+// CFB mode with CryptoPro key meshing (RFC 4357 2.3.2): after every
+// MeshingSize bytes the key is "decrypted" meshing constant and the
+// feedback block is encrypted with the new key.
+type CFBMeshing struct {
+	c       *Cipher
+	encrypt bool
+	reg     []byte
+	gamma   []byte
+	pos     int
+	n       int
+}
+
+func (c *Cipher) newCFBMeshing(iv []byte, encrypt bool) *CFBMeshing {
+	if len(iv) != BlockSize {
+		panic("iv length is not equal to blocksize")
+	}
+	s := CFBMeshing{
+		c:       newCipher(c.key[:], c.sbox),
+		encrypt: encrypt,
+		reg:     make([]byte, BlockSize),
+		gamma:   make([]byte, BlockSize),
+		pos:     BlockSize,
+	}
+	s.c.ct = c.ct
+	copy(s.reg, iv)
+	return &s
+}
+
+// Stream has its own copy of the key, that is changed during meshing.
+func (c *Cipher) NewCFBMeshingEncrypter(iv []byte) *CFBMeshing {
+	return c.newCFBMeshing(iv, true)
+}
+
+func (c *Cipher) NewCFBMeshingDecrypter(iv []byte) *CFBMeshing {
+	return c.newCFBMeshing(iv, false)
+}
+
+func (s *CFBMeshing) mesh() {
+	key := make([]byte, KeySize)
+	for i := 0; i < KeySize; i += BlockSize {
+		s.c.Decrypt(key[i:i+BlockSize], meshingKey[i:i+BlockSize])
+	}
+	c := newCipher(key, s.c.sbox)
+	c.ct = s.c.ct
+	wipe.Bytes(key)
+	s.c.Destroy()
+	s.c = c
+	s.c.Encrypt(s.reg, s.reg)
+}
+
+func (s *CFBMeshing) XORKeyStream(dst, src []byte) {
+	for i, b := range src {
+		if s.pos == BlockSize {
+			if s.n > 0 && s.n%MeshingSize == 0 {
+				s.mesh()
+			}
+			s.c.Encrypt(s.gamma, s.reg)
+			s.pos = 0
+		}
+		dst[i] = b ^ s.gamma[s.pos]
+		if s.encrypt {
+			s.reg[s.pos] = dst[i]
+		} else {
+			s.reg[s.pos] = b
+		}
+		s.pos++
+		s.n++
+	}
+}
+
+// Zero the key material.
+func (s *CFBMeshing) Destroy() {
+	s.c.Destroy()
+	wipe.Bytes(s.gamma)
+}
@@ -0,0 +1,85 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost28147
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"testing/quick"
+)
+
+func TestCFBMeshingFirstSection(t *testing.T) {
+	key := make([]byte, KeySize)
+	iv := make([]byte, BlockSize)
+	pt := make([]byte, MeshingSize+3*BlockSize)
+	rand.Read(key)
+	rand.Read(iv)
+	rand.Read(pt)
+	c := NewCipher(key, &SboxIdGost2814789CryptoProAParamSet)
+	ct := make([]byte, len(pt))
+	c.NewCFBMeshingEncrypter(iv).XORKeyStream(ct, pt)
+	plain := make([]byte, len(pt))
+	c.NewCFBEncrypter(iv).XORKeyStream(plain, pt)
+	if bytes.Compare(ct[:MeshingSize], plain[:MeshingSize]) != 0 {
+		t.FailNow()
+	}
+	if bytes.Compare(ct[MeshingSize:], plain[MeshingSize:]) == 0 {
+		t.FailNow()
+	}
+
+	// Next section: new key is decrypted constant, feedback is
+	// encrypted with it
+	meshed := make([]byte, KeySize)
+	c.NewECBDecrypter().CryptBlocks(meshed, meshingKey)
+	reg := make([]byte, BlockSize)
+	NewCipher(meshed, c.sbox).Encrypt(reg, ct[MeshingSize-BlockSize:MeshingSize])
+	expected := make([]byte, len(pt)-MeshingSize)
+	NewCipher(meshed, c.sbox).NewCFBEncrypter(reg).XORKeyStream(expected, pt[MeshingSize:])
+	if bytes.Compare(ct[MeshingSize:], expected) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestCFBMeshingRandom(t *testing.T) {
+	f := func(key [KeySize]byte, iv [BlockSize]byte, chunks []uint16) bool {
+		var pt []byte
+		for _, n := range chunks {
+			chunk := make([]byte, int(n)%3000)
+			rand.Read(chunk)
+			pt = append(pt, chunk...)
+		}
+		c := NewCipher(key[:], &SboxIdtc26gost28147paramZ)
+		ct := make([]byte, len(pt))
+		whole := make([]byte, len(pt))
+		c.NewCFBMeshingEncrypter(iv[:]).XORKeyStream(whole, pt)
+		e := c.NewCFBMeshingEncrypter(iv[:])
+		d := c.NewCFBMeshingDecrypter(iv[:])
+		got := make([]byte, len(pt))
+		offset := 0
+		for _, n := range chunks {
+			n := int(n) % 3000
+			e.XORKeyStream(ct[offset:offset+n], pt[offset:offset+n])
+			d.XORKeyStream(got[offset:offset+n], ct[offset:offset+n])
+			offset += n
+		}
+		return bytes.Compare(ct, whole) == 0 && bytes.Compare(got, pt) == 0
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
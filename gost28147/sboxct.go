@@ -0,0 +1,92 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost28147
+
+// Bitsliced representation of Sbox, used by constant-time cipher.
+// Each of four output bits of every substitution box is expressed in
+// algebraic normal form: XOR of monomials over four input bits. Eight
+// boxes are processed simultaneously: bit i of the plane corresponds
+// to the i-th box, so k[j][m] is the mask of boxes having monomial m
+// in the ANF of their j-th output bit.
+type sboxSliced [4][16]uint8
+
+func (s *Sbox) sliced() *sboxSliced {
+	r := new(sboxSliced)
+	var f [16]uint8
+	var i, j, m, x uint
+	for i = 0; i < 8; i++ {
+		for j = 0; j < 4; j++ {
+			for x = 0; x < 16; x++ {
+				f[x] = (s[i][x] >> j) & 1
+			}
+			// Moebius transform of the truth table
+			for m = 1; m < 16; m <<= 1 {
+				for x = 0; x < 16; x++ {
+					if x&m != 0 {
+						f[x] ^= f[x^m]
+					}
+				}
+			}
+			for m = 0; m < 16; m++ {
+				r[j][m] |= f[m] << i
+			}
+		}
+	}
+	return r
+}
+
+// Gather bits j, j+4, ..., j+28 of n into the single byte.
+func gather(n nv, j uint) uint8 {
+	n = (n >> j) & 0x11111111
+	n = (n | n>>3) & 0x03030303
+	n = (n | n>>6) & 0x000F000F
+	return uint8(n | n>>12)
+}
+
+// Inverse of gather: spread the byte over bits j, j+4, ..., j+28.
+func scatter(b uint8, j uint) nv {
+	n := nv(b)
+	n = (n | n<<12) & 0x000F000F
+	n = (n | n<<6) & 0x03030303
+	n = (n | n<<3) & 0x11111111
+	return n << j
+}
+
+// Sbox substitution itself, without any secret dependent memory
+// accesses and branches.
+func (s *sboxSliced) k(n nv) nv {
+	var mono [16]uint8
+	mono[0] = 0xFF
+	var j, m uint
+	var p uint8
+	for j = 0; j < 4; j++ {
+		p = gather(n, j)
+		for m = 1 << j; m < 2<<j; m++ {
+			mono[m] = mono[m^(1<<j)] & p
+		}
+	}
+	var o uint8
+	var r nv
+	for j = 0; j < 4; j++ {
+		o = 0
+		for m = 0; m < 16; m++ {
+			o ^= mono[m] & s[j][m]
+		}
+		r |= scatter(o, j)
+	}
+	return r
+}
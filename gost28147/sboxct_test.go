@@ -0,0 +1,104 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost28147
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"testing"
+	"testing/quick"
+)
+
+func TestCipherConstantTimeInterface(t *testing.T) {
+	var _ cipher.Block = NewCipherConstantTime(make([]byte, KeySize), SboxDefault)
+}
+
+func TestSboxSliced(t *testing.T) {
+	for _, sbox := range []*Sbox{
+		&SboxIdGost2814789TestParamSet,
+		&SboxIdGost2814789CryptoProAParamSet,
+		&SboxIdGost2814789CryptoProBParamSet,
+		&SboxIdGost2814789CryptoProCParamSet,
+		&SboxIdGost2814789CryptoProDParamSet,
+		&SboxIdtc26gost28147paramZ,
+		&SboxIdGostR341194TestParamSet,
+		&SboxIdGostR341194CryptoProParamSet,
+		&SboxEACParamSet,
+	} {
+		sliced := sbox.sliced()
+		f := func(n uint32) bool {
+			return sbox.k(nv(n)) == sliced.k(nv(n))
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCipherConstantTimeRandom(t *testing.T) {
+	dst1 := make([]byte, BlockSize)
+	dst2 := make([]byte, BlockSize)
+	f := func(key [KeySize]byte, pt [BlockSize]byte) bool {
+		c := NewCipher(key[:], SboxDefault)
+		cct := NewCipherConstantTime(key[:], SboxDefault)
+		c.Encrypt(dst1, pt[:])
+		cct.Encrypt(dst2, pt[:])
+		if bytes.Compare(dst1, dst2) != 0 {
+			return false
+		}
+		cct.Decrypt(dst2, dst2)
+		return bytes.Compare(dst2, pt[:]) == 0
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCipherConstantTimeVector(t *testing.T) {
+	key := []byte{
+		0x04, 0x75, 0xf6, 0xe0, 0x50, 0x38, 0xfb, 0xfa,
+		0xd2, 0xc7, 0xc3, 0x90, 0xed, 0xb3, 0xca, 0x3d,
+		0x15, 0x47, 0x12, 0x42, 0x91, 0xae, 0x1e, 0x8a,
+		0x2f, 0x79, 0xcd, 0x9e, 0xd2, 0xbc, 0xef, 0xbd,
+	}
+	pt := []byte{0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00}
+	ct := []byte{0x4b, 0x8c, 0x4c, 0x98, 0x15, 0xf2, 0x4a, 0xea}
+	c := NewCipherConstantTime(key, &SboxIdGost2814789TestParamSet)
+	dst := make([]byte, BlockSize)
+	c.Encrypt(dst, pt)
+	if bytes.Compare(dst, ct) != 0 {
+		t.FailNow()
+	}
+	c.Decrypt(dst, dst)
+	if bytes.Compare(dst, pt) != 0 {
+		t.FailNow()
+	}
+}
+
+func BenchmarkCipherConstantTime(b *testing.B) {
+	var key [KeySize]byte
+	rand.Read(key[:])
+	dst := make([]byte, BlockSize)
+	src := make([]byte, BlockSize)
+	rand.Read(src)
+	c := NewCipherConstantTime(key[:], SboxDefault)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(dst, src)
+	}
+}
@@ -0,0 +1,122 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost28147
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+const (
+	UKMSize        = 8
+	WrapMACSize    = 4
+	WrappedKeySize = UKMSize + KeySize + WrapMACSize
+)
+
+// CryptoPro KEK diversification algorithm (RFC 4357 6.5).
+func DiversifyCryptoPro(kek, ukm []byte, sbox *Sbox) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if len(kek) != KeySize || len(ukm) != UKMSize {
+		return nil, errors.New("invalid KEK or UKM size")
+	}
+	return diversify(kek, ukm, sbox), nil
+}
+
+func diversify(kek, ukm []byte, sbox *Sbox) []byte {
+	key := make([]byte, KeySize)
+	copy(key, kek)
+	s := make([]byte, BlockSize)
+	for i := 0; i < UKMSize; i++ {
+		var s1, s2 uint32
+		for j := uint(0); j < 8; j++ {
+			k := binary.LittleEndian.Uint32(key[4*j:])
+			if ukm[i]&(1<<j) != 0 {
+				s1 += k
+			} else {
+				s2 += k
+			}
+		}
+		binary.LittleEndian.PutUint32(s[:4], s1)
+		binary.LittleEndian.PutUint32(s[4:], s2)
+		c := newCipher(key, sbox)
+		c.NewCFBEncrypter(s).XORKeyStream(key, key)
+		c.Destroy()
+	}
+	return key
+}
+
+// GOST 28147-89 MAC with the IV (RFC 4357's gost28147IMIT): the IV is
+// added to the first block of data.
+func imitWithIV(c *Cipher, iv, data []byte) []byte {
+	first := make([]byte, BlockSize)
+	for i := 0; i < BlockSize; i++ {
+		first[i] = iv[i] ^ data[i]
+	}
+	m, err := c.NewMAC(WrapMACSize, first)
+	if err != nil {
+		panic(err)
+	}
+	m.Write(data[BlockSize:])
+	return m.Sum(nil)
+}
+
+// CryptoPro key wrap algorithm (RFC 4357 6.3). Result is UKM ||
+// encrypted CEK || CEK_MAC.
+func WrapCryptoPro(kek, ukm, cek []byte, sbox *Sbox) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if len(kek) != KeySize || len(ukm) != UKMSize || len(cek) != KeySize {
+		return nil, errors.New("invalid KEK, UKM or CEK size")
+	}
+	key := diversify(kek, ukm, sbox)
+	defer wipe.Bytes(key)
+	c := newCipher(key, sbox)
+	defer c.Destroy()
+	out := make([]byte, UKMSize+KeySize, WrappedKeySize)
+	copy(out, ukm)
+	c.NewECBEncrypter().CryptBlocks(out[UKMSize:], cek)
+	return append(out, imitWithIV(c, ukm, cek)...), nil
+}
+
+// CryptoPro key unwrap algorithm, reverse of WrapCryptoPro.
+func UnwrapCryptoPro(kek, wrapped []byte, sbox *Sbox) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if len(kek) != KeySize || len(wrapped) != WrappedKeySize {
+		return nil, errors.New("invalid wrapped key size")
+	}
+	ukm := wrapped[:UKMSize]
+	key := diversify(kek, ukm, sbox)
+	defer wipe.Bytes(key)
+	c := newCipher(key, sbox)
+	defer c.Destroy()
+	cek := make([]byte, KeySize)
+	c.NewECBDecrypter().CryptBlocks(cek, wrapped[UKMSize:UKMSize+KeySize])
+	if subtle.ConstantTimeCompare(imitWithIV(c, ukm, cek), wrapped[UKMSize+KeySize:]) != 1 {
+		wipe.Bytes(cek)
+		return nil, errors.New("invalid wrapped key MAC")
+	}
+	return cek, nil
+}
@@ -0,0 +1,112 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost28147
+
+import (
+	"bytes"
+	"testing"
+	"testing/quick"
+)
+
+func TestWrapCryptoProSymmetric(t *testing.T) {
+	sbox := &SboxIdGost2814789CryptoProAParamSet
+	f := func(kek, cek [KeySize]byte, ukm [UKMSize]byte) bool {
+		wrapped, err := WrapCryptoPro(kek[:], ukm[:], cek[:], sbox)
+		if err != nil || len(wrapped) != WrappedKeySize ||
+			bytes.Compare(wrapped[:UKMSize], ukm[:]) != 0 ||
+			bytes.Compare(wrapped[UKMSize:UKMSize+KeySize], cek[:]) == 0 {
+			return false
+		}
+		unwrapped, err := UnwrapCryptoPro(kek[:], wrapped, sbox)
+		if err != nil || bytes.Compare(unwrapped, cek[:]) != 0 {
+			return false
+		}
+		wrapped[len(wrapped)-1] ^= 1
+		_, err = UnwrapCryptoPro(kek[:], wrapped, sbox)
+		return err != nil
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWrapCryptoProMAC(t *testing.T) {
+	// MAC of the CEK with UKM as IV equals MAC of UKM||CEK without
+	// the very first encryption
+	kek := make([]byte, KeySize)
+	cek := make([]byte, KeySize)
+	for i := range kek {
+		kek[i] = byte(i)
+		cek[i] = byte(0xFF - i)
+	}
+	zeroUKM := make([]byte, UKMSize)
+	key, err := DiversifyCryptoPro(kek, zeroUKM, &SboxIdtc26gost28147paramZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCipher(key, &SboxIdtc26gost28147paramZ)
+	m, _ := c.NewMAC(WrapMACSize, cek[:BlockSize])
+	m.Write(cek[BlockSize:])
+	wrapped, err := WrapCryptoPro(kek, zeroUKM, cek, &SboxIdtc26gost28147paramZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(wrapped[UKMSize+KeySize:], m.Sum(nil)) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestDiversifyCryptoPro(t *testing.T) {
+	kek := make([]byte, KeySize)
+	for i := range kek {
+		kek[i] = byte(i)
+	}
+	sbox := &SboxIdGost2814789CryptoProAParamSet
+	ukm1 := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	ukm2 := []byte{1, 2, 3, 4, 5, 6, 7, 9}
+	k1, _ := DiversifyCryptoPro(kek, ukm1, sbox)
+	k2, _ := DiversifyCryptoPro(kek, ukm2, sbox)
+	if bytes.Compare(k1, kek) == 0 || bytes.Compare(k1, k2) == 0 ||
+		bytes.Compare(k1, diversify(kek, ukm1, sbox)) != 0 {
+		t.FailNow()
+	}
+	// UKM byte's bit j selects key's word j for the first sum
+	ukm := []byte{0x81, 0, 0, 0, 0, 0, 0, 0}
+	key := make([]byte, KeySize)
+	copy(key, kek)
+	for i := 0; i < UKMSize; i++ {
+		var s1, s2 uint32
+		for j := 0; j < 8; j++ {
+			k := uint32(key[4*j]) | uint32(key[4*j+1])<<8 |
+				uint32(key[4*j+2])<<16 | uint32(key[4*j+3])<<24
+			if i == 0 && (j == 0 || j == 7) {
+				s1 += k
+			} else {
+				s2 += k
+			}
+		}
+		s := make([]byte, BlockSize)
+		for n := uint(0); n < 4; n++ {
+			s[n] = byte(s1 >> (8 * n))
+			s[4+n] = byte(s2 >> (8 * n))
+		}
+		NewCipher(key, sbox).NewCFBEncrypter(s).XORKeyStream(key, key)
+	}
+	if bytes.Compare(diversify(kek, ukm, sbox), key) != 0 {
+		t.FailNow()
+	}
+}
@@ -19,6 +19,8 @@ package gost3410
 import (
 	"errors"
 	"math/big"
+
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 var (
@@ -135,10 +137,18 @@ func (c *Curve) Exp(degree, xS, yS *big.Int) (*big.Int, *big.Int, error) {
 		return nil, nil, errors.New("Bad degree value")
 	}
 	dg := big.NewInt(0).Sub(degree, bigInt1)
+	defer wipe.BigInt(dg)
 	tx := big.NewInt(0).Set(xS)
 	ty := big.NewInt(0).Set(yS)
 	cx := big.NewInt(0).Set(xS)
 	cy := big.NewInt(0).Set(yS)
+	defer func() {
+		wipe.BigInt(cx)
+		wipe.BigInt(cy)
+		wipe.BigInt(c.t)
+		wipe.BigInt(c.tx)
+		wipe.BigInt(c.ty)
+	}()
 	for dg.Cmp(zero) != 0 {
 		if dg.Bit(0) == 1 {
 			c.add(tx, ty, cx, cy)
@@ -0,0 +1,51 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3410
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+// Size of the KEG's output: K_EXP_MAC || K_EXP_ENC.
+const KEGSize = 64
+
+// KEG export keys generation algorithm (R 1323565.1.020). h is 32 bytes
+// long: its first half is the UKM for VKO, the following 8 bytes are the
+// KDF_TREE seed for 256-bit keys. Result is K_EXP_MAC || K_EXP_ENC.
+func (prv *PrivateKey) KEG(pub *PublicKey, h []byte) ([]byte, error) {
+	if len(h) != gost34112012256.Size {
+		return nil, errors.New("invalid hash size")
+	}
+	ukm := NewUKM(h[:16])
+	if ukm.Sign() == 0 {
+		ukm = big.NewInt(1)
+	}
+	if prv.Mode == Mode2012 {
+		return prv.KEK2012512(pub, ukm)
+	}
+	kek, err := prv.KEK2012256(pub, ukm)
+	if err != nil {
+		return nil, err
+	}
+	out := gost34112012256.KDFTree(kek, []byte("kdf tree"), h[16:24], 1, 8*KEGSize)
+	wipe.Bytes(kek)
+	return out, nil
+}
@@ -21,6 +21,9 @@ import (
 	"errors"
 	"io"
 	"math/big"
+
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 type PrivateKey struct {
@@ -30,6 +33,9 @@ type PrivateKey struct {
 }
 
 func NewPrivateKey(curve *Curve, mode Mode, raw []byte) (*PrivateKey, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
 	if len(raw) != int(mode) {
 		return nil, errors.New("Invalid private key length")
 	}
@@ -38,6 +44,7 @@ func NewPrivateKey(curve *Curve, mode Mode, raw []byte) (*PrivateKey, error) {
 		key[i] = raw[len(raw)-i-1]
 	}
 	k := bytes2big(key)
+	wipe.Bytes(key)
 	if k.Cmp(zero) == 0 {
 		return nil, errors.New("Zero private key")
 	}
@@ -46,10 +53,51 @@ func NewPrivateKey(curve *Curve, mode Mode, raw []byte) (*PrivateKey, error) {
 
 func GenPrivateKey(curve *Curve, mode Mode, rand io.Reader) (*PrivateKey, error) {
 	raw := make([]byte, int(mode))
+	defer wipe.Bytes(raw)
 	if _, err := io.ReadFull(rand, raw); err != nil {
 		return nil, err
 	}
-	return NewPrivateKey(curve, mode, raw)
+	prv, err := NewPrivateKey(curve, mode, raw)
+	if err != nil {
+		return nil, err
+	}
+	if strict.Enabled() {
+		if err = prv.pairwiseCheck(rand); err != nil {
+			prv.Destroy()
+			return nil, err
+		}
+	}
+	return prv, nil
+}
+
+// Pairwise consistency test of the generated key: sign random digest
+// and verify the signature with the corresponding public key.
+func (prv *PrivateKey) pairwiseCheck(rand io.Reader) error {
+	digest := make([]byte, int(prv.Mode))
+	if _, err := io.ReadFull(rand, digest); err != nil {
+		return err
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return err
+	}
+	sign, err := prv.SignDigest(digest, rand)
+	if err != nil {
+		return err
+	}
+	valid, err := pub.VerifyDigest(digest, sign)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("Pairwise consistency test failed")
+	}
+	return nil
+}
+
+// Zero the private key. It must not be used after that.
+func (prv *PrivateKey) Destroy() {
+	wipe.BigInt(prv.Key)
 }
 
 func (prv *PrivateKey) Raw() []byte {
@@ -74,15 +122,20 @@ func (prv *PrivateKey) SignDigest(digest []byte, rand io.Reader) ([]byte, error)
 	}
 	kRaw := make([]byte, int(prv.Mode))
 	var err error
-	var k *big.Int
 	var r *big.Int
+	k := big.NewInt(0)
 	d := big.NewInt(0)
 	s := big.NewInt(0)
+	defer func() {
+		wipe.Bytes(kRaw)
+		wipe.BigInt(k)
+		wipe.BigInt(d)
+	}()
 Retry:
 	if _, err = io.ReadFull(rand, kRaw); err != nil {
 		return nil, err
 	}
-	k = bytes2big(kRaw)
+	k.SetBytes(kRaw)
 	k.Mod(k, prv.C.Q)
 	if k.Cmp(zero) == 0 {
 		goto Retry
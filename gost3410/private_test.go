@@ -19,6 +19,7 @@ package gost3410
 import (
 	"crypto"
 	"crypto/rand"
+	"math/big"
 	"testing"
 )
 
@@ -31,3 +32,50 @@ func TestSignerInterface(t *testing.T) {
 	}
 	var _ crypto.Signer = prv
 }
+
+func TestDestroy(t *testing.T) {
+	prv, err := GenPrivateKey(CurveIdGostR34102001TestParamSet(), Mode2001, rand.Reader)
+	if err != nil {
+		t.FailNow()
+	}
+	words := prv.Key.Bits()
+	prv.Destroy()
+	if prv.Key.Sign() != 0 {
+		t.FailNow()
+	}
+	for _, w := range words {
+		if w != 0 {
+			t.FailNow()
+		}
+	}
+}
+
+func TestCurveTemporariesWiped(t *testing.T) {
+	c := CurveIdGostR34102001TestParamSet()
+	prv, err := GenPrivateKey(c, Mode2001, rand.Reader)
+	if err != nil {
+		t.FailNow()
+	}
+	digest := make([]byte, 32)
+	rand.Read(digest)
+	if _, err = prv.SignDigest(digest, rand.Reader); err != nil {
+		t.FailNow()
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.FailNow()
+	}
+	if _, err = prv.KEK2001(pub, NewUKM([]byte{1, 2, 3, 4, 5, 6, 7, 8})); err != nil {
+		t.FailNow()
+	}
+	for _, v := range []*big.Int{c.t, c.tx, c.ty} {
+		if v.Sign() != 0 {
+			t.FailNow()
+		}
+		for _, w := range v.Bits()[:cap(v.Bits())] {
+			if w != 0 {
+				t.FailNow()
+			}
+		}
+	}
+}
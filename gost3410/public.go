@@ -44,6 +44,23 @@ func NewPublicKey(curve *Curve, mode Mode, raw []byte) (*PublicKey, error) {
 	}, nil
 }
 
+// Is the point on the curve: y^2 = x^3 + ax + b (mod p). Points from
+// untrusted sources must be checked before use in key agreement.
+func (pub *PublicKey) OnCurve() bool {
+	c := pub.C
+	if pub.X.Sign() <= 0 || pub.Y.Sign() <= 0 || pub.X.Cmp(c.P) >= 0 || pub.Y.Cmp(c.P) >= 0 {
+		return false
+	}
+	l := new(big.Int).Mul(pub.Y, pub.Y)
+	l.Mod(l, c.P)
+	r := new(big.Int).Mul(pub.X, pub.X)
+	r.Add(r, c.A)
+	r.Mul(r, pub.X)
+	r.Add(r, c.B)
+	r.Mod(r, c.P)
+	return l.Cmp(r) == 0
+}
+
 func (pub *PublicKey) Raw() []byte {
 	raw := append(
 		pad(pub.Y.Bytes(), int(pub.Mode)),
@@ -18,6 +18,8 @@ package gost3410
 
 import (
 	"math/big"
+
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 func (prv *PrivateKey) KEK(pub *PublicKey, ukm *big.Int) ([]byte, error) {
@@ -26,11 +28,17 @@ func (prv *PrivateKey) KEK(pub *PublicKey, ukm *big.Int) ([]byte, error) {
 		return nil, err
 	}
 	if ukm.Cmp(bigInt1) != 0 {
-		keyX, keyY, err = prv.C.Exp(ukm, keyX, keyY)
+		x, y := keyX, keyY
+		keyX, keyY, err = prv.C.Exp(ukm, x, y)
+		wipe.BigInt(x)
+		wipe.BigInt(y)
 		if err != nil {
 			return nil, err
 		}
 	}
 	pk := PublicKey{prv.C, prv.Mode, keyX, keyY}
-	return pk.Raw(), nil
+	raw := pk.Raw()
+	wipe.BigInt(keyX)
+	wipe.BigInt(keyY)
+	return raw, nil
 }
@@ -22,6 +22,7 @@ import (
 
 	"github.com/ddulesov/gogost/gost28147"
 	"github.com/ddulesov/gogost/gost341194"
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 // RFC 4357 VKO GOST R 34.10-2001 key agreement function.
@@ -36,5 +37,8 @@ func (prv *PrivateKey) KEK2001(pub *PublicKey, ukm *big.Int) ([]byte, error) {
 	}
 	h := gost341194.New(&gost28147.SboxIdGostR341194CryptoProParamSet)
 	h.Write(key)
-	return h.Sum(key[:0]), nil
+	kek := h.Sum(nil)
+	h.Reset()
+	wipe.Bytes(key)
+	return kek, nil
 }
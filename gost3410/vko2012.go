@@ -21,6 +21,7 @@ import (
 
 	"github.com/ddulesov/gogost/gost34112012256"
 	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 // RFC 7836 VKO GOST R 34.10-2012 256-bit key agreement function.
@@ -32,7 +33,10 @@ func (prv *PrivateKey) KEK2012256(pub *PublicKey, ukm *big.Int) ([]byte, error)
 	}
 	h := gost34112012256.New()
 	h.Write(key)
-	return h.Sum(key[:0]), nil
+	kek := h.Sum(nil)
+	h.Reset()
+	wipe.Bytes(key)
+	return kek, nil
 }
 
 // RFC 7836 VKO GOST R 34.10-2012 512-bit key agreement function.
@@ -44,5 +48,8 @@ func (prv *PrivateKey) KEK2012512(pub *PublicKey, ukm *big.Int) ([]byte, error)
 	}
 	h := gost34112012512.New()
 	h.Write(key)
-	return h.Sum(key[:0]), nil
+	kek := h.Sum(nil)
+	h.Reset()
+	wipe.Bytes(key)
+	return kek, nil
 }
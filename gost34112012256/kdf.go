@@ -17,26 +17,176 @@
 package gost34112012256
 
 import (
-	"crypto/hmac"
+	"encoding/binary"
 	"hash"
+	"io"
+
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
+// KDF_GOSTR3411_2012_256. HMAC is implemented here explicitly, to be
+// able to zero its key material.
 type KDF struct {
-	h hash.Hash
+	ipad [BlockSize]byte
+	opad [BlockSize]byte
+	h    hash.Hash
 }
 
 func NewKDF(key []byte) *KDF {
-	return &KDF{hmac.New(New, key)}
+	kdf := KDF{h: New()}
+	if len(key) > BlockSize {
+		kdf.h.Write(key)
+		kdf.h.Sum(kdf.ipad[:0])
+		kdf.h.Reset()
+	} else {
+		copy(kdf.ipad[:], key)
+	}
+	copy(kdf.opad[:], kdf.ipad[:])
+	for i := 0; i < BlockSize; i++ {
+		kdf.ipad[i] ^= 0x36
+		kdf.opad[i] ^= 0x5c
+	}
+	return &kdf
+}
+
+// KDF_GOSTR3411_2012_256: single 256-bit block of KDF_TREE with R=1.
+func (kdf *KDF) Derive(dst, label, seed []byte) []byte {
+	return kdf.DeriveTree(dst, label, seed, 1, 8*Size)
 }
 
-func (kdf *KDF) Derive(dst, label, seed []byte) (r []byte) {
-	kdf.h.Write([]byte{0x01})
+// Encoded length of the KDF_TREE's output in bits: [L]_b with minimal
+// number of bytes.
+func treeLength(l int) []byte {
+	var b []byte
+	for ; l > 0; l >>= 8 {
+		b = append([]byte{byte(l)}, b...)
+	}
+	return b
+}
+
+// One block K(i) = HMAC(K, [i]_R || label || 0x00 || seed || [L]_b).
+func (kdf *KDF) block(dst []byte, i uint32, r int, label, seed, length []byte) []byte {
+	var inner [Size]byte
+	var counter [4]byte
+	binary.BigEndian.PutUint32(counter[:], i)
+	kdf.h.Write(kdf.ipad[:])
+	kdf.h.Write(counter[4-r:])
 	kdf.h.Write(label)
 	kdf.h.Write([]byte{0x00})
 	kdf.h.Write(seed)
-	kdf.h.Write([]byte{0x01})
-	kdf.h.Write([]byte{0x00})
-	r = kdf.h.Sum(dst)
+	kdf.h.Write(length)
+	kdf.h.Sum(inner[:0])
 	kdf.h.Reset()
-	return r
+	kdf.h.Write(kdf.opad[:])
+	kdf.h.Write(inner[:])
+	dst = kdf.h.Sum(dst)
+	kdf.h.Reset()
+	wipe.Bytes(inner[:])
+	return dst
+}
+
+func checkTreeParams(r, l int) {
+	if r < 1 || r > 4 {
+		panic("KDF_TREE counter size must be between 1 and 4")
+	}
+	if l <= 0 || l%8 != 0 {
+		panic("KDF_TREE output length must be positive multiple of 8 bits")
+	}
+	if blocks := (l/8 + Size - 1) / Size; r < 4 && blocks >= 1<<uint(8*r) {
+		panic("KDF_TREE output is too long for the counter size")
+	}
+}
+
+// KDF_TREE_GOSTR3411_2012_256 (RFC 7836 section 4.5): derive L bits
+// (multiple of 8) with R-byte (1-4) counter, appending them to dst.
+func (kdf *KDF) DeriveTree(dst, label, seed []byte, r, l int) []byte {
+	checkTreeParams(r, l)
+	length := treeLength(l)
+	size := l / 8
+	var buf [Size]byte
+	for i := uint32(1); size > 0; i++ {
+		kdf.block(buf[:0], i, r, label, seed, length)
+		n := size
+		if n > Size {
+			n = Size
+		}
+		dst = append(dst, buf[:n]...)
+		size -= n
+	}
+	wipe.Bytes(buf[:])
+	return dst
+}
+
+// KDF_TREE_GOSTR3411_2012_256 with L bits of output and R-byte counter.
+func KDFTree(key, label, seed []byte, r, l int) []byte {
+	kdf := NewKDF(key)
+	out := kdf.DeriveTree(make([]byte, 0, l/8), label, seed, r, l)
+	kdf.Destroy()
+	return out
+}
+
+// Streaming KDF_TREE_GOSTR3411_2012_256. Total output length L has to
+// be known in advance, as it is hashed in every block.
+type KDFTreeReader struct {
+	kdf    *KDF
+	label  []byte
+	seed   []byte
+	r      int
+	length []byte
+	left   int
+	i      uint32
+	buf    [Size]byte
+	bufLen int
+}
+
+func NewKDFTreeReader(key, label, seed []byte, r, l int) *KDFTreeReader {
+	checkTreeParams(r, l)
+	return &KDFTreeReader{
+		kdf:    NewKDF(key),
+		label:  append([]byte{}, label...),
+		seed:   append([]byte{}, seed...),
+		r:      r,
+		length: treeLength(l),
+		left:   l / 8,
+	}
+}
+
+// Read the next part of the output. io.EOF is returned after all L bits
+// are read.
+func (kr *KDFTreeReader) Read(p []byte) (n int, err error) {
+	for len(p) > 0 && kr.left > 0 {
+		if kr.bufLen == 0 {
+			kr.i++
+			kr.kdf.block(kr.buf[:0], kr.i, kr.r, kr.label, kr.seed, kr.length)
+			kr.bufLen = Size
+		}
+		c := copy(p, kr.buf[Size-kr.bufLen:])
+		if c > kr.left {
+			c = kr.left
+		}
+		kr.bufLen -= c
+		kr.left -= c
+		n += c
+		p = p[c:]
+	}
+	if kr.left == 0 {
+		wipe.Bytes(kr.buf[:])
+		if n == 0 {
+			err = io.EOF
+		}
+	}
+	return n, err
+}
+
+// Zero the key material. Reader must not be used after that.
+func (kr *KDFTreeReader) Destroy() {
+	kr.kdf.Destroy()
+	wipe.Bytes(kr.buf[:])
+	kr.left = 0
+}
+
+// Zero the key material. KDF must not be used after that.
+func (kdf *KDF) Destroy() {
+	wipe.Bytes(kdf.ipad[:])
+	wipe.Bytes(kdf.opad[:])
 }
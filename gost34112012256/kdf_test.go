@@ -18,6 +18,8 @@ package gost34112012256
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"testing"
 )
 
@@ -42,3 +44,93 @@ func TestKDFGOSTR34112012256(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestKDFDestroy(t *testing.T) {
+	kdf := NewKDF([]byte("key"))
+	kdf.Destroy()
+	if kdf.ipad != [BlockSize]byte{} || kdf.opad != [BlockSize]byte{} {
+		t.FailNow()
+	}
+}
+
+var (
+	kdfTreeKey = []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	kdfTreeLabel    = []byte{0x26, 0xbd, 0xb8, 0x78}
+	kdfTreeSeed     = []byte{0xaf, 0x21, 0x43, 0x41, 0x45, 0x65, 0x63, 0x78}
+	kdfTreeExpected = []byte{
+		0x22, 0xb6, 0x83, 0x78, 0x45, 0xc6, 0xbe, 0xf6,
+		0x5e, 0xa7, 0x16, 0x72, 0xb2, 0x65, 0x83, 0x10,
+		0x86, 0xd3, 0xc7, 0x6a, 0xeb, 0xe6, 0xda, 0xe9,
+		0x1c, 0xad, 0x51, 0xd8, 0x3f, 0x79, 0xd1, 0x6b,
+		0x07, 0x4c, 0x93, 0x30, 0x59, 0x9d, 0x7f, 0x8d,
+		0x71, 0x2f, 0xca, 0x54, 0x39, 0x2f, 0x4d, 0xdd,
+		0xe9, 0x37, 0x51, 0x20, 0x6b, 0x35, 0x84, 0xc8,
+		0xf4, 0x3f, 0x9e, 0x6d, 0xc5, 0x15, 0x31, 0xf9,
+	}
+)
+
+func TestKDFTree(t *testing.T) {
+	if bytes.Compare(
+		KDFTree(kdfTreeKey, kdfTreeLabel, kdfTreeSeed, 1, 512),
+		kdfTreeExpected,
+	) != 0 {
+		t.FailNow()
+	}
+}
+
+// KDF_GOSTR3411_2012_256 is KDF_TREE with R=1 and L=256.
+func TestKDFTreeSingle(t *testing.T) {
+	if bytes.Compare(
+		KDFTree(kdfTreeKey, kdfTreeLabel, kdfTreeSeed, 1, 256),
+		NewKDF(kdfTreeKey).Derive(nil, kdfTreeLabel, kdfTreeSeed),
+	) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestKDFTreeReader(t *testing.T) {
+	kr := NewKDFTreeReader(kdfTreeKey, kdfTreeLabel, kdfTreeSeed, 1, 512)
+	var got []byte
+	buf := make([]byte, 7)
+	for {
+		n, err := kr.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bytes.Compare(got, kdfTreeExpected) != 0 {
+		t.FailNow()
+	}
+	kr.Destroy()
+
+	kr = NewKDFTreeReader(kdfTreeKey, kdfTreeLabel, kdfTreeSeed, 2, 8*100)
+	got, err := ioutil.ReadAll(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, KDFTree(kdfTreeKey, kdfTreeLabel, kdfTreeSeed, 2, 8*100)) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestKDFTreeBadParams(t *testing.T) {
+	for _, p := range [][2]int{{0, 256}, {5, 256}, {1, 0}, {1, 255}, {1, 8 * 32 * 256}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal(p)
+				}
+			}()
+			KDFTree(kdfTreeKey, kdfTreeLabel, kdfTreeSeed, p[0], p[1])
+		}()
+	}
+}
@@ -0,0 +1,35 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost34112012256
+
+import (
+	"crypto/hmac"
+	"hash"
+
+	"github.com/ddulesov/gogost/internal/gost34112012"
+)
+
+// HMAC_GOSTR3411_2012_256 (RFC 7836).
+func NewHMAC(key []byte) hash.Hash {
+	return hmac.New(New, key)
+}
+
+// PRF_TLS_GOSTR3411_2012_256 (RFC 7836): TLS P_hash over
+// HMAC_GOSTR3411_2012_256 of label || seed. Output is size bytes long.
+func PRF(secret, label, seed []byte, size int) []byte {
+	return gost34112012.PRF(New, secret, label, seed, size)
+}
@@ -0,0 +1,73 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost34112012256
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	prfKey = []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	prfLabel = []byte{0x11, 0x22, 0x33, 0x44, 0x55}
+	prfSeed  = []byte{
+		0x18, 0x47, 0x1d, 0x62, 0x2d, 0xc6, 0x55, 0xc4,
+		0xd2, 0xd2, 0x26, 0x96, 0x91, 0xca, 0x4a, 0x56,
+		0x0b, 0x50, 0xab, 0xa6, 0x63, 0x55, 0x3a, 0xf2,
+		0x41, 0xf1, 0xad, 0xa8, 0x82, 0xc9, 0xf2, 0x9a,
+	}
+)
+
+func TestHMAC(t *testing.T) {
+	m := NewHMAC(prfKey)
+	m.Write([]byte{
+		0x01, 0x26, 0xbd, 0xb8, 0x78, 0x00, 0xaf, 0x21,
+		0x43, 0x41, 0x45, 0x65, 0x63, 0x78, 0x01, 0x00,
+	})
+	if bytes.Compare(m.Sum(nil), []byte{
+		0xa1, 0xaa, 0x5f, 0x7d, 0xe4, 0x02, 0xd7, 0xb3,
+		0xd3, 0x23, 0xf2, 0x99, 0x1c, 0x8d, 0x45, 0x34,
+		0x01, 0x31, 0x37, 0x01, 0x0a, 0x83, 0x75, 0x4f,
+		0xd0, 0xaf, 0x6d, 0x7c, 0xd4, 0x92, 0x2e, 0xd9,
+	}) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPRF(t *testing.T) {
+	out := PRF(prfKey, prfLabel, prfSeed, 64)
+	if bytes.Compare(out, []byte{
+		0xff, 0x09, 0x66, 0x4a, 0x44, 0x74, 0x58, 0x65,
+		0x94, 0x4f, 0x83, 0x9e, 0xbb, 0x48, 0x96, 0x5f,
+		0x15, 0x44, 0xff, 0x1c, 0xc8, 0xe8, 0xf1, 0x6f,
+		0x24, 0x7e, 0xe5, 0xf8, 0xa9, 0xeb, 0xe9, 0x7f,
+		0xc4, 0xe3, 0xc7, 0x90, 0x0e, 0x46, 0xca, 0xd3,
+		0xdb, 0x6a, 0x01, 0x64, 0x30, 0x63, 0x04, 0x0e,
+		0xc6, 0x7f, 0xc0, 0xfd, 0x5c, 0xd9, 0xf9, 0x04,
+		0x65, 0x23, 0x52, 0x37, 0xbd, 0xff, 0x2c, 0x02,
+	}) != 0 {
+		t.FailNow()
+	}
+	if bytes.Compare(PRF(prfKey, prfLabel, prfSeed, 10), out[:10]) != 0 {
+		t.FailNow()
+	}
+}
@@ -18,6 +18,8 @@ package gost34112012256
 
 import (
 	"encoding/binary"
+
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 type TLSTreeParams [3]uint64
@@ -55,6 +57,8 @@ var (
 	}
 )
 
+// TLSTREE key derivation (RFC 9189): every level is
+// KDF_GOSTR3411_2012_256 over the previous level's key.
 type TLSTree struct {
 	params     TLSTreeParams
 	keyRoot    []byte
@@ -88,6 +92,9 @@ func (t *TLSTree) DeriveCached(seqNum uint64) ([]byte, bool) {
 	kdf3 := NewKDF(kdf2.Derive(t.key[:0], []byte("level2"), t.seq))
 	binary.BigEndian.PutUint64(t.seq, seqNum&t.params[2])
 	kdf3.Derive(t.key[:0], []byte("level3"), t.seq)
+	kdf1.Destroy()
+	kdf2.Destroy()
+	kdf3.Destroy()
 	t.seqNumPrev = seqNum
 	return t.key, false
 }
@@ -98,3 +105,12 @@ func (t *TLSTree) Derive(seqNum uint64) []byte {
 	copy(keyDerived, key)
 	return keyDerived
 }
+
+// Zero the root and the cached derived keys. TLSTree must not be used
+// after that.
+func (t *TLSTree) Destroy() {
+	wipe.Bytes(t.keyRoot)
+	wipe.Bytes(t.key)
+	wipe.Bytes(t.seq)
+	t.seqNumPrev = 0
+}
@@ -186,3 +186,14 @@ func TestTLSTreeKuznyechikCTROMAC(t *testing.T) {
 		}
 	})
 }
+
+func TestTLSTreeDestroy(t *testing.T) {
+	tt := NewTLSTree(TLSGOSTR341112256WithKuznyechikMGML, make([]byte, 32))
+	tt.Derive(1)
+	tt.Destroy()
+	for _, buf := range [][]byte{tt.keyRoot, tt.key, tt.seq} {
+		if bytes.Compare(buf, make([]byte, len(buf))) != 0 {
+			t.FailNow()
+		}
+	}
+}
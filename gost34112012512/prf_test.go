@@ -0,0 +1,85 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost34112012512
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	prfKey = []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	prfLabel = []byte{0x11, 0x22, 0x33, 0x44, 0x55}
+	prfSeed  = []byte{
+		0x18, 0x47, 0x1d, 0x62, 0x2d, 0xc6, 0x55, 0xc4,
+		0xd2, 0xd2, 0x26, 0x96, 0x91, 0xca, 0x4a, 0x56,
+		0x0b, 0x50, 0xab, 0xa6, 0x63, 0x55, 0x3a, 0xf2,
+		0x41, 0xf1, 0xad, 0xa8, 0x82, 0xc9, 0xf2, 0x9a,
+	}
+)
+
+func TestHMAC(t *testing.T) {
+	m := NewHMAC(prfKey)
+	m.Write([]byte{
+		0x01, 0x26, 0xbd, 0xb8, 0x78, 0x00, 0xaf, 0x21,
+		0x43, 0x41, 0x45, 0x65, 0x63, 0x78, 0x01, 0x00,
+	})
+	if bytes.Compare(m.Sum(nil), []byte{
+		0xa5, 0x9b, 0xab, 0x22, 0xec, 0xae, 0x19, 0xc6,
+		0x5f, 0xbd, 0xe6, 0xe5, 0xf4, 0xe9, 0xf5, 0xd8,
+		0x54, 0x9d, 0x31, 0xf0, 0x37, 0xf9, 0xdf, 0x9b,
+		0x90, 0x55, 0x00, 0xe1, 0x71, 0x92, 0x3a, 0x77,
+		0x3d, 0x5f, 0x15, 0x30, 0xf2, 0xed, 0x7e, 0x96,
+		0x4c, 0xb2, 0xee, 0xdc, 0x29, 0xe9, 0xad, 0x2f,
+		0x3a, 0xfe, 0x93, 0xb2, 0x81, 0x4f, 0x79, 0xf5,
+		0x00, 0x0f, 0xfc, 0x03, 0x66, 0xc2, 0x51, 0xe6,
+	}) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPRF(t *testing.T) {
+	out := PRF(prfKey, prfLabel, prfSeed, 128)
+	if bytes.Compare(out, []byte{
+		0xf3, 0x51, 0x87, 0xa3, 0xdc, 0x96, 0x55, 0x11,
+		0x3a, 0x0e, 0x84, 0xd0, 0x6f, 0xd7, 0x52, 0x6c,
+		0x5f, 0xc1, 0xfb, 0xde, 0xc1, 0xa0, 0xe4, 0x67,
+		0x3d, 0xd6, 0xd7, 0x9d, 0x0b, 0x92, 0x0e, 0x65,
+		0xad, 0x1b, 0xc4, 0x7b, 0xb0, 0x83, 0xb3, 0x85,
+		0x1c, 0xb7, 0xcd, 0x8e, 0x7e, 0x6a, 0x91, 0x1a,
+		0x62, 0x6c, 0xf0, 0x2b, 0x29, 0xe9, 0xe4, 0xa5,
+		0x8e, 0xd7, 0x66, 0xa4, 0x49, 0xa7, 0x29, 0x6d,
+		0xe6, 0x1a, 0x7a, 0x26, 0xc4, 0xd1, 0xca, 0xee,
+		0xcf, 0xd8, 0x0c, 0xca, 0x65, 0xc7, 0x1f, 0x0f,
+		0x88, 0xc1, 0xf8, 0x22, 0xc0, 0xe8, 0xc0, 0xad,
+		0x94, 0x9d, 0x03, 0xfe, 0xe1, 0x39, 0x57, 0x9f,
+		0x72, 0xba, 0x0c, 0x3d, 0x32, 0xc5, 0xf9, 0x54,
+		0xf1, 0xcc, 0xcd, 0x54, 0x08, 0x1f, 0xc7, 0x44,
+		0x02, 0x78, 0xcb, 0xa1, 0xfe, 0x7b, 0x7a, 0x17,
+		0xa9, 0x86, 0xfd, 0xff, 0x5b, 0xd1, 0x5d, 0x1f,
+	}) != 0 {
+		t.FailNow()
+	}
+	if bytes.Compare(PRF(prfKey, prfLabel, prfSeed, 10), out[:10]) != 0 {
+		t.FailNow()
+	}
+}
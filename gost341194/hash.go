@@ -19,49 +19,41 @@
 package gost341194
 
 import (
+	"bytes"
 	"encoding/binary"
-	"math/big"
+	"errors"
 
 	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 const (
 	BlockSize = 32
 	Size      = 32
+
+	MarshaledName = "GOST341194"
 )
 
 var (
 	SboxDefault *gost28147.Sbox = &gost28147.SboxIdGostR341194TestParamSet
 
-	c2 [BlockSize]byte = [BlockSize]byte{
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	}
+	// C2 and C4 constants are zero
 	c3 [BlockSize]byte = [BlockSize]byte{
 		0xff, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff,
 		0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0xff, 0x00,
 		0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff,
 		0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00,
 	}
-	c4 [BlockSize]byte = [BlockSize]byte{
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	}
-
-	big256 *big.Int = big.NewInt(0).SetBit(big.NewInt(0), 256, 1)
 )
 
 type Hash struct {
-	sbox *gost28147.Sbox
-	size uint64
-	hsh  [BlockSize]byte
-	chk  *big.Int
-	buf  []byte
-	tmp  [BlockSize]byte
+	sbox   *gost28147.Sbox
+	size   uint64
+	hsh    [BlockSize]byte
+	chk    [4]uint64
+	buf    [BlockSize]byte
+	bufLen int
 }
 
 func New(sbox *gost28147.Sbox) *Hash {
@@ -72,14 +64,10 @@ func New(sbox *gost28147.Sbox) *Hash {
 
 func (h *Hash) Reset() {
 	h.size = 0
-	h.hsh = [BlockSize]byte{
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	}
-	h.chk = big.NewInt(0)
-	h.buf = h.buf[:0]
+	h.hsh = [BlockSize]byte{}
+	h.chk = [4]uint64{}
+	h.bufLen = 0
+	wipe.Bytes(h.buf[:])
 }
 
 func (h *Hash) BlockSize() int {
@@ -90,36 +78,30 @@ func (h *Hash) Size() int {
 	return BlockSize
 }
 
-func fA(in *[BlockSize]byte) *[BlockSize]byte {
-	out := new([BlockSize]byte)
-	out[0] = in[16+0] ^ in[24+0]
-	out[1] = in[16+1] ^ in[24+1]
-	out[2] = in[16+2] ^ in[24+2]
-	out[3] = in[16+3] ^ in[24+3]
-	out[4] = in[16+4] ^ in[24+4]
-	out[5] = in[16+5] ^ in[24+5]
-	out[6] = in[16+6] ^ in[24+6]
-	out[7] = in[16+7] ^ in[24+7]
-	copy(out[8:], in[0:24])
-	return out
+func fA(in *[BlockSize]byte) {
+	var t [8]byte
+	for i := 0; i < 8; i++ {
+		t[i] = in[16+i] ^ in[24+i]
+	}
+	copy(in[8:], in[0:24])
+	copy(in[:8], t[:])
 }
 
-func fP(in *[BlockSize]byte) *[BlockSize]byte {
-	return &[BlockSize]byte{
-		in[0], in[8], in[16], in[24], in[1], in[9], in[17],
-		in[25], in[2], in[10], in[18], in[26], in[3],
-		in[11], in[19], in[27], in[4], in[12], in[20],
-		in[28], in[5], in[13], in[21], in[29], in[6],
-		in[14], in[22], in[30], in[7], in[15], in[23], in[31],
+func fP(out, in *[BlockSize]byte) {
+	for i := 0; i < 8; i++ {
+		out[4*i+0] = in[i]
+		out[4*i+1] = in[8+i]
+		out[4*i+2] = in[16+i]
+		out[4*i+3] = in[24+i]
 	}
 }
 
-func fChi(in *[BlockSize]byte) *[BlockSize]byte {
-	out := new([BlockSize]byte)
-	out[0] = in[32-2] ^ in[32-4] ^ in[32-6] ^ in[32-8] ^ in[32-32] ^ in[32-26]
-	out[1] = in[32-1] ^ in[32-3] ^ in[32-5] ^ in[32-7] ^ in[32-31] ^ in[32-25]
-	copy(out[2:32], in[0:30])
-	return out
+func fChi(in *[BlockSize]byte) {
+	t0 := in[32-2] ^ in[32-4] ^ in[32-6] ^ in[32-8] ^ in[32-32] ^ in[32-26]
+	t1 := in[32-1] ^ in[32-3] ^ in[32-5] ^ in[32-7] ^ in[32-31] ^ in[32-25]
+	copy(in[2:32], in[0:30])
+	in[0] = t0
+	in[1] = t1
 }
 
 func blockReverse(dst, src []byte) {
@@ -135,136 +117,189 @@ func blockXor(dst, a, b *[BlockSize]byte) {
 	return
 }
 
-func (h *Hash) step(hin, m [BlockSize]byte) [BlockSize]byte {
-	out := new([BlockSize]byte)
-	u := new([BlockSize]byte)
-	v := new([BlockSize]byte)
-	k := new([BlockSize]byte)
-	(*u) = hin
-	(*v) = m
-	blockXor(k, u, v)
-	k = fP(k)
-	blockReverse(k[:], k[:])
-	c := gost28147.NewCipher(k[:], h.sbox)
-	s := make([]byte, gost28147.BlockSize)
-	c.Encrypt(s, []byte{
-		hin[31], hin[30], hin[29], hin[28], hin[27], hin[26], hin[25], hin[24],
-	})
-	out[31] = s[0]
-	out[30] = s[1]
-	out[29] = s[2]
-	out[28] = s[3]
-	out[27] = s[4]
-	out[26] = s[5]
-	out[25] = s[6]
-	out[24] = s[7]
-
-	blockXor(u, fA(u), &c2)
-	v = fA(fA(v))
-	blockXor(k, u, v)
-	k = fP(k)
-	blockReverse(k[:], k[:])
-	c = gost28147.NewCipher(k[:], h.sbox)
-	c.Encrypt(s, []byte{
-		hin[23], hin[22], hin[21], hin[20], hin[19], hin[18], hin[17], hin[16],
-	})
-	out[23] = s[0]
-	out[22] = s[1]
-	out[21] = s[2]
-	out[20] = s[3]
-	out[19] = s[4]
-	out[18] = s[5]
-	out[17] = s[6]
-	out[16] = s[7]
-
-	blockXor(u, fA(u), &c3)
-	v = fA(fA(v))
-	blockXor(k, u, v)
-	k = fP(k)
-	blockReverse(k[:], k[:])
-	c = gost28147.NewCipher(k[:], h.sbox)
-	c.Encrypt(s, []byte{
-		hin[15], hin[14], hin[13], hin[12], hin[11], hin[10], hin[9], hin[8],
-	})
-	out[15] = s[0]
-	out[14] = s[1]
-	out[13] = s[2]
-	out[12] = s[3]
-	out[11] = s[4]
-	out[10] = s[5]
-	out[9] = s[6]
-	out[8] = s[7]
-
-	blockXor(u, fA(u), &c4)
-	v = fA(fA(v))
-	blockXor(k, u, v)
-	k = fP(k)
-	blockReverse(k[:], k[:])
-	c = gost28147.NewCipher(k[:], h.sbox)
-	c.Encrypt(s, []byte{
-		hin[7], hin[6], hin[5], hin[4], hin[3], hin[2], hin[1], hin[0],
-	})
-	out[7] = s[0]
-	out[6] = s[1]
-	out[5] = s[2]
-	out[4] = s[3]
-	out[3] = s[4]
-	out[2] = s[5]
-	out[1] = s[6]
-	out[0] = s[7]
-
+// Step hash function. hsh is updated in place.
+func (h *Hash) step(hsh, m *[BlockSize]byte) {
+	var out, u, v, w, k [BlockSize]byte
+	var s [gost28147.BlockSize]byte
+	var c *gost28147.Cipher
+	var off, j int
+	u = *hsh
+	v = *m
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			fA(&u)
+			if i == 2 {
+				blockXor(&u, &u, &c3)
+			}
+			fA(&v)
+			fA(&v)
+		}
+		blockXor(&w, &u, &v)
+		fP(&k, &w)
+		blockReverse(k[:], k[:])
+		c = strict.NewGost28147(k[:], h.sbox).(*gost28147.Cipher)
+		off = BlockSize - gost28147.BlockSize*(i+1)
+		for j = 0; j < gost28147.BlockSize; j++ {
+			s[j] = hsh[off+gost28147.BlockSize-1-j]
+		}
+		c.Encrypt(s[:], s[:])
+		c.Destroy()
+		for j = 0; j < gost28147.BlockSize; j++ {
+			out[off+gost28147.BlockSize-1-j] = s[j]
+		}
+	}
 	for i := 0; i < 12; i++ {
-		out = fChi(out)
+		fChi(&out)
 	}
-	blockXor(out, out, &m)
-	out = fChi(out)
-	blockXor(out, out, &hin)
+	blockXor(&out, &out, m)
+	fChi(&out)
+	blockXor(&out, &out, hsh)
 	for i := 0; i < 61; i++ {
-		out = fChi(out)
+		fChi(&out)
 	}
-	return *out
+	*hsh = out
+	wipe.Bytes(out[:])
+	wipe.Bytes(u[:])
+	wipe.Bytes(v[:])
+	wipe.Bytes(w[:])
+	wipe.Bytes(k[:])
+	wipe.Bytes(s[:])
 }
 
-func (h *Hash) chkAdd(data []byte) *big.Int {
-	i := big.NewInt(0).SetBytes(data)
-	i.Add(i, h.chk)
-	if i.Cmp(big256) != -1 {
-		i.Sub(i, big256)
+// Add block to the checksum modulo 2^256. Block is treated as a
+// little-endian number.
+func chkAdd(chk *[4]uint64, data []byte) {
+	var carry, s, d uint64
+	for i := 0; i < 4; i++ {
+		d = binary.LittleEndian.Uint64(data[i*8 : i*8+8])
+		s = chk[i] + carry
+		if s < carry {
+			carry = 1
+		} else {
+			carry = 0
+		}
+		s += d
+		if s < d {
+			carry = 1
+		}
+		chk[i] = s
 	}
-	return i
+}
+
+func (h *Hash) compress(data []byte) {
+	var block [BlockSize]byte
+	h.size += BlockSize * 8
+	chkAdd(&h.chk, data)
+	blockReverse(block[:], data)
+	h.step(&h.hsh, &block)
+	wipe.Bytes(block[:])
 }
 
 func (h *Hash) Write(data []byte) (int, error) {
-	h.buf = append(h.buf, data...)
-	for len(h.buf) >= BlockSize {
-		h.size += BlockSize * 8
-		blockReverse(h.tmp[:], h.buf[:BlockSize])
-		h.chk = h.chkAdd(h.tmp[:])
-		h.buf = h.buf[BlockSize:]
-		h.hsh = h.step(h.hsh, h.tmp)
+	written := len(data)
+	if h.bufLen > 0 {
+		n := copy(h.buf[h.bufLen:], data)
+		h.bufLen += n
+		data = data[n:]
+		if h.bufLen < BlockSize {
+			return written, nil
+		}
+		h.compress(h.buf[:])
+		h.bufLen = 0
+	}
+	for len(data) >= BlockSize {
+		h.compress(data[:BlockSize])
+		data = data[BlockSize:]
 	}
-	return len(data), nil
+	h.bufLen = copy(h.buf[:], data)
+	return written, nil
 }
 
 func (h *Hash) Sum(in []byte) []byte {
 	size := h.size
 	chk := h.chk
 	hsh := h.hsh
-	block := new([BlockSize]byte)
-	if len(h.buf) != 0 {
-		size += uint64(len(h.buf)) * 8
-		copy(block[:], h.buf)
+	var block [BlockSize]byte
+	if h.bufLen != 0 {
+		size += uint64(h.bufLen) * 8
+		copy(block[:], h.buf[:h.bufLen])
+		chkAdd(&chk, block[:])
 		blockReverse(block[:], block[:])
-		chk = h.chkAdd(block[:])
-		hsh = h.step(hsh, *block)
-		block = new([BlockSize]byte)
+		h.step(&hsh, &block)
+		block = [BlockSize]byte{}
 	}
 	binary.BigEndian.PutUint64(block[24:], size)
-	hsh = h.step(hsh, *block)
-	block = new([BlockSize]byte)
-	chkBytes := chk.Bytes()
-	copy(block[BlockSize-len(chkBytes):], chkBytes)
-	hsh = h.step(hsh, *block)
+	h.step(&hsh, &block)
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(block[BlockSize-8*(i+1):], chk[i])
+	}
+	h.step(&hsh, &block)
 	blockReverse(hsh[:], hsh[:])
-	return append(in, hsh[:]...)
+	in = append(in, hsh[:]...)
+	wipe.Bytes(hsh[:])
+	wipe.Bytes(block[:])
+	wipe.Uint64s(chk[:])
+	return in
+}
+
+// Sbox packed two substitution values per byte. It is stored together
+// with the marshaled state to prevent its continuation with another
+// Sbox.
+func packSbox(sbox *gost28147.Sbox) []byte {
+	packed := make([]byte, 0, 8*16/2)
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 16; j += 2 {
+			packed = append(packed, sbox[i][j]<<4|sbox[i][j+1])
+		}
+	}
+	return packed
+}
+
+func (h *Hash) MarshalBinary() (data []byte, err error) {
+	sbox := packSbox(h.sbox)
+	data = make([]byte, len(MarshaledName)+8+2*BlockSize+len(sbox)+h.bufLen)
+	copy(data, []byte(MarshaledName))
+	idx := len(MarshaledName)
+	binary.BigEndian.PutUint64(data[idx:idx+8], h.size)
+	idx += 8
+	copy(data[idx:], h.hsh[:])
+	idx += BlockSize
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(data[idx+8*i:], h.chk[i])
+	}
+	idx += BlockSize
+	copy(data[idx:], sbox)
+	idx += len(sbox)
+	copy(data[idx:], h.buf[:h.bufLen])
+	return
+}
+
+func (h *Hash) UnmarshalBinary(data []byte) error {
+	sbox := packSbox(h.sbox)
+	minSize := len(MarshaledName) + 8 + 2*BlockSize + len(sbox)
+	if len(data) < minSize {
+		return errors.New("too short data")
+	}
+	if len(data) >= minSize+BlockSize {
+		return errors.New("too long data")
+	}
+	if !bytes.HasPrefix(data, []byte(MarshaledName)) {
+		return errors.New("no hash name prefix")
+	}
+	idx := len(MarshaledName)
+	size := binary.BigEndian.Uint64(data[idx : idx+8])
+	idx += 8
+	if bytes.Compare(data[idx+2*BlockSize:idx+2*BlockSize+len(sbox)], sbox) != 0 {
+		return errors.New("different Sbox")
+	}
+	h.size = size
+	copy(h.hsh[:], data[idx:])
+	idx += BlockSize
+	for i := 0; i < 4; i++ {
+		h.chk[i] = binary.LittleEndian.Uint64(data[idx+8*i:])
+	}
+	idx += BlockSize
+	idx += len(sbox)
+	h.bufLen = copy(h.buf[:], data[idx:])
+	return nil
 }
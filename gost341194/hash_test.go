@@ -19,6 +19,7 @@ package gost341194
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding"
 	"hash"
 	"testing"
 	"testing/quick"
@@ -29,6 +30,8 @@ import (
 func TestHashInterface(t *testing.T) {
 	h := New(SboxDefault)
 	var _ hash.Hash = h
+	var _ encoding.BinaryMarshaler = h
+	var _ encoding.BinaryUnmarshaler = h
 }
 
 func TestVectors(t *testing.T) {
@@ -271,13 +274,39 @@ func TestRandom(t *testing.T) {
 			h.Write([]byte{c})
 		}
 		d2 := h.Sum(nil)
-		return bytes.Compare(d1, d2) == 0
+		if bytes.Compare(d1, d2) != 0 {
+			return false
+		}
+		h.Reset()
+		h.Write(data[:len(data)/2])
+		raw, err := h.MarshalBinary()
+		if err != nil {
+			return false
+		}
+		hNew := New(SboxDefault)
+		if err = hNew.UnmarshalBinary(raw); err != nil {
+			return false
+		}
+		hNew.Write(data[len(data)/2:])
+		return bytes.Compare(hNew.Sum(nil), d1) == 0
 	}
 	if err := quick.Check(f, nil); err != nil {
 		t.Error(err)
 	}
 }
 
+func TestUnmarshalDifferentSbox(t *testing.T) {
+	h := New(SboxDefault)
+	h.Write([]byte("foobar"))
+	raw, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = New(&gost28147.SboxIdGostR341194CryptoProParamSet).UnmarshalBinary(raw); err == nil {
+		t.FailNow()
+	}
+}
+
 func BenchmarkHash(b *testing.B) {
 	h := New(SboxDefault)
 	src := make([]byte, BlockSize+1)
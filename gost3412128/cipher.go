@@ -17,6 +17,13 @@
 // GOST 34.12-2015 128-bit (Кузнечик (Kuznechik)) block cipher.
 package gost3412128
 
+import (
+	"crypto/cipher"
+
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
 const (
 	BlockSize = 16
 	KeySize   = 32
@@ -103,6 +110,8 @@ func init() {
 		piInvP[int(pi[i])] = byte(i)
 	}
 	piInv = *piInvP
+	piANF = anf(&pi)
+	piInvANF = anf(&piInv)
 	CP := new([32]*[BlockSize]byte)
 	for i := 0; i < 32; i++ {
 		CP[i] = new([BlockSize]byte)
@@ -110,6 +119,9 @@ func init() {
 		l(CP[i], 16)
 	}
 	cBlk = *CP
+	strict.NewKuznyechik = func(key []byte) cipher.Block {
+		return newCipher(key, false)
+	}
 }
 
 func s(blk *[BlockSize]byte) {
@@ -126,6 +138,7 @@ func xor(dst, src1, src2 *[BlockSize]byte) {
 
 type Cipher struct {
 	ks [10]*[BlockSize]byte
+	ct bool
 }
 
 func (c *Cipher) BlockSize() int {
@@ -133,6 +146,11 @@ func (c *Cipher) BlockSize() int {
 }
 
 func NewCipher(key []byte) *Cipher {
+	strict.Check()
+	return newCipher(key, false)
+}
+
+func newCipher(key []byte, ct bool) *Cipher {
 	if len(key) != KeySize {
 		panic("invalid key size")
 	}
@@ -149,8 +167,13 @@ func NewCipher(key []byte) *Cipher {
 	for i := 0; i < 4; i++ {
 		for j := 0; j < 8; j++ {
 			xor(krt, kr0, cBlk[8*i+j])
-			s(krt)
-			l(krt, 16)
+			if ct {
+				sSliced(krt, &piANF)
+				lConstantTime(krt, 16)
+			} else {
+				s(krt)
+				l(krt, 16)
+			}
 			xor(krt, krt, kr1)
 			copy(kr1[:], kr0[:])
 			copy(kr0[:], krt[:])
@@ -160,10 +183,24 @@ func NewCipher(key []byte) *Cipher {
 		ks[2+2*i+1] = new([BlockSize]byte)
 		copy(ks[2+2*i+1][:], kr1[:])
 	}
-	return &Cipher{*ks}
+	wipe.Bytes(kr0[:])
+	wipe.Bytes(kr1[:])
+	wipe.Bytes(krt[:])
+	return &Cipher{*ks, ct}
+}
+
+// Zero the round keys. Cipher must not be used after that.
+func (c *Cipher) Destroy() {
+	for _, k := range c.ks {
+		wipe.Bytes(k[:])
+	}
 }
 
 func (c *Cipher) Encrypt(dst, src []byte) {
+	if c.ct {
+		c.encryptConstantTime(dst, src)
+		return
+	}
 	blk := new([BlockSize]byte)
 	copy(blk[:], src)
 	for i := 0; i < 9; i++ {
@@ -176,6 +213,10 @@ func (c *Cipher) Encrypt(dst, src []byte) {
 }
 
 func (c *Cipher) Decrypt(dst, src []byte) {
+	if c.ct {
+		c.decryptConstantTime(dst, src)
+		return
+	}
 	blk := new([BlockSize]byte)
 	copy(blk[:], src)
 	var n int
@@ -323,3 +323,13 @@ func TestVectorDecrypt(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestDestroy(t *testing.T) {
+	c := NewCipher(key)
+	c.Destroy()
+	for _, k := range c.ks {
+		if *k != [BlockSize]byte{} {
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3412128
+
+// Bitsliced constant-time implementation. Block is transposed into
+// eight 16-bit planes: bit i of the plane j is the bit j of the byte
+// i. S-boxes are evaluated through the algebraic normal form of their
+// output bits, linear transformation uses branchless GF(2^8)
+// multiplication, so neither memory accesses nor branches depend on
+// the key and the data.
+
+import "github.com/ddulesov/gogost/internal/strict"
+
+var (
+	piANF    [8][]uint8 // It is filled in init()
+	piInvANF [8][]uint8 // It is filled in init()
+)
+
+// Monomials of the algebraic normal form of each output bit of the
+// S-box. Monomial is identified by the mask of its input bits.
+func anf(sbox *[256]byte) (r [8][]uint8) {
+	var f [256]byte
+	var j uint
+	var m, x int
+	for j = 0; j < 8; j++ {
+		for x = 0; x < 256; x++ {
+			f[x] = (sbox[x] >> j) & 1
+		}
+		// Moebius transform of the truth table
+		for m = 1; m < 256; m <<= 1 {
+			for x = 0; x < 256; x++ {
+				if x&m != 0 {
+					f[x] ^= f[x^m]
+				}
+			}
+		}
+		for m = 0; m < 256; m++ {
+			if f[m] == 1 {
+				r[j] = append(r[j], uint8(m))
+			}
+		}
+	}
+	return
+}
+
+type planes [8]uint16
+
+func bitslice(p *planes, blk *[BlockSize]byte) {
+	var i, j uint
+	for j = 0; j < 8; j++ {
+		p[j] = 0
+		for i = 0; i < BlockSize; i++ {
+			p[j] |= uint16((blk[i]>>j)&1) << i
+		}
+	}
+}
+
+func unbitslice(blk *[BlockSize]byte, p *planes) {
+	var i, j uint
+	for i = 0; i < BlockSize; i++ {
+		blk[i] = 0
+		for j = 0; j < 8; j++ {
+			blk[i] |= byte((p[j]>>i)&1) << j
+		}
+	}
+}
+
+// Bitsliced substitution of all block's bytes at once.
+func sSliced(blk *[BlockSize]byte, monomials *[8][]uint8) {
+	var p planes
+	var mono [256]uint16
+	bitslice(&p, blk)
+	mono[0] = 0xFFFF
+	var j, m uint
+	for j = 0; j < 8; j++ {
+		for m = 1 << j; m < 2<<j; m++ {
+			mono[m] = mono[m^(1<<j)] & p[j]
+		}
+	}
+	var o planes
+	for j = 0; j < 8; j++ {
+		for _, m := range monomials[j] {
+			o[j] ^= mono[m]
+		}
+	}
+	unbitslice(blk, &o)
+}
+
+func gfConstantTime(a, b byte) (c byte) {
+	for i := 0; i < 8; i++ {
+		c ^= a & -(b & 1)
+		a = (a << 1) ^ (0xC3 & -(a >> 7))
+		b >>= 1
+	}
+	return
+}
+
+func lConstantTime(blk *[BlockSize]byte, rounds int) {
+	var t byte
+	var i int
+	for ; rounds > 0; rounds-- {
+		t = blk[15]
+		for i = 14; i >= 0; i-- {
+			blk[i+1] = blk[i]
+			t ^= gfConstantTime(blk[i], lc[i])
+		}
+		blk[0] = t
+	}
+}
+
+func lInvConstantTime(blk *[BlockSize]byte) {
+	var t byte
+	var i int
+	for n := 0; n < BlockSize; n++ {
+		t = blk[0]
+		for i = 0; i < 15; i++ {
+			blk[i] = blk[i+1]
+			t ^= gfConstantTime(blk[i], lc[i])
+		}
+		blk[15] = t
+	}
+}
+
+// Create cipher using bitsliced constant-time implementation. It is
+// considerably slower, but does not leak key and data through the
+// cache timings.
+func NewCipherConstantTime(key []byte) *Cipher {
+	strict.Check()
+	return newCipher(key, true)
+}
+
+func (c *Cipher) encryptConstantTime(dst, src []byte) {
+	blk := new([BlockSize]byte)
+	copy(blk[:], src)
+	for i := 0; i < 9; i++ {
+		xor(blk, blk, c.ks[i])
+		sSliced(blk, &piANF)
+		lConstantTime(blk, 16)
+	}
+	xor(blk, blk, c.ks[9])
+	copy(dst[:BlockSize], blk[:])
+}
+
+func (c *Cipher) decryptConstantTime(dst, src []byte) {
+	blk := new([BlockSize]byte)
+	copy(blk[:], src)
+	for i := 9; i > 0; i-- {
+		xor(blk, blk, c.ks[i])
+		lInvConstantTime(blk)
+		sSliced(blk, &piInvANF)
+	}
+	xor(blk, blk, c.ks[0])
+	copy(dst[:BlockSize], blk[:])
+}
@@ -0,0 +1,125 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3412128
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"testing"
+	"testing/quick"
+)
+
+func TestCipherConstantTimeInterface(t *testing.T) {
+	var _ cipher.Block = NewCipherConstantTime(make([]byte, KeySize))
+}
+
+func TestSSliced(t *testing.T) {
+	f := func(blk [BlockSize]byte) bool {
+		blkSliced := blk
+		s(&blk)
+		sSliced(&blkSliced, &piANF)
+		if blk != blkSliced {
+			return false
+		}
+		sSliced(&blkSliced, &piInvANF)
+		for i := 0; i < BlockSize; i++ {
+			blk[i] = piInv[int(blk[i])]
+		}
+		return blk == blkSliced
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLConstantTime(t *testing.T) {
+	f := func(blk [BlockSize]byte) bool {
+		blkCT := blk
+		l(&blk, 16)
+		lConstantTime(&blkCT, 16)
+		if blk != blkCT {
+			return false
+		}
+		lInv(&blk)
+		lInvConstantTime(&blkCT)
+		return blk == blkCT
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoundKeysConstantTime(t *testing.T) {
+	c := NewCipher(key)
+	cct := NewCipherConstantTime(key)
+	for i := 0; i < len(c.ks); i++ {
+		if *c.ks[i] != *cct.ks[i] {
+			t.FailNow()
+		}
+	}
+}
+
+func TestVectorConstantTime(t *testing.T) {
+	c := NewCipherConstantTime(key)
+	dst := make([]byte, BlockSize)
+	c.Encrypt(dst, pt[:])
+	if bytes.Compare(dst, ct[:]) != 0 {
+		t.FailNow()
+	}
+	c.Decrypt(dst, ct[:])
+	if bytes.Compare(dst, pt[:]) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestRandomConstantTime(t *testing.T) {
+	data := make([]byte, BlockSize)
+	f := func(key [KeySize]byte, pt [BlockSize]byte) bool {
+		io.ReadFull(rand.Reader, key[:])
+		c := NewCipherConstantTime(key[:])
+		c.Encrypt(data, pt[:])
+		c.Decrypt(data, data)
+		return bytes.Compare(data, pt[:]) == 0
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkEncryptConstantTime(b *testing.B) {
+	key := make([]byte, KeySize)
+	io.ReadFull(rand.Reader, key)
+	c := NewCipherConstantTime(key)
+	blk := make([]byte, BlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(blk, blk)
+	}
+}
+
+func BenchmarkDecryptConstantTime(b *testing.B) {
+	key := make([]byte, KeySize)
+	io.ReadFull(rand.Reader, key)
+	c := NewCipherConstantTime(key)
+	blk := make([]byte, BlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Decrypt(blk, blk)
+	}
+}
@@ -18,7 +18,12 @@
 package gost341264
 
 import (
+	"crypto/cipher"
+	"encoding/binary"
+
 	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 const (
@@ -26,12 +31,60 @@ const (
 	KeySize   = 32
 )
 
+// Combined substitution and 11-bit rotation tables. Each of them
+// substitutes a single byte of the 32-bit half with two Sboxes and
+// places the result already rotated. It is filled in init().
+var sbox [4][256]uint32
+
+func init() {
+	strict.NewMagma = func(key []byte) cipher.Block {
+		return newCipher(key)
+	}
+	s := &gost28147.SboxIdtc26gost28147paramZ
+	var i, b uint32
+	var n uint32
+	for i = 0; i < 4; i++ {
+		for b = 0; b < 256; b++ {
+			n = uint32(s[2*i+1][b>>4])<<4 | uint32(s[2*i][b&0x0F])
+			n <<= 8 * i
+			sbox[i][b] = n<<11 | n>>(32-11)
+		}
+	}
+}
+
+func g(n uint32) uint32 {
+	return sbox[0][n&0xFF] ^
+		sbox[1][(n>>8)&0xFF] ^
+		sbox[2][(n>>16)&0xFF] ^
+		sbox[3][n>>24]
+}
+
 type Cipher struct {
-	c   *gost28147.Cipher
-	blk *[BlockSize]byte
+	rk [32]uint32
+	ct *gost28147.Cipher
 }
 
 func NewCipher(key []byte) *Cipher {
+	strict.Check()
+	return newCipher(key)
+}
+
+func newCipher(key []byte) *Cipher {
+	if len(key) != KeySize {
+		panic("invalid key size")
+	}
+	c := Cipher{}
+	for i := 0; i < 24; i++ {
+		c.rk[i] = binary.BigEndian.Uint32(key[4*(i%8):])
+	}
+	for i := 24; i < 32; i++ {
+		c.rk[i] = binary.BigEndian.Uint32(key[4*(31-i):])
+	}
+	return &c
+}
+
+// Create cipher using bitsliced constant-time Sbox substitution.
+func NewCipherConstantTime(key []byte) *Cipher {
 	if len(key) != KeySize {
 		panic("invalid key size")
 	}
@@ -42,9 +95,19 @@ func NewCipher(key []byte) *Cipher {
 		keyCompatible[i*4+2] = key[i*4+1]
 		keyCompatible[i*4+3] = key[i*4+0]
 	}
-	return &Cipher{
-		c:   gost28147.NewCipher(keyCompatible, &gost28147.SboxIdtc26gost28147paramZ),
-		blk: new([BlockSize]byte),
+	c := Cipher{ct: gost28147.NewCipherConstantTime(
+		keyCompatible,
+		&gost28147.SboxIdtc26gost28147paramZ,
+	)}
+	wipe.Bytes(keyCompatible)
+	return &c
+}
+
+// Zero the round keys. Cipher must not be used after that.
+func (c *Cipher) Destroy() {
+	wipe.Uint32s(c.rk[:])
+	if c.ct != nil {
+		c.ct.Destroy()
 	}
 }
 
@@ -53,41 +116,48 @@ func (c *Cipher) BlockSize() int {
 }
 
 func (c *Cipher) Encrypt(dst, src []byte) {
-	c.blk[0] = src[7]
-	c.blk[1] = src[6]
-	c.blk[2] = src[5]
-	c.blk[3] = src[4]
-	c.blk[4] = src[3]
-	c.blk[5] = src[2]
-	c.blk[6] = src[1]
-	c.blk[7] = src[0]
-	c.c.Encrypt(c.blk[:], c.blk[:])
-	dst[0] = c.blk[7]
-	dst[1] = c.blk[6]
-	dst[2] = c.blk[5]
-	dst[3] = c.blk[4]
-	dst[4] = c.blk[3]
-	dst[5] = c.blk[2]
-	dst[6] = c.blk[1]
-	dst[7] = c.blk[0]
+	if c.ct != nil {
+		c.xcryptConstantTime(dst, src, true)
+		return
+	}
+	n1 := binary.BigEndian.Uint32(src[4:])
+	n2 := binary.BigEndian.Uint32(src[:4])
+	for i := 0; i < 32; i += 2 {
+		n2 ^= g(n1 + c.rk[i])
+		n1 ^= g(n2 + c.rk[i+1])
+	}
+	binary.BigEndian.PutUint32(dst[:4], n1)
+	binary.BigEndian.PutUint32(dst[4:], n2)
 }
 
 func (c *Cipher) Decrypt(dst, src []byte) {
-	c.blk[0] = src[7]
-	c.blk[1] = src[6]
-	c.blk[2] = src[5]
-	c.blk[3] = src[4]
-	c.blk[4] = src[3]
-	c.blk[5] = src[2]
-	c.blk[6] = src[1]
-	c.blk[7] = src[0]
-	c.c.Decrypt(c.blk[:], c.blk[:])
-	dst[0] = c.blk[7]
-	dst[1] = c.blk[6]
-	dst[2] = c.blk[5]
-	dst[3] = c.blk[4]
-	dst[4] = c.blk[3]
-	dst[5] = c.blk[2]
-	dst[6] = c.blk[1]
-	dst[7] = c.blk[0]
+	if c.ct != nil {
+		c.xcryptConstantTime(dst, src, false)
+		return
+	}
+	n1 := binary.BigEndian.Uint32(src[4:])
+	n2 := binary.BigEndian.Uint32(src[:4])
+	for i := 31; i > 0; i -= 2 {
+		n2 ^= g(n1 + c.rk[i])
+		n1 ^= g(n2 + c.rk[i-1])
+	}
+	binary.BigEndian.PutUint32(dst[:4], n1)
+	binary.BigEndian.PutUint32(dst[4:], n2)
+}
+
+// 28147-89 works with little-endian blocks, so constant-time
+// implementation reverses them on the stack.
+func (c *Cipher) xcryptConstantTime(dst, src []byte, encrypt bool) {
+	var blk [BlockSize]byte
+	for i := 0; i < BlockSize; i++ {
+		blk[i] = src[BlockSize-1-i]
+	}
+	if encrypt {
+		c.ct.Encrypt(blk[:], blk[:])
+	} else {
+		c.ct.Decrypt(blk[:], blk[:])
+	}
+	for i := 0; i < BlockSize; i++ {
+		dst[i] = blk[BlockSize-1-i]
+	}
 }
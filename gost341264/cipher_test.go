@@ -19,22 +19,29 @@ package gost341264
 import (
 	"bytes"
 	"crypto/cipher"
+	"crypto/rand"
 	"testing"
+	"testing/quick"
+
+	"github.com/ddulesov/gogost/gost28147"
 )
 
 func TestCipherInterface(t *testing.T) {
 	var _ cipher.Block = NewCipher(make([]byte, KeySize))
 }
 
-func TestVector(t *testing.T) {
-	key := []byte{
+var (
+	key []byte = []byte{
 		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
 		0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00,
 		0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7,
 		0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0xfe, 0xff,
 	}
-	pt := [BlockSize]byte{0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10}
-	ct := [BlockSize]byte{0x4e, 0xe9, 0x01, 0xe5, 0xc2, 0xd8, 0xca, 0x3d}
+	pt [BlockSize]byte = [BlockSize]byte{0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10}
+	ct [BlockSize]byte = [BlockSize]byte{0x4e, 0xe9, 0x01, 0xe5, 0xc2, 0xd8, 0xca, 0x3d}
+)
+
+func TestVector(t *testing.T) {
 	c := NewCipher(key)
 	dst := make([]byte, BlockSize)
 	c.Encrypt(dst, pt[:])
@@ -46,3 +53,84 @@ func TestVector(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestVectorConstantTime(t *testing.T) {
+	c := NewCipherConstantTime(key)
+	dst := make([]byte, BlockSize)
+	c.Encrypt(dst, pt[:])
+	if bytes.Compare(dst, ct[:]) != 0 {
+		t.FailNow()
+	}
+	c.Decrypt(dst, dst)
+	if bytes.Compare(dst, pt[:]) != 0 {
+		t.FailNow()
+	}
+}
+
+// Magma is 28147-89 with fixed Sbox and reversed byte order.
+func TestCompatibility28147(t *testing.T) {
+	keyCompatible := make([]byte, KeySize)
+	blk := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	f := func(key [KeySize]byte, pt [BlockSize]byte) bool {
+		for i := 0; i < KeySize; i++ {
+			keyCompatible[i] = key[i/4*4+3-i%4]
+		}
+		for i := 0; i < BlockSize; i++ {
+			blk[i] = pt[BlockSize-1-i]
+		}
+		gost28147.NewCipher(
+			keyCompatible,
+			&gost28147.SboxIdtc26gost28147paramZ,
+		).Encrypt(blk, blk)
+		c := NewCipher(key[:])
+		c.Encrypt(dst, pt[:])
+		for i := 0; i < BlockSize; i++ {
+			if dst[i] != blk[BlockSize-1-i] {
+				return false
+			}
+		}
+		NewCipherConstantTime(key[:]).Decrypt(dst, dst)
+		return bytes.Compare(dst, pt[:]) == 0
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	key := make([]byte, KeySize)
+	rand.Read(key)
+	c := NewCipher(key)
+	blk := make([]byte, BlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(blk, blk)
+	}
+}
+
+func BenchmarkEncryptConstantTime(b *testing.B) {
+	key := make([]byte, KeySize)
+	rand.Read(key)
+	c := NewCipherConstantTime(key)
+	blk := make([]byte, BlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(blk, blk)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	c := NewCipher(key)
+	c.Destroy()
+	if c.rk != [32]uint32{} {
+		t.FailNow()
+	}
+	c = NewCipherConstantTime(key)
+	c.Destroy()
+	dst := make([]byte, BlockSize)
+	c.Encrypt(dst, pt[:])
+	if bytes.Compare(dst, ct[:]) == 0 {
+		t.FailNow()
+	}
+}
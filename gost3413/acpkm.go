@@ -0,0 +1,117 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3413
+
+import (
+	"crypto/cipher"
+
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+// ACPKM key meshing constant D.
+var acpkmD = []byte{
+	0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+	0x88, 0x89, 0x8A, 0x8B, 0x8C, 0x8D, 0x8E, 0x8F,
+	0x90, 0x91, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97,
+	0x98, 0x99, 0x9A, 0x9B, 0x9C, 0x9D, 0x9E, 0x9F,
+}
+
+// CTR-ACPKM mode of operation (RFC 8645): CTR mode, where the key is
+// changed with ACPKM transformation after every section of sectionSize
+// bytes.
+type CTRACPKM struct {
+	newCipher   func(key []byte) cipher.Block
+	c           cipher.Block
+	key         []byte
+	ctr         []byte
+	ks          []byte
+	ksLeft      int
+	sectionSize int
+	sectionLeft int
+}
+
+// Create CTR-ACPKM stream. IV is half of the cipher's blocksize,
+// sectionSize must be multiple of the blocksize.
+func NewCTRACPKM(newCipher func(key []byte) cipher.Block, key, iv []byte, sectionSize int) *CTRACPKM {
+	c := newCipher(key)
+	blockSize := c.BlockSize()
+	if len(iv) != blockSize/2 {
+		panic("invalid IV size")
+	}
+	if sectionSize <= 0 || sectionSize%blockSize != 0 {
+		panic("invalid section size")
+	}
+	s := CTRACPKM{
+		newCipher:   newCipher,
+		c:           c,
+		key:         make([]byte, len(key)),
+		ctr:         make([]byte, blockSize),
+		ks:          make([]byte, blockSize),
+		sectionSize: sectionSize,
+		sectionLeft: sectionSize,
+	}
+	copy(s.key, key)
+	copy(s.ctr, iv)
+	return &s
+}
+
+// K^{i+1} = MSB_k(E_{K^i}(D_1) || ... || E_{K^i}(D_J))
+func (s *CTRACPKM) acpkm() {
+	blockSize := s.c.BlockSize()
+	for i := 0; i < len(s.key); i += blockSize {
+		s.c.Encrypt(s.key[i:i+blockSize], acpkmD[i:i+blockSize])
+	}
+	if d, ok := s.c.(interface{ Destroy() }); ok {
+		d.Destroy()
+	}
+	s.c = s.newCipher(s.key)
+	s.sectionLeft = s.sectionSize
+}
+
+func (s *CTRACPKM) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("output smaller than input")
+	}
+	blockSize := len(s.ctr)
+	for i := 0; i < len(src); i++ {
+		if s.ksLeft == 0 {
+			if s.sectionLeft == 0 {
+				s.acpkm()
+			}
+			s.c.Encrypt(s.ks, s.ctr)
+			for j := blockSize - 1; j >= 0; j-- {
+				s.ctr[j]++
+				if s.ctr[j] != 0 {
+					break
+				}
+			}
+			s.ksLeft = blockSize
+			s.sectionLeft -= blockSize
+		}
+		dst[i] = src[i] ^ s.ks[blockSize-s.ksLeft]
+		s.ksLeft--
+	}
+}
+
+// Zero the current key and keystream.
+func (s *CTRACPKM) Destroy() {
+	wipe.Bytes(s.key)
+	wipe.Bytes(s.ks)
+	if d, ok := s.c.(interface{ Destroy() }); ok {
+		d.Destroy()
+	}
+}
@@ -0,0 +1,102 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3413
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+)
+
+func newKuznechik(key []byte) cipher.Block {
+	return gost3412128.NewCipher(key)
+}
+
+func newMagma(key []byte) cipher.Block {
+	return gost341264.NewCipher(key)
+}
+
+// RFC 8645 A.1 with N=256 bits section size.
+func TestCTRACPKMKuznechik(t *testing.T) {
+	s := NewCTRACPKM(newKuznechik, []byte{
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	}, []byte{0x12, 0x34, 0x56, 0x78, 0x90, 0xab, 0xce, 0xf0}, 32)
+	pt := []byte{
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x00,
+		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xee, 0xff, 0x0a,
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88,
+		0x99, 0xaa, 0xbb, 0xcc, 0xee, 0xff, 0x0a, 0x00,
+		0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99,
+		0xaa, 0xbb, 0xcc, 0xee, 0xff, 0x0a, 0x00, 0x11,
+		0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa,
+		0xbb, 0xcc, 0xee, 0xff, 0x0a, 0x00, 0x11, 0x22,
+		0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb,
+		0xcc, 0xee, 0xff, 0x0a, 0x00, 0x11, 0x22, 0x33,
+		0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc,
+		0xee, 0xff, 0x0a, 0x00, 0x11, 0x22, 0x33, 0x44,
+	}
+	ct := make([]byte, len(pt))
+	// Feed it unaligned to check keystream buffering
+	s.XORKeyStream(ct[:5], pt[:5])
+	s.XORKeyStream(ct[5:], pt[5:])
+	if bytes.Compare(ct, []byte{
+		0xf1, 0x95, 0xd8, 0xbe, 0xc1, 0x0e, 0xd1, 0xdb,
+		0xd5, 0x7b, 0x5f, 0xa2, 0x40, 0xbd, 0xa1, 0xb8,
+		0x85, 0xee, 0xe7, 0x33, 0xf6, 0xa1, 0x3e, 0x5d,
+		0xf3, 0x3c, 0xe4, 0xb3, 0x3c, 0x45, 0xde, 0xe4,
+		0x4b, 0xce, 0xeb, 0x8f, 0x64, 0x6f, 0x4c, 0x55,
+		0x00, 0x17, 0x06, 0x27, 0x5e, 0x85, 0xe8, 0x00,
+		0x58, 0x7c, 0x4d, 0xf5, 0x68, 0xd0, 0x94, 0x39,
+		0x3e, 0x48, 0x34, 0xaf, 0xd0, 0x80, 0x50, 0x46,
+		0xcf, 0x30, 0xf5, 0x76, 0x86, 0xae, 0xec, 0xe1,
+		0x1c, 0xfc, 0x6c, 0x31, 0x6b, 0x8a, 0x89, 0x6e,
+		0xdf, 0xfd, 0x07, 0xec, 0x81, 0x36, 0x36, 0x46,
+		0x0c, 0x4f, 0x3b, 0x74, 0x34, 0x23, 0x16, 0x3e,
+		0x64, 0x09, 0xa9, 0xc2, 0x82, 0xfa, 0xc8, 0xd4,
+		0x69, 0xd2, 0x21, 0xe7, 0xfb, 0xd6, 0xde, 0x5d,
+	}) != 0 {
+		t.FailNow()
+	}
+}
+
+// The first section of CTR-ACPKM is ordinary CTR mode.
+func TestCTRACPKMFirstSection(t *testing.T) {
+	key := make([]byte, 32)
+	iv := make([]byte, 4)
+	rand.Read(key)
+	rand.Read(iv)
+	pt := make([]byte, 64)
+	rand.Read(pt)
+	ctr := make([]byte, 8)
+	copy(ctr, iv)
+	ct1 := make([]byte, len(pt))
+	cipher.NewCTR(newMagma(key), ctr).XORKeyStream(ct1, pt)
+	ct2 := make([]byte, len(pt))
+	NewCTRACPKM(newMagma, key, iv, 64).XORKeyStream(ct2, pt)
+	if bytes.Compare(ct1, ct2) != 0 {
+		t.FailNow()
+	}
+}
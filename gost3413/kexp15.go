@@ -0,0 +1,93 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3413
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"errors"
+
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+func destroy(c cipher.Block) {
+	if d, ok := c.(interface{ Destroy() }); ok {
+		d.Destroy()
+	}
+}
+
+// KExp15 key export algorithm (R 1323565.1.017): CTR(K_EXP_ENC, IV,
+// key || OMAC(K_EXP_MAC, IV || key)). IV is half of the cipher's
+// blocksize, MAC is blocksize long.
+func KExp15(newCipher func(key []byte) cipher.Block, key, kExpMAC, kExpEnc, iv []byte) ([]byte, error) {
+	cMAC := newCipher(kExpMAC)
+	defer destroy(cMAC)
+	if len(iv) != cMAC.BlockSize()/2 {
+		return nil, errors.New("invalid IV size")
+	}
+	m, err := NewOMAC(cMAC, cMAC.BlockSize())
+	if err != nil {
+		return nil, err
+	}
+	m.Write(iv)
+	m.Write(key)
+	out := make([]byte, len(key), len(key)+m.Size())
+	copy(out, key)
+	out = m.Sum(out)
+	m.Destroy()
+	cEnc := newCipher(kExpEnc)
+	ctr := make([]byte, cEnc.BlockSize())
+	copy(ctr, iv)
+	cipher.NewCTR(cEnc, ctr).XORKeyStream(out, out)
+	destroy(cEnc)
+	return out, nil
+}
+
+// KImp15 key import algorithm, reverse of KExp15.
+func KImp15(newCipher func(key []byte) cipher.Block, exported, kExpMAC, kExpEnc, iv []byte) ([]byte, error) {
+	cEnc := newCipher(kExpEnc)
+	blockSize := cEnc.BlockSize()
+	if len(iv) != blockSize/2 {
+		destroy(cEnc)
+		return nil, errors.New("invalid IV size")
+	}
+	if len(exported) <= blockSize {
+		destroy(cEnc)
+		return nil, errors.New("too short exported key")
+	}
+	out := make([]byte, len(exported))
+	ctr := make([]byte, blockSize)
+	copy(ctr, iv)
+	cipher.NewCTR(cEnc, ctr).XORKeyStream(out, exported)
+	destroy(cEnc)
+	key := out[:len(out)-blockSize]
+	cMAC := newCipher(kExpMAC)
+	m, err := NewOMAC(cMAC, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	m.Write(iv)
+	m.Write(key)
+	expected := m.Sum(nil)
+	m.Destroy()
+	destroy(cMAC)
+	if !hmac.Equal(expected, out[len(key):]) {
+		wipe.Bytes(out)
+		return nil, errors.New("invalid exported key MAC")
+	}
+	return key, nil
+}
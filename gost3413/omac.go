@@ -0,0 +1,144 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3413
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+// GOST R 34.13-2015 OMAC (CMAC) message authentication code.
+type OMAC struct {
+	c       cipher.Block
+	size    int
+	k1      []byte
+	k2      []byte
+	x       []byte
+	buf     []byte
+	bufLen  int
+	scratch []byte
+}
+
+func shiftLeft(dst, src []byte) byte {
+	var carry byte
+	for i := len(src) - 1; i >= 0; i-- {
+		b := src[i]
+		dst[i] = b<<1 | carry
+		carry = b >> 7
+	}
+	return carry
+}
+
+// Create OMAC with given tag size (in bytes) over 64 or 128-bit block
+// cipher.
+func NewOMAC(c cipher.Block, size int) (*OMAC, error) {
+	blockSize := c.BlockSize()
+	var r byte
+	switch blockSize {
+	case 8:
+		r = 0x1B
+	case 16:
+		r = 0x87
+	default:
+		return nil, errors.New("OMAC supports only 64/128 blocksizes")
+	}
+	if size <= 0 || size > blockSize {
+		return nil, errors.New("invalid tag size")
+	}
+	m := OMAC{
+		c:       c,
+		size:    size,
+		k1:      make([]byte, blockSize),
+		k2:      make([]byte, blockSize),
+		x:       make([]byte, blockSize),
+		buf:     make([]byte, blockSize),
+		scratch: make([]byte, blockSize),
+	}
+	l := make([]byte, blockSize)
+	c.Encrypt(l, l)
+	if shiftLeft(m.k1, l) == 1 {
+		m.k1[blockSize-1] ^= r
+	}
+	if shiftLeft(m.k2, m.k1) == 1 {
+		m.k2[blockSize-1] ^= r
+	}
+	wipe.Bytes(l)
+	return &m, nil
+}
+
+func (m *OMAC) Reset() {
+	wipe.Bytes(m.x)
+	wipe.Bytes(m.buf)
+	m.bufLen = 0
+}
+
+func (m *OMAC) BlockSize() int {
+	return len(m.x)
+}
+
+func (m *OMAC) Size() int {
+	return m.size
+}
+
+func (m *OMAC) Write(b []byte) (int, error) {
+	n := len(b)
+	blockSize := len(m.x)
+	for len(b) > 0 {
+		// The last block is kept buffered, as it is processed
+		// differently during finalization
+		if m.bufLen == blockSize {
+			for i := 0; i < blockSize; i++ {
+				m.x[i] ^= m.buf[i]
+			}
+			m.c.Encrypt(m.x, m.x)
+			m.bufLen = 0
+		}
+		copied := copy(m.buf[m.bufLen:], b)
+		m.bufLen += copied
+		b = b[copied:]
+	}
+	return n, nil
+}
+
+func (m *OMAC) Sum(b []byte) []byte {
+	blockSize := len(m.x)
+	copy(m.scratch, m.buf[:m.bufLen])
+	k := m.k1
+	if m.bufLen < blockSize {
+		m.scratch[m.bufLen] = 0x80
+		for i := m.bufLen + 1; i < blockSize; i++ {
+			m.scratch[i] = 0
+		}
+		k = m.k2
+	}
+	for i := 0; i < blockSize; i++ {
+		m.scratch[i] ^= m.x[i] ^ k[i]
+	}
+	m.c.Encrypt(m.scratch, m.scratch)
+	b = append(b, m.scratch[:m.size]...)
+	wipe.Bytes(m.scratch)
+	return b
+}
+
+// Zero the subkeys and the state. Underlying cipher is not destroyed.
+func (m *OMAC) Destroy() {
+	wipe.Bytes(m.k1)
+	wipe.Bytes(m.k2)
+	m.Reset()
+}
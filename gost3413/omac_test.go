@@ -0,0 +1,141 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost3413
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+)
+
+func TestOMACKuznechik(t *testing.T) {
+	c := gost3412128.NewCipher([]byte{
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	})
+	m, err := NewOMAC(c, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Write([]byte{
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x00,
+		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xee, 0xff, 0x0a,
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88,
+		0x99, 0xaa, 0xbb, 0xcc, 0xee, 0xff, 0x0a, 0x00,
+		0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99,
+		0xaa, 0xbb, 0xcc, 0xee, 0xff, 0x0a, 0x00, 0x11,
+	})
+	if bytes.Compare(m.Sum(nil), []byte{
+		0x33, 0x6f, 0x4d, 0x29, 0x60, 0x59, 0xfb, 0xe3,
+	}) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestOMACMagma(t *testing.T) {
+	c := gost341264.NewCipher([]byte{
+		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
+		0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00,
+		0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7,
+		0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0xfe, 0xff,
+	})
+	m, err := NewOMAC(c, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Write([]byte{
+		0x92, 0xde, 0xf0, 0x6b, 0x3c, 0x13, 0x0a, 0x59,
+		0xdb, 0x54, 0xc7, 0x04, 0xf8, 0x18, 0x9d, 0x20,
+		0x4a, 0x98, 0xfb, 0x2e, 0x67, 0xa8, 0x02, 0x4c,
+		0x89, 0x12, 0x40, 0x9b, 0x17, 0xb5, 0x7e, 0x41,
+	})
+	if bytes.Compare(m.Sum(nil), []byte{0x15, 0x4e, 0x72, 0x10}) != 0 {
+		t.FailNow()
+	}
+}
+
+// CTR mode of GOST R 34.13-2015 with s=n is equivalent to crypto/cipher's
+// one with IV||0 initial counter.
+func TestCTRKuznechik(t *testing.T) {
+	c := gost3412128.NewCipher([]byte{
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32, 0x10,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	})
+	iv := []byte{
+		0x12, 0x34, 0x56, 0x78, 0x90, 0xab, 0xce, 0xf0,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	pt := []byte{
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x00,
+		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
+	}
+	ct := make([]byte, len(pt))
+	cipher.NewCTR(c, iv).XORKeyStream(ct, pt)
+	if bytes.Compare(ct, []byte{
+		0xf1, 0x95, 0xd8, 0xbe, 0xc1, 0x0e, 0xd1, 0xdb,
+		0xd5, 0x7b, 0x5f, 0xa2, 0x40, 0xbd, 0xa1, 0xb8,
+	}) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestCTRMagma(t *testing.T) {
+	c := gost341264.NewCipher([]byte{
+		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
+		0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00,
+		0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7,
+		0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0xfe, 0xff,
+	})
+	iv := []byte{0x12, 0x34, 0x56, 0x78, 0x00, 0x00, 0x00, 0x00}
+	pt := []byte{0x92, 0xde, 0xf0, 0x6b, 0x3c, 0x13, 0x0a, 0x59}
+	ct := make([]byte, len(pt))
+	cipher.NewCTR(c, iv).XORKeyStream(ct, pt)
+	if bytes.Compare(ct, []byte{
+		0x4e, 0x98, 0x11, 0x0c, 0x97, 0xb7, 0xb9, 0x3c,
+	}) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestOMACIncremental(t *testing.T) {
+	c := gost341264.NewCipher(make([]byte, 32))
+	data := make([]byte, 37)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for l := 0; l <= len(data); l++ {
+		m, _ := NewOMAC(c, 8)
+		m.Write(data[:l])
+		whole := m.Sum(nil)
+		m.Reset()
+		for i := 0; i < l; i++ {
+			m.Write(data[i : i+1])
+		}
+		if bytes.Compare(m.Sum(nil), whole) != 0 {
+			t.Fatal(l)
+		}
+	}
+}
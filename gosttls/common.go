@@ -0,0 +1,225 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Minimal GOST TLS 1.3 (RFC 9367) client and server.
+//
+// Only full 1-RTT handshake with server authentication by GOST R
+// 34.10-2012 certificate and ephemeral VKO key agreement on tc26
+// curves is supported. There is no session resumption, early data,
+// HelloRetryRequest or client authentication.
+package gosttls
+
+import (
+	"crypto/rand"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/tls13gost"
+)
+
+const (
+	versionTLS12 = 0x0303
+	versionTLS13 = 0x0304
+
+	typeClientHello         = 1
+	typeServerHello         = 2
+	typeNewSessionTicket    = 4
+	typeEncryptedExtensions = 8
+	typeCertificate         = 11
+	typeCertificateVerify   = 15
+	typeFinished            = 20
+	typeKeyUpdate           = 24
+
+	extServerName          = 0
+	extSupportedGroups     = 10
+	extSignatureAlgorithms = 13
+	extSupportedVersions   = 43
+	extKeyShare            = 51
+
+	alertCloseNotify          = 0
+	alertUnexpectedMessage    = 10
+	alertBadRecordMAC         = 20
+	alertHandshakeFailure     = 40
+	alertBadCertificate       = 42
+	alertIllegalParameter     = 47
+	alertDecodeError          = 50
+	alertDecryptError         = 51
+	alertProtocolVersion      = 70
+	alertInternalError        = 80
+	alertMissingExtension     = 109
+	alertLevelWarning         = 1
+	alertLevelFatal           = 2
+	maxHandshakeMessageLength = 1 << 16
+)
+
+// Key exchange group (RFC 9367 section 3.2).
+type Group uint16
+
+const (
+	GC256A Group = 0x22
+	GC256B Group = 0x23
+	GC256C Group = 0x24
+	GC256D Group = 0x25
+	GC512A Group = 0x26
+	GC512B Group = 0x27
+	GC512C Group = 0x28
+)
+
+func (g Group) curve() (*gost3410.Curve, gost3410.Mode, bool) {
+	switch g {
+	case GC256A:
+		return gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001, true
+	case GC256B:
+		return gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001, true
+	case GC256C:
+		return gost3410.CurveIdGostR34102001CryptoProBParamSet(), gost3410.Mode2001, true
+	case GC256D:
+		return gost3410.CurveIdGostR34102001CryptoProCParamSet(), gost3410.Mode2001, true
+	case GC512A:
+		return gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012, true
+	case GC512B:
+		return gost3410.CurveIdtc26gost341012512paramSetB(), gost3410.Mode2012, true
+	case GC512C:
+		return gost3410.CurveIdtc26gost34102012512paramSetC(), gost3410.Mode2012, true
+	}
+	return nil, 0, false
+}
+
+// Signature scheme (RFC 9367 section 3.3).
+type SignatureScheme uint16
+
+const (
+	GOSTR34102012256A SignatureScheme = 0x0709
+	GOSTR34102012256B SignatureScheme = 0x070A
+	GOSTR34102012256C SignatureScheme = 0x070B
+	GOSTR34102012256D SignatureScheme = 0x070C
+	GOSTR34102012512A SignatureScheme = 0x070D
+	GOSTR34102012512B SignatureScheme = 0x070E
+	GOSTR34102012512C SignatureScheme = 0x070F
+)
+
+var schemeGroups = map[SignatureScheme]Group{
+	GOSTR34102012256A: GC256A,
+	GOSTR34102012256B: GC256B,
+	GOSTR34102012256C: GC256C,
+	GOSTR34102012256D: GC256D,
+	GOSTR34102012512A: GC512A,
+	GOSTR34102012512B: GC512B,
+	GOSTR34102012512C: GC512C,
+}
+
+// Signature scheme corresponding to the key's curve.
+func schemeOf(curve *gost3410.Curve, mode gost3410.Mode) (SignatureScheme, bool) {
+	for scheme, group := range schemeGroups {
+		c, m, _ := group.curve()
+		if c.Name == curve.Name && m == mode {
+			return scheme, true
+		}
+	}
+	return 0, false
+}
+
+func (s SignatureScheme) hash() hash.Hash {
+	if s >= GOSTR34102012512A {
+		return gost34112012512.New()
+	}
+	return gost34112012256.New()
+}
+
+var (
+	defaultCipherSuites = []tls13gost.CipherSuite{
+		tls13gost.TLSGOSTR341112256WithKuznyechikMGML,
+		tls13gost.TLSGOSTR341112256WithMagmaMGML,
+		tls13gost.TLSGOSTR341112256WithKuznyechikMGMS,
+		tls13gost.TLSGOSTR341112256WithMagmaMGMS,
+	}
+	defaultGroups = []Group{GC256A, GC256B, GC256C, GC256D, GC512A, GC512B, GC512C}
+)
+
+type Config struct {
+	// Source of entropy. crypto/rand's Reader is used if nil.
+	Rand io.Reader
+
+	// DER-encoded certificates chain (leaf first) and its private
+	// key, required for the server.
+	Certificate [][]byte
+	PrivateKey  *gost3410.PrivateKey
+
+	// Cipher suites in the order of preference.
+	CipherSuites []tls13gost.CipherSuite
+
+	// Key exchange groups in the order of preference. Client sends key
+	// share for the first one only.
+	Groups []Group
+
+	// Server name indication sent by the client.
+	ServerName string
+
+	// Called by the client with the server's certificates chain and
+	// the public key taken from its leaf. Either it has to be set, or
+	// InsecureSkipVerify.
+	VerifyPeerCertificate func(rawCerts [][]byte, pub *gost3410.PublicKey) error
+	InsecureSkipVerify    bool
+}
+
+func (c *Config) rand() io.Reader {
+	if c.Rand == nil {
+		return rand.Reader
+	}
+	return c.Rand
+}
+
+func (c *Config) cipherSuites() []tls13gost.CipherSuite {
+	if len(c.CipherSuites) == 0 {
+		return defaultCipherSuites
+	}
+	return c.CipherSuites
+}
+
+func (c *Config) groups() []Group {
+	if len(c.Groups) == 0 {
+		return defaultGroups
+	}
+	return c.Groups
+}
+
+// Error with the alert to be sent to the peer.
+type alertError struct {
+	alert byte
+	msg   string
+}
+
+func (e *alertError) Error() string {
+	return "gosttls: " + e.msg
+}
+
+func newAlert(alert byte, msg string) error {
+	return &alertError{alert, msg}
+}
+
+// Alert received from the peer.
+type AlertError byte
+
+func (e AlertError) Error() string {
+	return "gosttls: received alert " + strconv.Itoa(int(e))
+}
+
+var errClosed = errors.New("gosttls: use of closed connection")
@@ -0,0 +1,467 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gosttls
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/tls13gost"
+)
+
+const (
+	recordTypeChangeCipherSpec = byte(tls13gost.ContentTypeChangeCipherSpec)
+	recordTypeAlert            = byte(tls13gost.ContentTypeAlert)
+	recordTypeHandshake        = byte(tls13gost.ContentTypeHandshake)
+	recordTypeApplicationData  = byte(tls13gost.ContentTypeApplicationData)
+)
+
+// One direction of the connection.
+type halfConn struct {
+	suite  tls13gost.CipherSuite
+	secret []byte
+	rp     *tls13gost.RecordProtection
+	seq    uint64
+}
+
+func (hc *halfConn) setSecret(suite tls13gost.CipherSuite, secret []byte) error {
+	rp, err := tls13gost.NewRecordProtectionFromSecret(suite, secret)
+	if err != nil {
+		return err
+	}
+	hc.destroy()
+	hc.suite = suite
+	hc.secret = secret
+	hc.rp = rp
+	hc.seq = 0
+	return nil
+}
+
+// Move to the next application traffic secret after KeyUpdate.
+func (hc *halfConn) update() error {
+	next := tls13gost.NextTrafficSecret(hc.suite.Hash(), hc.secret)
+	return hc.setSecret(hc.suite, next)
+}
+
+func (hc *halfConn) destroy() {
+	if hc.rp != nil {
+		hc.rp.Destroy()
+		hc.rp = nil
+	}
+	wipe.Bytes(hc.secret)
+	hc.secret = nil
+}
+
+// GOST TLS 1.3 connection. It implements net.Conn.
+type Conn struct {
+	conn     net.Conn
+	config   *Config
+	isClient bool
+
+	handshakeMutex    sync.Mutex
+	handshakeComplete bool
+	handshakeErr      error
+
+	suite         tls13gost.CipherSuite
+	peerCerts     [][]byte
+	peerPublicKey *gost3410.PublicKey
+
+	in  halfConn
+	out halfConn
+
+	readMutex  sync.Mutex
+	hsBuf      []byte
+	appBuf     []byte
+	readErr    error
+	writeMutex sync.Mutex
+	writeErr   error
+	closed     bool
+}
+
+// Client side of the connection. Handshake is performed on the first
+// Read or Write, or by explicit Handshake call.
+func Client(conn net.Conn, config *Config) *Conn {
+	return &Conn{conn: conn, config: config, isClient: true}
+}
+
+// Server side of the connection.
+func Server(conn net.Conn, config *Config) *Conn {
+	return &Conn{conn: conn, config: config}
+}
+
+func (c *Conn) Handshake() error {
+	c.handshakeMutex.Lock()
+	defer c.handshakeMutex.Unlock()
+	if c.handshakeComplete || c.handshakeErr != nil {
+		return c.handshakeErr
+	}
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+	if c.isClient {
+		c.handshakeErr = c.clientHandshake()
+	} else {
+		c.handshakeErr = c.serverHandshake()
+	}
+	if c.handshakeErr == nil {
+		c.handshakeComplete = true
+	} else {
+		c.sendAlertFor(c.handshakeErr)
+	}
+	return c.handshakeErr
+}
+
+// Negotiated cipher suite.
+func (c *Conn) CipherSuite() tls13gost.CipherSuite {
+	return c.suite
+}
+
+// Server's certificates chain and the public key from its leaf, known
+// to the client after the handshake.
+func (c *Conn) PeerCertificates() ([][]byte, *gost3410.PublicKey) {
+	return c.peerCerts, c.peerPublicKey
+}
+
+func (c *Conn) sendAlertFor(err error) {
+	alert := byte(alertInternalError)
+	if ae, ok := err.(*alertError); ok {
+		alert = ae.alert
+	}
+	if _, ok := err.(AlertError); ok {
+		return
+	}
+	c.sendAlert(alertLevelFatal, alert)
+}
+
+func (c *Conn) sendAlert(level, alert byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.writeRecord(recordTypeAlert, []byte{level, alert})
+}
+
+// Write the record, splitting data to several ones if necessary.
+// writeMutex must be held.
+func (c *Conn) writeRecord(typ byte, data []byte) error {
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data
+		if len(chunk) > tls13gost.MaxPlaintextSize {
+			chunk = chunk[:tls13gost.MaxPlaintextSize]
+		}
+		data = data[len(chunk):]
+		var record []byte
+		if c.out.rp == nil {
+			record = make([]byte, tls13gost.RecordHeaderSize, tls13gost.RecordHeaderSize+len(chunk))
+			record[0] = typ
+			binary.BigEndian.PutUint16(record[1:], versionTLS12)
+			binary.BigEndian.PutUint16(record[3:], uint16(len(chunk)))
+			record = append(record, chunk...)
+		} else {
+			var err error
+			record, err = c.out.rp.Seal(nil, c.out.seq, tls13gost.ContentType(typ), chunk, 0)
+			if err != nil {
+				c.writeErr = err
+				return err
+			}
+			c.out.seq++
+		}
+		if _, err := c.conn.Write(record); err != nil {
+			c.writeErr = err
+			return err
+		}
+	}
+	return nil
+}
+
+// Read and decrypt the next record. readMutex must be held.
+func (c *Conn) readRecord() (byte, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	typ, data, err := c.readRecordRaw()
+	if err != nil {
+		c.readErr = err
+	}
+	return typ, data, err
+}
+
+func (c *Conn) readRecordRaw() (byte, []byte, error) {
+	for {
+		header := make([]byte, tls13gost.RecordHeaderSize)
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[3:]))
+		if length > tls13gost.MaxCiphertextSize {
+			return 0, nil, newAlert(alertDecodeError, "too long record")
+		}
+		record := make([]byte, tls13gost.RecordHeaderSize+length)
+		copy(record, header)
+		if _, err := io.ReadFull(c.conn, record[tls13gost.RecordHeaderSize:]); err != nil {
+			return 0, nil, err
+		}
+		typ := header[0]
+		if typ == recordTypeChangeCipherSpec {
+			// Middlebox compatibility mode's record is ignored
+			if c.handshakeComplete || length != 1 || record[5] != 1 {
+				return 0, nil, newAlert(alertUnexpectedMessage, "unexpected ChangeCipherSpec")
+			}
+			continue
+		}
+		if c.in.rp == nil {
+			if typ != recordTypeHandshake && typ != recordTypeAlert {
+				return 0, nil, newAlert(alertUnexpectedMessage, "unexpected plaintext record")
+			}
+			return typ, record[tls13gost.RecordHeaderSize:], nil
+		}
+		ctyp, data, err := c.in.rp.Open(c.in.seq, record)
+		if err != nil {
+			return 0, nil, newAlert(alertBadRecordMAC, err.Error())
+		}
+		c.in.seq++
+		return byte(ctyp), data, nil
+	}
+}
+
+// Read the next record and dispatch it: handshake data is appended to
+// hsBuf, application data to appBuf. readMutex must be held.
+func (c *Conn) readAndDispatch() error {
+	typ, data, err := c.readRecord()
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case recordTypeAlert:
+		if len(data) != 2 {
+			c.readErr = newAlert(alertDecodeError, "invalid alert")
+			return c.readErr
+		}
+		if data[1] == alertCloseNotify {
+			c.readErr = io.EOF
+		} else {
+			c.readErr = AlertError(data[1])
+		}
+		return c.readErr
+	case recordTypeHandshake:
+		if len(data) == 0 {
+			c.readErr = newAlert(alertUnexpectedMessage, "empty handshake record")
+			return c.readErr
+		}
+		c.hsBuf = append(c.hsBuf, data...)
+	case recordTypeApplicationData:
+		if !c.handshakeComplete {
+			c.readErr = newAlert(alertUnexpectedMessage, "application data during handshake")
+			return c.readErr
+		}
+		c.appBuf = append(c.appBuf, data...)
+	default:
+		c.readErr = newAlert(alertUnexpectedMessage, "unknown record type")
+		return c.readErr
+	}
+	return nil
+}
+
+// Read the whole handshake message with its header. readMutex must be
+// held.
+func (c *Conn) readHandshake() (byte, []byte, error) {
+	for {
+		if len(c.hsBuf) >= 4 {
+			length := int(c.hsBuf[1])<<16 | int(c.hsBuf[2])<<8 | int(c.hsBuf[3])
+			if length > maxHandshakeMessageLength {
+				return 0, nil, newAlert(alertDecodeError, "too long handshake message")
+			}
+			if len(c.hsBuf) >= 4+length {
+				msg := make([]byte, 4+length)
+				copy(msg, c.hsBuf)
+				c.hsBuf = c.hsBuf[4+length:]
+				return msg[0], msg, nil
+			}
+		}
+		if err := c.readAndDispatch(); err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+func (c *Conn) writeHandshake(msgs ...[]byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	var data []byte
+	for _, msg := range msgs {
+		data = append(data, msg...)
+	}
+	return c.writeRecord(recordTypeHandshake, data)
+}
+
+// Handle post-handshake message. readMutex must be held.
+func (c *Conn) handlePostHandshake() error {
+	for len(c.hsBuf) > 0 {
+		typ, msg, err := c.readHandshake()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case typeNewSessionTicket:
+			// Resumption is not supported
+		case typeKeyUpdate:
+			if len(msg) != 5 || msg[4] > 1 {
+				return newAlert(alertDecodeError, "invalid KeyUpdate")
+			}
+			if len(c.hsBuf) > 0 {
+				return newAlert(alertUnexpectedMessage, "data after KeyUpdate")
+			}
+			if err = c.in.update(); err != nil {
+				return err
+			}
+			if msg[4] == 1 {
+				if err = c.sendKeyUpdate(false); err != nil {
+					return err
+				}
+			}
+		default:
+			return newAlert(alertUnexpectedMessage, "unexpected post-handshake message")
+		}
+	}
+	return nil
+}
+
+func (c *Conn) sendKeyUpdate(requestUpdate bool) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	msg := []byte{typeKeyUpdate, 0, 0, 1, 0}
+	if requestUpdate {
+		msg[4] = 1
+	}
+	if err := c.writeRecord(recordTypeHandshake, msg); err != nil {
+		return err
+	}
+	if err := c.out.update(); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// Send KeyUpdate message and move to the next traffic keys. If
+// requestUpdate is true, then peer is asked to update its keys too.
+func (c *Conn) UpdateKey(requestUpdate bool) error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+	return c.sendKeyUpdate(requestUpdate)
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+	for len(c.appBuf) == 0 {
+		if err := c.readAndDispatch(); err != nil {
+			if ae, ok := err.(*alertError); ok {
+				c.sendAlertFor(ae)
+			}
+			return 0, err
+		}
+		if err := c.handlePostHandshake(); err != nil {
+			c.readErr = err
+			c.sendAlertFor(err)
+			return 0, err
+		}
+	}
+	n := copy(b, c.appBuf)
+	c.appBuf = c.appBuf[n:]
+	return n, nil
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.closed {
+		return 0, errClosed
+	}
+	if err := c.writeRecord(recordTypeApplicationData, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Send close_notify alert (if handshake is complete) and close the
+// underlying connection.
+func (c *Conn) Close() error {
+	c.writeMutex.Lock()
+	if c.closed {
+		c.writeMutex.Unlock()
+		return errClosed
+	}
+	c.closed = true
+	var alertErr error
+	if c.handshakeComplete {
+		alertErr = c.writeRecord(recordTypeAlert, []byte{alertLevelWarning, alertCloseNotify})
+	}
+	c.out.destroy()
+	c.writeMutex.Unlock()
+	if err := c.conn.Close(); err != nil {
+		return err
+	}
+	return alertErr
+}
+
+// Send close_notify alert without closing the underlying connection.
+func (c *Conn) CloseWrite() error {
+	if !c.handshakeComplete {
+		return errors.New("gosttls: CloseWrite before handshake is complete")
+	}
+	return c.sendAlert(alertLevelWarning, alertCloseNotify)
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
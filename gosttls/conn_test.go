@@ -0,0 +1,340 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gosttls
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/tls13gost"
+)
+
+type testValidity struct {
+	NotBefore, NotAfter time.Time
+}
+
+type testTBS struct {
+	Version      int `asn1:"explicit,tag:0"`
+	SerialNumber *big.Int
+	Signature    testAlgorithm
+	Issuer       asn1.RawValue
+	Validity     testValidity
+	Subject      asn1.RawValue
+	PublicKey    gostasn1.SubjectPublicKeyInfo
+}
+
+type testAlgorithm struct {
+	Algorithm asn1.ObjectIdentifier
+}
+
+type testCertificate struct {
+	TBS       asn1.RawValue
+	Algorithm testAlgorithm
+	Signature asn1.BitString
+}
+
+// Self-signed certificate with the key on the given curve.
+func genCertificate(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode) ([]byte, *gost3410.PrivateKey) {
+	prv, err := gost3410.GenPrivateKey(curve, mode, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := prv.PublicKey()
+	spki, err := gostasn1.MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ := asn1.Marshal([]interface{}{})
+	alg := testAlgorithm{gostasn1.OIDSignWithDigestGost34102012256}
+	h := gost34112012256.New()
+	if mode == gost3410.Mode2012 {
+		alg.Algorithm = gostasn1.OIDSignWithDigestGost34102012512
+		h = gost34112012512.New()
+	}
+	tbs, err := asn1.Marshal(testTBS{
+		Version:      2,
+		SerialNumber: big.NewInt(1),
+		Signature:    alg,
+		Issuer:       asn1.RawValue{FullBytes: name},
+		Validity: testValidity{
+			time.Now().Add(-time.Hour).UTC(),
+			time.Now().Add(time.Hour).UTC(),
+		},
+		Subject:   asn1.RawValue{FullBytes: name},
+		PublicKey: spki,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write(tbs)
+	digest := h.Sum(nil)
+	for i, j := 0, len(digest)-1; i < j; i, j = i+1, j-1 {
+		digest[i], digest[j] = digest[j], digest[i]
+	}
+	signature, err := prv.SignDigest(digest, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := asn1.Marshal(testCertificate{
+		TBS:       asn1.RawValue{FullBytes: tbs},
+		Algorithm: alg,
+		Signature: asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, prv
+}
+
+// Establish client and server connections over TCP loopback.
+func pair(t *testing.T, clientConfig, serverConfig *Config) (*Conn, *Conn, error, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	serverResult := make(chan result)
+	go func() {
+		raw, err := ln.Accept()
+		if err != nil {
+			serverResult <- result{nil, err}
+			return
+		}
+		conn := Server(raw, serverConfig)
+		err = conn.Handshake()
+		serverResult <- result{conn, err}
+	}()
+	raw, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := Client(raw, clientConfig)
+	clientErr := client.Handshake()
+	if clientErr != nil {
+		client.Close()
+	}
+	r := <-serverResult
+	return client, r.conn, clientErr, r.err
+}
+
+func pinned(pub *gost3410.PublicKey) func([][]byte, *gost3410.PublicKey) error {
+	return func(certs [][]byte, got *gost3410.PublicKey) error {
+		if got.X.Cmp(pub.X) != 0 || got.Y.Cmp(pub.Y) != 0 {
+			return errors.New("unexpected server key")
+		}
+		return nil
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	for _, c := range []struct {
+		curve *gost3410.Curve
+		mode  gost3410.Mode
+		group Group
+		suite tls13gost.CipherSuite
+	}{
+		{gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001, GC256A, tls13gost.TLSGOSTR341112256WithKuznyechikMGML},
+		{gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001, GC256B, tls13gost.TLSGOSTR341112256WithMagmaMGML},
+		{gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001, GC256D, tls13gost.TLSGOSTR341112256WithKuznyechikMGMS},
+		{gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012, GC256A, tls13gost.TLSGOSTR341112256WithMagmaMGMS},
+		{gost3410.CurveIdtc26gost34102012512paramSetC(), gost3410.Mode2012, GC512C, tls13gost.TLSGOSTR341112256WithKuznyechikMGML},
+	} {
+		cert, prv := genCertificate(t, c.curve, c.mode)
+		pub, _ := prv.PublicKey()
+		client, server, clientErr, serverErr := pair(t, &Config{
+			CipherSuites:          []tls13gost.CipherSuite{c.suite},
+			Groups:                []Group{c.group},
+			ServerName:            "example.com",
+			VerifyPeerCertificate: pinned(pub),
+		}, &Config{
+			Certificate: [][]byte{cert},
+			PrivateKey:  prv,
+		})
+		if clientErr != nil || serverErr != nil {
+			t.Fatal(c.curve.Name, c.suite, clientErr, serverErr)
+		}
+		if client.CipherSuite() != c.suite || server.CipherSuite() != c.suite {
+			t.Fatal("cipher suite mismatch")
+		}
+		certs, _ := client.PeerCertificates()
+		if len(certs) != 1 || bytes.Compare(certs[0], cert) != 0 {
+			t.Fatal("peer certificates")
+		}
+		exchange(t, client, server, []byte("hello"))
+		client.Close()
+		server.Close()
+	}
+}
+
+// Send data from a to b and back.
+func exchange(t *testing.T, a, b *Conn, data []byte) {
+	errs := make(chan error, 1)
+	go func() {
+		got := make([]byte, len(data))
+		if _, err := io.ReadFull(b, got); err != nil {
+			errs <- err
+			return
+		}
+		_, err := b.Write(got)
+		errs <- err
+	}()
+	if _, err := a.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(a, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, data) != 0 {
+		t.Fatal("data differs")
+	}
+}
+
+func newPair(t *testing.T) (*Conn, *Conn) {
+	cert, prv := genCertificate(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001)
+	client, server, clientErr, serverErr := pair(
+		t,
+		&Config{InsecureSkipVerify: true},
+		&Config{Certificate: [][]byte{cert}, PrivateKey: prv},
+	)
+	if clientErr != nil || serverErr != nil {
+		t.Fatal(clientErr, serverErr)
+	}
+	return client, server
+}
+
+func TestLargeData(t *testing.T) {
+	client, server := newPair(t)
+	defer client.Close()
+	defer server.Close()
+	data := make([]byte, tls13gost.MaxPlaintextSize+1000)
+	rand.Read(data)
+	exchange(t, client, server, data)
+	exchange(t, server, client, data)
+}
+
+func TestKeyUpdate(t *testing.T) {
+	client, server := newPair(t)
+	defer client.Close()
+	defer server.Close()
+	clientSecret := append([]byte{}, client.out.secret...)
+	serverSecret := append([]byte{}, server.out.secret...)
+	if err := client.UpdateKey(true); err != nil {
+		t.Fatal(err)
+	}
+	exchange(t, client, server, []byte("after update"))
+	if bytes.Compare(client.out.secret, clientSecret) == 0 ||
+		bytes.Compare(server.out.secret, serverSecret) == 0 {
+		t.Fatal("keys are not updated")
+	}
+	if bytes.Compare(client.out.secret, server.in.secret) != 0 ||
+		bytes.Compare(server.out.secret, client.in.secret) != 0 {
+		t.Fatal("keys differ")
+	}
+	if err := server.UpdateKey(false); err != nil {
+		t.Fatal(err)
+	}
+	exchange(t, server, client, []byte("after second update"))
+}
+
+func TestCloseNotify(t *testing.T) {
+	client, server := newPair(t)
+	defer server.Close()
+	client.Close()
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyFailure(t *testing.T) {
+	cert, prv := genCertificate(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001)
+	other, _ := gost3410.GenPrivateKey(prv.C, prv.Mode, rand.Reader)
+	otherPub, _ := other.PublicKey()
+	_, _, clientErr, serverErr := pair(
+		t,
+		&Config{VerifyPeerCertificate: pinned(otherPub)},
+		&Config{Certificate: [][]byte{cert}, PrivateKey: prv},
+	)
+	if clientErr == nil || serverErr == nil {
+		t.FailNow()
+	}
+	if _, ok := serverErr.(AlertError); !ok {
+		t.Fatal("server has not received alert", serverErr)
+	}
+}
+
+// Server signs with the key not corresponding to the certificate.
+func TestWrongServerKey(t *testing.T) {
+	cert, prv := genCertificate(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001)
+	other, _ := gost3410.GenPrivateKey(prv.C, prv.Mode, rand.Reader)
+	_, _, clientErr, _ := pair(
+		t,
+		&Config{InsecureSkipVerify: true},
+		&Config{Certificate: [][]byte{cert}, PrivateKey: other},
+	)
+	if clientErr == nil {
+		t.FailNow()
+	}
+}
+
+func TestNoCommonSuite(t *testing.T) {
+	cert, prv := genCertificate(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001)
+	_, _, clientErr, serverErr := pair(t, &Config{
+		InsecureSkipVerify: true,
+		CipherSuites:       []tls13gost.CipherSuite{tls13gost.TLSGOSTR341112256WithMagmaMGML},
+	}, &Config{
+		Certificate:  [][]byte{cert},
+		PrivateKey:   prv,
+		CipherSuites: []tls13gost.CipherSuite{tls13gost.TLSGOSTR341112256WithKuznyechikMGML},
+	})
+	if clientErr == nil || serverErr == nil {
+		t.FailNow()
+	}
+}
+
+func TestOnCurve(t *testing.T) {
+	prv, _ := gost3410.GenPrivateKey(gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001, rand.Reader)
+	pub, _ := prv.PublicKey()
+	if !pub.OnCurve() {
+		t.FailNow()
+	}
+	pub.Y.Add(pub.Y, big.NewInt(1))
+	if pub.OnCurve() {
+		t.FailNow()
+	}
+	if _, err := sharedSecret(prv, pub.Raw()); err == nil {
+		t.FailNow()
+	}
+}
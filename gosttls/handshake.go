@@ -0,0 +1,92 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gosttls
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+var helloRetryRequestRandom = []byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11,
+	0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e,
+	0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
+// Generate ephemeral key for the group, returning it with the
+// key_exchange encoding of its public part.
+func generateKeyShare(config *Config, group Group) (*gost3410.PrivateKey, []byte, error) {
+	curve, mode, ok := group.curve()
+	if !ok {
+		return nil, nil, errors.New("gosttls: unsupported group")
+	}
+	prv, err := gost3410.GenPrivateKey(curve, mode, config.rand())
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	return prv, pub.Raw(), nil
+}
+
+// ECDHE shared secret: little-endian X coordinate of (m/q * d) * Q,
+// where m/q is the curve's cofactor (4 for twisted Edwards tc26 curves).
+func sharedSecret(prv *gost3410.PrivateKey, peer []byte) ([]byte, error) {
+	pub, err := gost3410.NewPublicKey(prv.C, prv.Mode, peer)
+	if err != nil {
+		return nil, newAlert(alertIllegalParameter, "invalid key share")
+	}
+	if !pub.OnCurve() {
+		return nil, newAlert(alertIllegalParameter, "key share is not on curve")
+	}
+	cofactor := big.NewInt(1)
+	if prv.C.IsEdwards() {
+		cofactor.SetInt64(4)
+	}
+	raw, err := prv.KEK(pub, cofactor)
+	if err != nil {
+		return nil, newAlert(alertIllegalParameter, "invalid key share")
+	}
+	secret := make([]byte, int(prv.Mode))
+	copy(secret, raw)
+	wipe.Bytes(raw)
+	return secret, nil
+}
+
+const serverSignatureContext = "TLS 1.3, server CertificateVerify"
+
+// Digest of the CertificateVerify's signed content, reversed as GOST
+// R 34.10 treats hash value as little-endian number.
+func signedDigest(scheme SignatureScheme, transcriptHash []byte) []byte {
+	h := scheme.hash()
+	h.Write(bytes.Repeat([]byte{0x20}, 64))
+	h.Write([]byte(serverSignatureContext))
+	h.Write([]byte{0})
+	h.Write(transcriptHash)
+	digest := h.Sum(nil)
+	for i, j := 0, len(digest)-1; i < j; i, j = i+1, j-1 {
+		digest[i], digest[j] = digest[j], digest[i]
+	}
+	return digest
+}
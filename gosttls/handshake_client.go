@@ -0,0 +1,203 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gosttls
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/tls13gost"
+)
+
+func (c *Conn) clientHandshake() error {
+	config := c.config
+	if config.VerifyPeerCertificate == nil && !config.InsecureSkipVerify {
+		return newAlert(alertInternalError, "either VerifyPeerCertificate or InsecureSkipVerify must be set")
+	}
+	hello := clientHelloMsg{
+		random:            make([]byte, 32),
+		sessionID:         make([]byte, 32),
+		serverName:        config.ServerName,
+		supportedVersions: []uint16{versionTLS13},
+		supportedGroups:   config.groups(),
+	}
+	if _, err := io.ReadFull(config.rand(), hello.random); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(config.rand(), hello.sessionID); err != nil {
+		return err
+	}
+	for _, suite := range config.cipherSuites() {
+		hello.cipherSuites = append(hello.cipherSuites, uint16(suite))
+	}
+	for scheme := GOSTR34102012256A; scheme <= GOSTR34102012512C; scheme++ {
+		hello.signatureAlgorithms = append(hello.signatureAlgorithms, scheme)
+	}
+	group := hello.supportedGroups[0]
+	eph, ephPub, err := generateKeyShare(config, group)
+	if err != nil {
+		return err
+	}
+	defer eph.Destroy()
+	hello.keyShares = []keyShare{{group, ephPub}}
+	helloRaw, err := hello.marshal()
+	if err != nil {
+		return err
+	}
+	transcript := gost34112012256.New()
+	transcript.Write(helloRaw)
+	if err = c.writeHandshake(helloRaw); err != nil {
+		return err
+	}
+
+	typ, msg, err := c.readHandshake()
+	if err != nil {
+		return err
+	}
+	if typ != typeServerHello {
+		return newAlert(alertUnexpectedMessage, "expected ServerHello")
+	}
+	var sh serverHelloMsg
+	if !sh.unmarshal(msg) {
+		return newAlert(alertDecodeError, "invalid ServerHello")
+	}
+	if bytes.Compare(sh.random, helloRetryRequestRandom) == 0 {
+		return newAlert(alertHandshakeFailure, "HelloRetryRequest is not supported")
+	}
+	if sh.supportedVersion != versionTLS13 {
+		return newAlert(alertProtocolVersion, "server does not support TLS 1.3")
+	}
+	if bytes.Compare(sh.sessionID, hello.sessionID) != 0 {
+		return newAlert(alertIllegalParameter, "invalid legacy_session_id_echo")
+	}
+	c.suite = tls13gost.CipherSuite(sh.cipherSuite)
+	offered := false
+	for _, suite := range hello.cipherSuites {
+		offered = offered || suite == sh.cipherSuite
+	}
+	if !offered {
+		return newAlert(alertIllegalParameter, "server chose not offered cipher suite")
+	}
+	if sh.keyShare.group != group {
+		return newAlert(alertIllegalParameter, "server chose not offered group")
+	}
+	shared, err := sharedSecret(eph, sh.keyShare.data)
+	if err != nil {
+		return err
+	}
+	transcript.Write(msg)
+
+	h := c.suite.Hash()
+	ks := tls13gost.NewKeySchedule(h, nil)
+	defer ks.Destroy()
+	ks.Handshake(shared)
+	wipe.Bytes(shared)
+	th := transcript.Sum(nil)
+	clientHS := ks.ClientHandshakeTrafficSecret(th)
+	serverHS := ks.ServerHandshakeTrafficSecret(th)
+	defer wipe.Bytes(clientHS)
+	defer wipe.Bytes(serverHS)
+	if err = c.in.setSecret(c.suite, append([]byte{}, serverHS...)); err != nil {
+		return err
+	}
+	if err = c.out.setSecret(c.suite, append([]byte{}, clientHS...)); err != nil {
+		return err
+	}
+
+	if typ, msg, err = c.readHandshake(); err != nil {
+		return err
+	}
+	if typ != typeEncryptedExtensions || !unmarshalEncryptedExtensions(msg) {
+		return newAlert(alertUnexpectedMessage, "expected EncryptedExtensions")
+	}
+	transcript.Write(msg)
+
+	if typ, msg, err = c.readHandshake(); err != nil {
+		return err
+	}
+	if typ != typeCertificate {
+		return newAlert(alertUnexpectedMessage, "expected Certificate")
+	}
+	certs, ok := unmarshalCertificate(msg)
+	if !ok || len(certs) == 0 {
+		return newAlert(alertDecodeError, "invalid Certificate")
+	}
+	pub, err := gostasn1.CertificatePublicKey(certs[0])
+	if err != nil {
+		return newAlert(alertBadCertificate, err.Error())
+	}
+	if config.VerifyPeerCertificate != nil {
+		if err = config.VerifyPeerCertificate(certs, pub); err != nil {
+			return newAlert(alertBadCertificate, err.Error())
+		}
+	}
+	c.peerCerts = certs
+	c.peerPublicKey = pub
+	transcript.Write(msg)
+
+	if typ, msg, err = c.readHandshake(); err != nil {
+		return err
+	}
+	if typ != typeCertificateVerify {
+		return newAlert(alertUnexpectedMessage, "expected CertificateVerify")
+	}
+	scheme, signature, ok := unmarshalCertificateVerify(msg)
+	if !ok {
+		return newAlert(alertDecodeError, "invalid CertificateVerify")
+	}
+	if keyScheme, ok := schemeOf(pub.C, pub.Mode); !ok || keyScheme != scheme {
+		return newAlert(alertIllegalParameter, "signature scheme does not match certificate")
+	}
+	valid, err := pub.VerifyDigest(signedDigest(scheme, transcript.Sum(nil)), signature)
+	if err != nil || !valid {
+		return newAlert(alertDecryptError, "invalid CertificateVerify signature")
+	}
+	transcript.Write(msg)
+
+	if typ, msg, err = c.readHandshake(); err != nil {
+		return err
+	}
+	if typ != typeFinished {
+		return newAlert(alertUnexpectedMessage, "expected Finished")
+	}
+	expected := tls13gost.FinishedVerifyData(h, serverHS, transcript.Sum(nil))
+	if !hmac.Equal(msg[4:], expected) {
+		return newAlert(alertDecryptError, "invalid server Finished")
+	}
+	transcript.Write(msg)
+
+	ks.Master()
+	th = transcript.Sum(nil)
+	clientAP := ks.ClientApplicationTrafficSecret(th)
+	serverAP := ks.ServerApplicationTrafficSecret(th)
+
+	if len(c.hsBuf) > 0 {
+		return newAlert(alertUnexpectedMessage, "data after server Finished")
+	}
+	finished := marshalFinished(tls13gost.FinishedVerifyData(h, clientHS, th))
+	if err = c.writeHandshake(finished); err != nil {
+		return err
+	}
+	if err = c.out.setSecret(c.suite, clientAP); err != nil {
+		return err
+	}
+	return c.in.setSecret(c.suite, serverAP)
+}
@@ -0,0 +1,185 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gosttls
+
+import (
+	"crypto/hmac"
+	"io"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/tls13gost"
+)
+
+func (c *Conn) serverHandshake() error {
+	config := c.config
+	if len(config.Certificate) == 0 || config.PrivateKey == nil {
+		return newAlert(alertInternalError, "server certificate is not configured")
+	}
+	prv := config.PrivateKey
+	scheme, ok := schemeOf(prv.C, prv.Mode)
+	if !ok {
+		return newAlert(alertInternalError, "unsupported server key's curve")
+	}
+
+	typ, msg, err := c.readHandshake()
+	if err != nil {
+		return err
+	}
+	if typ != typeClientHello {
+		return newAlert(alertUnexpectedMessage, "expected ClientHello")
+	}
+	var hello clientHelloMsg
+	if !hello.unmarshal(msg) {
+		return newAlert(alertDecodeError, "invalid ClientHello")
+	}
+	if len(c.hsBuf) > 0 {
+		return newAlert(alertUnexpectedMessage, "data after ClientHello")
+	}
+	tls13 := false
+	for _, v := range hello.supportedVersions {
+		tls13 = tls13 || v == versionTLS13
+	}
+	if !tls13 {
+		return newAlert(alertProtocolVersion, "client does not support TLS 1.3")
+	}
+	var suite tls13gost.CipherSuite
+Suites:
+	for _, ours := range config.cipherSuites() {
+		for _, theirs := range hello.cipherSuites {
+			if uint16(ours) == theirs {
+				suite = ours
+				break Suites
+			}
+		}
+	}
+	if suite == 0 {
+		return newAlert(alertHandshakeFailure, "no common cipher suite")
+	}
+	schemeOffered := false
+	for _, s := range hello.signatureAlgorithms {
+		schemeOffered = schemeOffered || s == scheme
+	}
+	if !schemeOffered {
+		return newAlert(alertHandshakeFailure, "client does not support server key's signature scheme")
+	}
+	var share *keyShare
+Groups:
+	for _, ours := range config.groups() {
+		for i := range hello.keyShares {
+			if hello.keyShares[i].group == ours {
+				share = &hello.keyShares[i]
+				break Groups
+			}
+		}
+	}
+	if share == nil {
+		return newAlert(alertHandshakeFailure, "no key share for supported group")
+	}
+	c.suite = suite
+
+	eph, ephPub, err := generateKeyShare(config, share.group)
+	if err != nil {
+		return err
+	}
+	defer eph.Destroy()
+	shared, err := sharedSecret(eph, share.data)
+	if err != nil {
+		return err
+	}
+	sh := serverHelloMsg{
+		random:           make([]byte, 32),
+		sessionID:        hello.sessionID,
+		cipherSuite:      uint16(suite),
+		supportedVersion: versionTLS13,
+		keyShare:         keyShare{share.group, ephPub},
+	}
+	if _, err = io.ReadFull(config.rand(), sh.random); err != nil {
+		return err
+	}
+	shRaw, err := sh.marshal()
+	if err != nil {
+		return err
+	}
+	transcript := gost34112012256.New()
+	transcript.Write(msg)
+	transcript.Write(shRaw)
+	if err = c.writeHandshake(shRaw); err != nil {
+		return err
+	}
+
+	h := suite.Hash()
+	ks := tls13gost.NewKeySchedule(h, nil)
+	defer ks.Destroy()
+	ks.Handshake(shared)
+	wipe.Bytes(shared)
+	th := transcript.Sum(nil)
+	clientHS := ks.ClientHandshakeTrafficSecret(th)
+	serverHS := ks.ServerHandshakeTrafficSecret(th)
+	defer wipe.Bytes(clientHS)
+	defer wipe.Bytes(serverHS)
+	if err = c.out.setSecret(suite, append([]byte{}, serverHS...)); err != nil {
+		return err
+	}
+	if err = c.in.setSecret(suite, append([]byte{}, clientHS...)); err != nil {
+		return err
+	}
+
+	ee := marshalEncryptedExtensions()
+	transcript.Write(ee)
+	cert, err := marshalCertificate(config.Certificate)
+	if err != nil {
+		return err
+	}
+	transcript.Write(cert)
+	signature, err := prv.SignDigest(signedDigest(scheme, transcript.Sum(nil)), config.rand())
+	if err != nil {
+		return err
+	}
+	cv, err := marshalCertificateVerify(scheme, signature)
+	if err != nil {
+		return err
+	}
+	transcript.Write(cv)
+	finished := marshalFinished(tls13gost.FinishedVerifyData(h, serverHS, transcript.Sum(nil)))
+	transcript.Write(finished)
+	if err = c.writeHandshake(ee, cert, cv, finished); err != nil {
+		return err
+	}
+
+	ks.Master()
+	th = transcript.Sum(nil)
+	clientAP := ks.ClientApplicationTrafficSecret(th)
+	serverAP := ks.ServerApplicationTrafficSecret(th)
+	if err = c.out.setSecret(suite, serverAP); err != nil {
+		return err
+	}
+
+	if typ, msg, err = c.readHandshake(); err != nil {
+		return err
+	}
+	if typ != typeFinished {
+		return newAlert(alertUnexpectedMessage, "expected Finished")
+	}
+	if !hmac.Equal(msg[4:], tls13gost.FinishedVerifyData(h, clientHS, th)) {
+		return newAlert(alertDecryptError, "invalid client Finished")
+	}
+	if len(c.hsBuf) > 0 {
+		return newAlert(alertUnexpectedMessage, "data after client Finished")
+	}
+	return c.in.setSecret(suite, clientAP)
+}
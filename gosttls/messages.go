@@ -0,0 +1,376 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gosttls
+
+import (
+	"golang.org/x/crypto/cryptobyte"
+)
+
+type keyShare struct {
+	group Group
+	data  []byte
+}
+
+type clientHelloMsg struct {
+	random              []byte
+	sessionID           []byte
+	cipherSuites        []uint16
+	serverName          string
+	supportedVersions   []uint16
+	supportedGroups     []Group
+	signatureAlgorithms []SignatureScheme
+	keyShares           []keyShare
+}
+
+func (m *clientHelloMsg) marshal() ([]byte, error) {
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(typeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(versionTLS12)
+		b.AddBytes(m.random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(m.sessionID)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, suite := range m.cipherSuites {
+				b.AddUint16(suite)
+			}
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8(0) // null compression
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			if m.serverName != "" {
+				b.AddUint16(extServerName)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint8(0) // host_name
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							b.AddBytes([]byte(m.serverName))
+						})
+					})
+				})
+			}
+			b.AddUint16(extSupportedVersions)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+					for _, v := range m.supportedVersions {
+						b.AddUint16(v)
+					}
+				})
+			})
+			b.AddUint16(extSupportedGroups)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					for _, g := range m.supportedGroups {
+						b.AddUint16(uint16(g))
+					}
+				})
+			})
+			b.AddUint16(extSignatureAlgorithms)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					for _, s := range m.signatureAlgorithms {
+						b.AddUint16(uint16(s))
+					}
+				})
+			})
+			b.AddUint16(extKeyShare)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					for _, ks := range m.keyShares {
+						b.AddUint16(uint16(ks.group))
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							b.AddBytes(ks.data)
+						})
+					}
+				})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+func (m *clientHelloMsg) unmarshal(data []byte) bool {
+	s := cryptobyte.String(data[4:])
+	var version uint16
+	var compression cryptobyte.String
+	var suites cryptobyte.String
+	if !s.ReadUint16(&version) ||
+		!s.ReadBytes(&m.random, 32) ||
+		!s.ReadUint8LengthPrefixed((*cryptobyte.String)(&m.sessionID)) ||
+		len(m.sessionID) > 32 ||
+		!s.ReadUint16LengthPrefixed(&suites) ||
+		!s.ReadUint8LengthPrefixed(&compression) {
+		return false
+	}
+	for !suites.Empty() {
+		var suite uint16
+		if !suites.ReadUint16(&suite) {
+			return false
+		}
+		m.cipherSuites = append(m.cipherSuites, suite)
+	}
+	if s.Empty() {
+		// No extensions: it is not TLS 1.3 hello
+		return true
+	}
+	var exts cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&exts) || !s.Empty() {
+		return false
+	}
+	for !exts.Empty() {
+		var ext uint16
+		var extData cryptobyte.String
+		if !exts.ReadUint16(&ext) || !exts.ReadUint16LengthPrefixed(&extData) {
+			return false
+		}
+		switch ext {
+		case extServerName:
+			var names cryptobyte.String
+			if !extData.ReadUint16LengthPrefixed(&names) {
+				return false
+			}
+			for !names.Empty() {
+				var nameType uint8
+				var name cryptobyte.String
+				if !names.ReadUint8(&nameType) || !names.ReadUint16LengthPrefixed(&name) {
+					return false
+				}
+				if nameType == 0 {
+					m.serverName = string(name)
+				}
+			}
+		case extSupportedVersions:
+			var versions cryptobyte.String
+			if !extData.ReadUint8LengthPrefixed(&versions) {
+				return false
+			}
+			for !versions.Empty() {
+				var v uint16
+				if !versions.ReadUint16(&v) {
+					return false
+				}
+				m.supportedVersions = append(m.supportedVersions, v)
+			}
+		case extSupportedGroups:
+			var groups cryptobyte.String
+			if !extData.ReadUint16LengthPrefixed(&groups) {
+				return false
+			}
+			for !groups.Empty() {
+				var g uint16
+				if !groups.ReadUint16(&g) {
+					return false
+				}
+				m.supportedGroups = append(m.supportedGroups, Group(g))
+			}
+		case extSignatureAlgorithms:
+			var algs cryptobyte.String
+			if !extData.ReadUint16LengthPrefixed(&algs) {
+				return false
+			}
+			for !algs.Empty() {
+				var alg uint16
+				if !algs.ReadUint16(&alg) {
+					return false
+				}
+				m.signatureAlgorithms = append(m.signatureAlgorithms, SignatureScheme(alg))
+			}
+		case extKeyShare:
+			var shares cryptobyte.String
+			if !extData.ReadUint16LengthPrefixed(&shares) {
+				return false
+			}
+			for !shares.Empty() {
+				var g uint16
+				var ks keyShare
+				if !shares.ReadUint16(&g) ||
+					!shares.ReadUint16LengthPrefixed((*cryptobyte.String)(&ks.data)) {
+					return false
+				}
+				ks.group = Group(g)
+				m.keyShares = append(m.keyShares, ks)
+			}
+		default:
+			continue
+		}
+		if !extData.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+type serverHelloMsg struct {
+	random           []byte
+	sessionID        []byte
+	cipherSuite      uint16
+	supportedVersion uint16
+	keyShare         keyShare
+}
+
+func (m *serverHelloMsg) marshal() ([]byte, error) {
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(typeServerHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(versionTLS12)
+		b.AddBytes(m.random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(m.sessionID)
+		})
+		b.AddUint16(m.cipherSuite)
+		b.AddUint8(0)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(extSupportedVersions)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16(m.supportedVersion)
+			})
+			b.AddUint16(extKeyShare)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16(uint16(m.keyShare.group))
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.keyShare.data)
+				})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+func (m *serverHelloMsg) unmarshal(data []byte) bool {
+	s := cryptobyte.String(data[4:])
+	var version uint16
+	var compression uint8
+	var exts cryptobyte.String
+	if !s.ReadUint16(&version) ||
+		!s.ReadBytes(&m.random, 32) ||
+		!s.ReadUint8LengthPrefixed((*cryptobyte.String)(&m.sessionID)) ||
+		!s.ReadUint16(&m.cipherSuite) ||
+		!s.ReadUint8(&compression) ||
+		compression != 0 {
+		return false
+	}
+	if s.Empty() {
+		return true
+	}
+	if !s.ReadUint16LengthPrefixed(&exts) || !s.Empty() {
+		return false
+	}
+	for !exts.Empty() {
+		var ext uint16
+		var extData cryptobyte.String
+		if !exts.ReadUint16(&ext) || !exts.ReadUint16LengthPrefixed(&extData) {
+			return false
+		}
+		switch ext {
+		case extSupportedVersions:
+			if !extData.ReadUint16(&m.supportedVersion) {
+				return false
+			}
+		case extKeyShare:
+			var g uint16
+			if !extData.ReadUint16(&g) ||
+				!extData.ReadUint16LengthPrefixed((*cryptobyte.String)(&m.keyShare.data)) {
+				return false
+			}
+			m.keyShare.group = Group(g)
+		default:
+			continue
+		}
+		if !extData.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+func marshalEncryptedExtensions() []byte {
+	return []byte{typeEncryptedExtensions, 0, 0, 2, 0, 0}
+}
+
+func unmarshalEncryptedExtensions(data []byte) bool {
+	s := cryptobyte.String(data[4:])
+	var exts cryptobyte.String
+	return s.ReadUint16LengthPrefixed(&exts) && s.Empty()
+}
+
+func marshalCertificate(certs [][]byte) ([]byte, error) {
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(typeCertificate)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(0) // certificate_request_context
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, cert := range certs {
+				b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(cert)
+				})
+				b.AddUint16(0) // extensions
+			}
+		})
+	})
+	return b.Bytes()
+}
+
+func unmarshalCertificate(data []byte) ([][]byte, bool) {
+	s := cryptobyte.String(data[4:])
+	var context, list cryptobyte.String
+	if !s.ReadUint8LengthPrefixed(&context) ||
+		!s.ReadUint24LengthPrefixed(&list) ||
+		!s.Empty() {
+		return nil, false
+	}
+	var certs [][]byte
+	for !list.Empty() {
+		var cert []byte
+		var exts cryptobyte.String
+		if !list.ReadUint24LengthPrefixed((*cryptobyte.String)(&cert)) ||
+			!list.ReadUint16LengthPrefixed(&exts) {
+			return nil, false
+		}
+		certs = append(certs, cert)
+	}
+	return certs, true
+}
+
+func marshalCertificateVerify(scheme SignatureScheme, signature []byte) ([]byte, error) {
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(typeCertificateVerify)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(uint16(scheme))
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(signature)
+		})
+	})
+	return b.Bytes()
+}
+
+func unmarshalCertificateVerify(data []byte) (SignatureScheme, []byte, bool) {
+	s := cryptobyte.String(data[4:])
+	var scheme uint16
+	var signature []byte
+	if !s.ReadUint16(&scheme) ||
+		!s.ReadUint16LengthPrefixed((*cryptobyte.String)(&signature)) ||
+		!s.Empty() {
+		return 0, nil, false
+	}
+	return SignatureScheme(scheme), signature, true
+}
+
+func marshalFinished(verifyData []byte) []byte {
+	return append([]byte{typeFinished, 0, 0, byte(len(verifyData))}, verifyData...)
+}
@@ -0,0 +1,190 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Hybrid public key encryption with GOST algorithms: ephemeral VKO
+// GOST R 34.10-2012 key agreement, KDF_TREE_GOSTR3411_2012_256 key
+// schedule bound to the whole context and MGM AEAD for the payload.
+//
+// Sealed message consists of the suite identifier byte, the ephemeral
+// public key (encoded as gost3410.PublicKey.Raw) and MGM ciphertext with
+// the full blocksize ICV:
+//
+//	suite || enc || ciphertext || icv
+//
+// Encryption key and MGM nonce are derived as:
+//
+//	K = VKO_GOSTR3410_2012_256(ephemeral, recipient, UKM=1)
+//	key || nonce = KDF_TREE_GOSTR3411_2012_256(
+//		K, "gogost hpke", suite || enc || recipient || info, R=1)
+//
+// with the higher bit of the nonce cleared. The header (suite || enc) is
+// also authenticated as MGM's additional data.
+package hpkegost
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/mgm"
+)
+
+const KeySize = 32
+
+var kdfLabel = []byte("gogost hpke")
+
+// AEAD used for the payload encryption.
+type Suite byte
+
+const (
+	KuznyechikMGM Suite = 1
+	MagmaMGM      Suite = 2
+)
+
+func (s Suite) String() string {
+	switch s {
+	case KuznyechikMGM:
+		return "Kuznyechik MGM"
+	case MagmaMGM:
+		return "Magma MGM"
+	}
+	return "unknown"
+}
+
+func (s Suite) blockSize() int {
+	switch s {
+	case KuznyechikMGM:
+		return gost3412128.BlockSize
+	case MagmaMGM:
+		return gost341264.BlockSize
+	}
+	return 0
+}
+
+// Size of the sealed message's overhead over the plaintext for the
+// recipient's key of given mode.
+func (s Suite) Overhead(mode gost3410.Mode) int {
+	return 1 + 2*int(mode) + s.blockSize()
+}
+
+// MGM with the key and nonce derived from the shared secret and the
+// context.
+func (s Suite) keySchedule(shared, header, recipient, info []byte) (cipher.AEAD, []byte, error) {
+	if !strict.Ready() {
+		return nil, nil, strict.ErrNotReady
+	}
+	seed := make([]byte, 0, len(header)+len(recipient)+len(info))
+	seed = append(append(append(seed, header...), recipient...), info...)
+	okm := gost34112012256.KDFTree(shared, kdfLabel, seed, 1, 8*(KeySize+s.blockSize()))
+	defer wipe.Bytes(okm)
+	var block cipher.Block
+	if s == MagmaMGM {
+		block = gost341264.NewCipher(okm[:KeySize])
+	} else {
+		block = gost3412128.NewCipher(okm[:KeySize])
+	}
+	aead, err := mgm.NewMGM(block, block.BlockSize())
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := append([]byte{}, okm[KeySize:]...)
+	nonce[0] &= 0x7F
+	return aead, nonce, nil
+}
+
+// Encrypt plaintext to the recipient's public key, binding it to the
+// info. Each call generates new ephemeral key.
+func (s Suite) Seal(rand io.Reader, pub *gost3410.PublicKey, plaintext, info []byte) ([]byte, error) {
+	if s.blockSize() == 0 {
+		return nil, errors.New("hpkegost: unknown suite")
+	}
+	if !pub.OnCurve() {
+		return nil, errors.New("hpkegost: public key is not on curve")
+	}
+	eph, err := gost3410.GenPrivateKey(pub.C, pub.Mode, rand)
+	if err != nil {
+		return nil, err
+	}
+	defer eph.Destroy()
+	ephPub, err := eph.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.KEK2012256(pub, big.NewInt(1))
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(shared)
+	header := append([]byte{byte(s)}, ephPub.Raw()...)
+	aead, nonce, err := s.keySchedule(shared, header, pub.Raw(), info)
+	if err != nil {
+		return nil, err
+	}
+	defer aead.(*mgm.MGM).Destroy()
+	out := make([]byte, len(header), len(header)+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// Encrypt plaintext to the recipient's public key with Kuznyechik MGM.
+func Seal(rand io.Reader, pub *gost3410.PublicKey, plaintext, info []byte) ([]byte, error) {
+	return KuznyechikMGM.Seal(rand, pub, plaintext, info)
+}
+
+// Decrypt and authenticate the sealed message with the recipient's
+// private key and the same info used during sealing.
+func Open(prv *gost3410.PrivateKey, sealed, info []byte) ([]byte, error) {
+	if len(sealed) == 0 {
+		return nil, errors.New("hpkegost: empty message")
+	}
+	s := Suite(sealed[0])
+	if s.blockSize() == 0 {
+		return nil, errors.New("hpkegost: unknown suite")
+	}
+	if len(sealed) < s.Overhead(prv.Mode) {
+		return nil, errors.New("hpkegost: message is too short")
+	}
+	header := sealed[:1+2*int(prv.Mode)]
+	ephPub, err := gost3410.NewPublicKey(prv.C, prv.Mode, header[1:])
+	if err != nil {
+		return nil, err
+	}
+	if !ephPub.OnCurve() {
+		return nil, errors.New("hpkegost: ephemeral key is not on curve")
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	shared, err := prv.KEK2012256(ephPub, big.NewInt(1))
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(shared)
+	aead, nonce, err := s.keySchedule(shared, header, pub.Raw(), info)
+	if err != nil {
+		return nil, err
+	}
+	defer aead.(*mgm.MGM).Destroy()
+	return aead.Open(nil, nonce, sealed[len(header):], header)
+}
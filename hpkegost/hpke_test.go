@@ -0,0 +1,191 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package hpkegost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"testing/quick"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Sequential bytes starting from the given one: deterministic keys.
+func seq(n int, start byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = start + byte(i)
+	}
+	return b
+}
+
+// Regression values: they are produced by this implementation itself
+// with the deterministic ephemeral key, so they only protect the message
+// format from accidental changes and are not independent test vectors.
+func TestRegression(t *testing.T) {
+	for _, v := range []struct {
+		suite  Suite
+		curve  *gost3410.Curve
+		mode   gost3410.Mode
+		pub    string
+		sealed string
+	}{
+		{
+			KuznyechikMGM,
+			gost3410.CurveIdtc26gost34102012256paramSetA(),
+			gost3410.Mode2001,
+			"000ad8811b8280e56a2c9b37b7170a3de04039df9151482097e3cc0669ecb7a0" +
+				"623f29508cc68b124c3d15a4e2a26e3e71dc391fb2c62d558071878e6814f9a3",
+			"01780b4d737e061084468e428d18c3a3a0429a4b78821b37db9bd5841fcd2cb5" +
+				"fcbce096e45b1a8d656b6de3d7f1abead3a83dc0de0a361b4ee5020e8969ec82" +
+				"8952c767ac6285d6f0ef2859974e9da25c48c03c9e172e624b5df85118",
+		},
+		{
+			MagmaMGM,
+			gost3410.CurveIdtc26gost341012512paramSetA(),
+			gost3410.Mode2012,
+			"6d5710309a4a1f6ab75895d582a54074407e4c3504b396cf0d1cf69d4a02015c" +
+				"1c14fb4fd9f120dfd7521fe32e0aa8a89cfa32993fc6e34b33927d8db563c647" +
+				"84e534a5d11c03cce593cd6322bcaaa8ddd6f476d727ffcd78ba9d6011322a2a" +
+				"1216f8c68eb76be0125552e15b4e9f0595a612b7f5b43af88f17323f2e1d3214",
+			"02690feb963f5539f0086bcb3c49a56ad861c476cc7d94fe37b70735c157702f" +
+				"5d1959399b25d4b95fa0545f2ac8aac1dbe3b4ab84c17d0b1af13346f295a1c1" +
+				"224241bedce80c9b4a8f32c0e2d9db2b645bb4f736938e3c004fcfde0f86ecc4" +
+				"7b0284d7e4696ce3c8a67a1032dcc2ae0b13ff191fbfd8f7bfdb2ccd6cf12dbb" +
+				"2d08dc6df0521778d26ce10bab88719e82c5fd3dd0",
+		},
+	} {
+		plaintext := []byte("Hello, GOST!")
+		info := []byte("info")
+		prv, err := gost3410.NewPrivateKey(v.curve, v.mode, seq(int(v.mode), 0x01))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := prv.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(pub.Raw(), unhex(v.pub)) != 0 {
+			t.Fatal(v.suite, "public key")
+		}
+		sealed, err := v.suite.Seal(bytes.NewReader(seq(int(v.mode), 0x41)), pub, plaintext, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(sealed, unhex(v.sealed)) != 0 {
+			t.Fatal(v.suite, "sealed")
+		}
+		if len(sealed) != len(plaintext)+v.suite.Overhead(v.mode) {
+			t.Fatal(v.suite, "overhead")
+		}
+		opened, err := Open(prv, sealed, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(opened, plaintext) != 0 {
+			t.Fatal(v.suite, "opened")
+		}
+	}
+}
+
+func TestSymmetric(t *testing.T) {
+	prv, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost34102012512paramSetC(),
+		gost3410.Mode2012,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := func(plaintext, info []byte) bool {
+		sealed, err := Seal(rand.Reader, pub, plaintext, info)
+		if err != nil {
+			return false
+		}
+		opened, err := Open(prv, sealed, info)
+		if err != nil {
+			return false
+		}
+		return bytes.Compare(opened, plaintext) == 0
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 20}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenFailures(t *testing.T) {
+	curve := gost3410.CurveIdtc26gost34102012256paramSetA()
+	prv, err := gost3410.GenPrivateKey(curve, gost3410.Mode2001, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Seal(rand.Reader, pub, []byte("payload"), []byte("info"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(prv, sealed, []byte("other info")); err == nil {
+		t.Fatal("info")
+	}
+	other, err := gost3410.GenPrivateKey(curve, gost3410.Mode2001, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(other, sealed, []byte("info")); err == nil {
+		t.Fatal("other key")
+	}
+	for i := range sealed {
+		tampered := append([]byte{}, sealed...)
+		tampered[i] ^= 0x01
+		if _, err = Open(prv, tampered, []byte("info")); err == nil {
+			t.Fatal("tampered", i)
+		}
+	}
+	if _, err = Open(prv, sealed[:len(sealed)-1], []byte("info")); err == nil {
+		t.Fatal("truncated")
+	}
+	if _, err = Open(prv, sealed[:MagmaMGM.Overhead(gost3410.Mode2001)-1], []byte("info")); err == nil {
+		t.Fatal("too short")
+	}
+	eph, err := gost3410.NewPublicKey(curve, gost3410.Mode2001, sealed[1:1+2*32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	eph.Y.Add(eph.Y, big.NewInt(1))
+	offCurve := append(append([]byte{sealed[0]}, eph.Raw()...), sealed[1+2*32:]...)
+	if _, err = Open(prv, offCurve, []byte("info")); err == nil {
+		t.Fatal("off curve")
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 const (
@@ -65,16 +67,6 @@ var (
 		0x59, 0xa6, 0x74, 0xd2, 0xe6, 0xf4, 0xb4, 0xc0,
 		0xd1, 0x66, 0xaf, 0xc2, 0x39, 0x4b, 0x63, 0xb6,
 	}
-	tau [64]int = [64]int{
-		0x00, 0x08, 0x10, 0x18, 0x20, 0x28, 0x30, 0x38,
-		0x01, 0x09, 0x11, 0x19, 0x21, 0x29, 0x31, 0x39,
-		0x02, 0x0a, 0x12, 0x1a, 0x22, 0x2a, 0x32, 0x3a,
-		0x03, 0x0b, 0x13, 0x1b, 0x23, 0x2b, 0x33, 0x3b,
-		0x04, 0x0c, 0x14, 0x1c, 0x24, 0x2c, 0x34, 0x3c,
-		0x05, 0x0d, 0x15, 0x1d, 0x25, 0x2d, 0x35, 0x3d,
-		0x06, 0x0e, 0x16, 0x1e, 0x26, 0x2e, 0x36, 0x3e,
-		0x07, 0x0f, 0x17, 0x1f, 0x27, 0x2f, 0x37, 0x3f,
-	}
 	c [12][BlockSize]byte = [12][BlockSize]byte{
 		[BlockSize]byte{
 			0x07, 0x45, 0xa6, 0xf2, 0x59, 0x65, 0x80, 0xdd,
@@ -197,7 +189,10 @@ var (
 			0xba, 0x31, 0x16, 0xf1, 0x67, 0xe7, 0x8e, 0x37,
 		},
 	}
-	a [64]uint64 // It is filled in init()
+	// LPS transformation tables: substitution, permutation and
+	// linear transformation of every byte position are precomputed
+	lpsTable [8][256]uint64 // It is filled in init()
+	cw       [12][8]uint64  // Words of c, filled in init()
 )
 
 func init() {
@@ -267,18 +262,37 @@ func init() {
 		[]byte{0xc8, 0x38, 0x62, 0x96, 0x56, 0x01, 0xdd, 0x1b},
 		[]byte{0x64, 0x1c, 0x31, 0x4b, 0x2b, 0x8e, 0xe0, 0x83},
 	}
+	var a [64]uint64
 	for i := 0; i < 64; i++ {
 		a[i] = binary.BigEndian.Uint64(as[i])
 	}
+	var v, r uint64
+	var j int
+	for k := 0; k < 8; k++ {
+		for b := 0; b < 256; b++ {
+			v = uint64(pi[b]) << uint(8*k)
+			r = 0
+			for j = 0; j < 64; j++ {
+				if v&0x8000000000000000 > 0 {
+					r ^= a[j]
+				}
+				v <<= 1
+			}
+			lpsTable[k][b] = r
+		}
+	}
+	for i := 0; i < 12; i++ {
+		block2words(&cw[i], c[i][:])
+	}
 }
 
 type Hash struct {
-	size int
-	buf  []byte
-	n    uint64
-	hsh  *[BlockSize]byte
-	chk  *[BlockSize]byte
-	tmp  *[BlockSize]byte
+	size   int
+	buf    [BlockSize]byte
+	bufLen int
+	n      uint64
+	hsh    [8]uint64
+	chk    [8]uint64
 }
 
 // Create new hash object with specified size digest size.
@@ -286,23 +300,19 @@ func New(size int) *Hash {
 	if size != 32 && size != 64 {
 		panic("size must be either 32 or 64")
 	}
-	h := Hash{
-		size: size,
-		hsh:  new([BlockSize]byte),
-		chk:  new([BlockSize]byte),
-		tmp:  new([BlockSize]byte),
-	}
+	h := Hash{size: size}
 	h.Reset()
 	return &h
 }
 
 func (h *Hash) Reset() {
 	h.n = 0
-	h.buf = nil
-	for i := 0; i < BlockSize; i++ {
+	h.bufLen = 0
+	wipe.Bytes(h.buf[:])
+	for i := 0; i < 8; i++ {
 		h.chk[i] = 0
 		if h.size == 32 {
-			h.hsh[i] = 1
+			h.hsh[i] = 0x0101010101010101
 		} else {
 			h.hsh[i] = 0
 		}
@@ -317,112 +327,140 @@ func (h *Hash) Size() int {
 	return h.size
 }
 
-func (h *Hash) Write(data []byte) (int, error) {
-	h.buf = append(h.buf, data...)
-	for len(h.buf) >= BlockSize {
-		copy(h.tmp[:], h.buf[:BlockSize])
-		h.hsh = g(h.n, h.hsh, h.tmp)
-		h.chk = add512bit(h.chk, h.tmp)
-		h.n += BlockSize * 8
-		h.buf = h.buf[BlockSize:]
+func block2words(dst *[8]uint64, src []byte) {
+	for i := 0; i < 8; i++ {
+		dst[i] = binary.LittleEndian.Uint64(src[i*8 : i*8+8])
 	}
-	return len(data), nil
 }
 
-func (h *Hash) Sum(in []byte) []byte {
-	buf := new([BlockSize]byte)
-	copy(h.tmp[:], buf[:])
-	copy(buf[:], h.buf[:])
-	buf[len(h.buf)] = 1
-	hsh := g(h.n, h.hsh, buf)
-	binary.LittleEndian.PutUint64(h.tmp[:], h.n+uint64(len(h.buf))*8)
-	hsh = g(0, hsh, h.tmp)
-	hsh = g(0, hsh, add512bit(h.chk, buf))
-	if h.size == 32 {
-		return append(in, hsh[BlockSize/2:]...)
+func words2block(dst []byte, src *[8]uint64) {
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint64(dst[i*8:i*8+8], src[i])
 	}
-	return append(in, hsh[:]...)
 }
 
-func add512bit(chk, data *[BlockSize]byte) *[BlockSize]byte {
-	var ss uint16
-	r := new([BlockSize]byte)
-	for i := 0; i < BlockSize; i++ {
-		ss = uint16(chk[i]) + uint16(data[i]) + (ss >> 8)
-		r[i] = byte(0xFF & ss)
-	}
-	return r
+func (h *Hash) compress(data []byte) {
+	var m [8]uint64
+	block2words(&m, data)
+	g(&h.hsh, h.n, &m)
+	add512bit(&h.chk, &m)
+	h.n += BlockSize * 8
+	wipe.Uint64s(m[:])
 }
 
-func g(n uint64, hsh, data *[BlockSize]byte) *[BlockSize]byte {
-	ns := make([]byte, 8)
-	binary.LittleEndian.PutUint64(ns, n)
-	r := new([BlockSize]byte)
-	for i := 0; i < 8; i++ {
-		r[i] = hsh[i] ^ ns[i]
+func (h *Hash) Write(data []byte) (int, error) {
+	written := len(data)
+	if h.bufLen > 0 {
+		n := copy(h.buf[h.bufLen:], data)
+		h.bufLen += n
+		data = data[n:]
+		if h.bufLen < BlockSize {
+			return written, nil
+		}
+		h.compress(h.buf[:])
+		h.bufLen = 0
+	}
+	for len(data) >= BlockSize {
+		h.compress(data[:BlockSize])
+		data = data[BlockSize:]
 	}
-	copy(r[8:], hsh[8:])
-	return blockXor(blockXor(e(l(ps(r)), data), hsh), data)
+	h.bufLen = copy(h.buf[:], data)
+	return written, nil
 }
 
-func e(k, msg *[BlockSize]byte) *[BlockSize]byte {
-	for i := 0; i < 12; i++ {
-		msg = l(ps(blockXor(k, msg)))
-		k = l(ps(blockXor(k, &c[i])))
+func (h *Hash) Sum(in []byte) []byte {
+	var buf [BlockSize]byte
+	var m [8]uint64
+	copy(buf[:], h.buf[:h.bufLen])
+	buf[h.bufLen] = 1
+	block2words(&m, buf[:])
+	hsh := h.hsh
+	g(&hsh, h.n, &m)
+	n := [8]uint64{h.n + uint64(h.bufLen)*8}
+	g(&hsh, 0, &n)
+	chk := h.chk
+	add512bit(&chk, &m)
+	g(&hsh, 0, &chk)
+	words2block(buf[:], &hsh)
+	if h.size == 32 {
+		in = append(in, buf[BlockSize/2:]...)
+	} else {
+		in = append(in, buf[:]...)
 	}
-	return blockXor(k, msg)
+	wipe.Bytes(buf[:])
+	wipe.Uint64s(m[:])
+	wipe.Uint64s(hsh[:])
+	wipe.Uint64s(chk[:])
+	return in
 }
 
-func blockXor(x, y *[BlockSize]byte) *[BlockSize]byte {
-	r := new([BlockSize]byte)
-	for i := 0; i < BlockSize; i++ {
-		r[i] = x[i] ^ y[i]
+func add512bit(chk, data *[8]uint64) {
+	var carry, s uint64
+	for i := 0; i < 8; i++ {
+		s = chk[i] + carry
+		if s < carry {
+			carry = 1
+		} else {
+			carry = 0
+		}
+		s += data[i]
+		if s < data[i] {
+			carry = 1
+		}
+		chk[i] = s
 	}
-	return r
 }
 
-func ps(data *[BlockSize]byte) *[BlockSize]byte {
-	r := new([BlockSize]byte)
-	for i := 0; i < BlockSize; i++ {
-		r[tau[i]] = pi[int(data[i])]
+// Compression function itself. hsh is updated in place.
+func g(hsh *[8]uint64, n uint64, data *[8]uint64) {
+	var k, msg [8]uint64
+	nBlk := [8]uint64{n}
+	xlps(&k, hsh, &nBlk)
+	msg = *data
+	for i := 0; i < 12; i++ {
+		xlps(&msg, &k, &msg)
+		xlps(&k, &k, &cw[i])
+	}
+	for i := 0; i < 8; i++ {
+		hsh[i] ^= msg[i] ^ k[i] ^ data[i]
 	}
-	return r
+	wipe.Uint64s(k[:])
+	wipe.Uint64s(msg[:])
 }
 
-func l(data *[BlockSize]byte) *[BlockSize]byte {
-	var val uint64
-	var res64 uint64
-	var j int
-	r := new([BlockSize]byte)
+// LPS transformation of x xor y, written to dst.
+func xlpsGeneric(dst, x, y *[8]uint64) {
+	var t [8]uint64
 	for i := 0; i < 8; i++ {
-		val = binary.LittleEndian.Uint64(data[i*8 : i*8+8])
-		res64 = 0
-		for j = 0; j < BlockSize; j++ {
-			if val&0x8000000000000000 > 0 {
-				res64 ^= a[j]
-			}
-			val <<= 1
-		}
-		binary.LittleEndian.PutUint64(r[i*8:i*8+8], res64)
+		t[i] = x[i] ^ y[i]
+	}
+	for i := uint(0); i < 8; i++ {
+		dst[i] = lpsTable[0][byte(t[0]>>(8*i))] ^
+			lpsTable[1][byte(t[1]>>(8*i))] ^
+			lpsTable[2][byte(t[2]>>(8*i))] ^
+			lpsTable[3][byte(t[3]>>(8*i))] ^
+			lpsTable[4][byte(t[4]>>(8*i))] ^
+			lpsTable[5][byte(t[5]>>(8*i))] ^
+			lpsTable[6][byte(t[6]>>(8*i))] ^
+			lpsTable[7][byte(t[7]>>(8*i))]
 	}
-	return r
 }
 
 func (h *Hash) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, len(MarshaledName)+1+8+3*BlockSize+len(h.buf))
+	data = make([]byte, len(MarshaledName)+1+8+3*BlockSize+h.bufLen)
 	copy(data, []byte(MarshaledName))
 	idx := len(MarshaledName)
 	data[idx] = byte(h.size)
 	idx += 1
 	binary.BigEndian.PutUint64(data[idx:idx+8], h.n)
 	idx += 8
-	copy(data[idx:], h.hsh[:])
+	words2block(data[idx:], &h.hsh)
 	idx += BlockSize
-	copy(data[idx:], h.chk[:])
+	words2block(data[idx:], &h.chk)
 	idx += BlockSize
-	copy(data[idx:], h.tmp[:])
+	// Place of the former temporary buffer, kept for compatibility
 	idx += BlockSize
-	copy(data[idx:], h.buf)
+	copy(data[idx:], h.buf[:h.bufLen])
 	return
 }
 
@@ -430,6 +468,9 @@ func (h *Hash) UnmarshalBinary(data []byte) error {
 	if len(data) < len(MarshaledName)+1+8+3*BlockSize {
 		return errors.New("too short data")
 	}
+	if len(data) >= len(MarshaledName)+1+8+4*BlockSize {
+		return errors.New("too long data")
+	}
 	if !bytes.HasPrefix(data, []byte(MarshaledName)) {
 		return errors.New("no hash name prefix")
 	}
@@ -438,12 +479,11 @@ func (h *Hash) UnmarshalBinary(data []byte) error {
 	idx += 1
 	h.n = binary.BigEndian.Uint64(data[idx : idx+8])
 	idx += 8
-	copy(h.hsh[:], data[idx:])
+	block2words(&h.hsh, data[idx:])
 	idx += BlockSize
-	copy(h.chk[:], data[idx:])
+	block2words(&h.chk, data[idx:])
 	idx += BlockSize
-	copy(h.tmp[:], data[idx:])
 	idx += BlockSize
-	h.buf = data[idx:]
+	h.bufLen = copy(h.buf[:], data[idx:])
 	return nil
 }
@@ -221,6 +221,37 @@ func TestRandom(t *testing.T) {
 	}
 }
 
+func TestXLPS(t *testing.T) {
+	f := func(x, y [8]uint64) bool {
+		var got, expected [8]uint64
+		xlps(&got, &x, &y)
+		xlpsGeneric(&expected, &x, &y)
+		if got != expected {
+			return false
+		}
+		xlps(&x, &x, &y)
+		return x == expected
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalCompatibility(t *testing.T) {
+	h := New(32)
+	h.Write([]byte("foobar"))
+	raw, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != len(MarshaledName)+1+8+3*BlockSize+6 {
+		t.FailNow()
+	}
+	if err = h.UnmarshalBinary(append(raw, make([]byte, BlockSize)...)); err == nil {
+		t.FailNow()
+	}
+}
+
 func BenchmarkHash(b *testing.B) {
 	h := New(64)
 	src := make([]byte, BlockSize+1)
@@ -231,3 +262,14 @@ func BenchmarkHash(b *testing.B) {
 		h.Sum(nil)
 	}
 }
+
+func BenchmarkHashLarge(b *testing.B) {
+	h := New(64)
+	src := make([]byte, 8192)
+	rand.Read(src)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write(src)
+	}
+}
@@ -0,0 +1,48 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gost34112012
+
+import (
+	"crypto/hmac"
+	"hash"
+
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+// PRF_TLS_GOSTR3411_2012_256/512 (RFC 7836): TLS P_hash over HMAC with
+// the given hash of label || seed. Output is size bytes long.
+func PRF(newHash func() hash.Hash, secret, label, seed []byte, size int) []byte {
+	labelSeed := make([]byte, len(label)+len(seed))
+	copy(labelSeed, label)
+	copy(labelSeed[len(label):], seed)
+	mac := hmac.New(newHash, secret)
+	mac.Write(labelSeed)
+	a := mac.Sum(nil)
+	out := make([]byte, 0, size+mac.Size())
+	for len(out) < size {
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(labelSeed)
+		out = mac.Sum(out)
+		mac.Reset()
+		mac.Write(a)
+		a = mac.Sum(a[:0])
+	}
+	wipe.Bytes(a)
+	wipe.Bytes(out[size:])
+	return out[:size]
+}
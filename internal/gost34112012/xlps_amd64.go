@@ -0,0 +1,27 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+package gost34112012
+
+// LPS transformation of x xor y, written to dst. Only XOR is done with
+// SSE2 instructions, table lookups are scalar, just fully unrolled.
+// AVX2 gathers of the lookups were measured slower than that.
+//
+//go:noescape
+func xlps(dst, x, y *[8]uint64)
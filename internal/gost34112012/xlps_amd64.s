@@ -0,0 +1,75 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+#include "textflag.h"
+
+// Output word off/8 of LPS: byte i of every intermediate word (kept on
+// the stack) indexes the corresponding lpsTable row.
+#define ROW(i, off) \
+	MOVBQZX (0*8+i)(SP), AX; \
+	MOVQ    (0*2048)(R8)(AX*8), BX; \
+	MOVBQZX (1*8+i)(SP), AX; \
+	XORQ    (1*2048)(R8)(AX*8), BX; \
+	MOVBQZX (2*8+i)(SP), AX; \
+	XORQ    (2*2048)(R8)(AX*8), BX; \
+	MOVBQZX (3*8+i)(SP), AX; \
+	XORQ    (3*2048)(R8)(AX*8), BX; \
+	MOVBQZX (4*8+i)(SP), AX; \
+	XORQ    (4*2048)(R8)(AX*8), BX; \
+	MOVBQZX (5*8+i)(SP), AX; \
+	XORQ    (5*2048)(R8)(AX*8), BX; \
+	MOVBQZX (6*8+i)(SP), AX; \
+	XORQ    (6*2048)(R8)(AX*8), BX; \
+	MOVBQZX (7*8+i)(SP), AX; \
+	XORQ    (7*2048)(R8)(AX*8), BX; \
+	MOVQ    BX, off(DI)
+
+// func xlps(dst, x, y *[8]uint64)
+TEXT ·xlps(SB), NOSPLIT, $64-24
+	MOVQ dst+0(FP), DI
+	MOVQ x+8(FP), SI
+	MOVQ y+16(FP), DX
+
+	MOVOU 0(SI), X0
+	MOVOU 16(SI), X1
+	MOVOU 32(SI), X2
+	MOVOU 48(SI), X3
+	MOVOU 0(DX), X4
+	MOVOU 16(DX), X5
+	MOVOU 32(DX), X6
+	MOVOU 48(DX), X7
+	PXOR  X4, X0
+	PXOR  X5, X1
+	PXOR  X6, X2
+	PXOR  X7, X3
+	MOVOU X0, 0(SP)
+	MOVOU X1, 16(SP)
+	MOVOU X2, 32(SP)
+	MOVOU X3, 48(SP)
+
+	LEAQ ·lpsTable(SB), R8
+	ROW(0, 0)
+	ROW(1, 8)
+	ROW(2, 16)
+	ROW(3, 24)
+	ROW(4, 32)
+	ROW(5, 40)
+	ROW(6, 48)
+	ROW(7, 56)
+	RET
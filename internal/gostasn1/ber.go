@@ -0,0 +1,169 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gostasn1
+
+import (
+	"errors"
+)
+
+// PFX and CMS files are often BER-encoded (indefinite lengths,
+// constructed strings), but encoding/asn1 understands only DER. BER2DER
+// rewrites lengths in definite minimal form and joins constructed OCTET
+// STRINGs. It does not reorder SET OF elements.
+func BER2DER(ber []byte) ([]byte, error) {
+	der, rest, err := berElement(ber, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after BER")
+	}
+	return der, nil
+}
+
+const berMaxDepth = 64
+
+var errBER = errors.New("invalid BER encoding")
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var l []byte
+	for ; n > 0; n >>= 8 {
+		l = append([]byte{byte(n)}, l...)
+	}
+	return append([]byte{0x80 | byte(len(l))}, l...)
+}
+
+// Convert single element, return it and the rest of data.
+func berElement(b []byte, depth int) ([]byte, []byte, error) {
+	if depth > berMaxDepth || len(b) < 2 {
+		return nil, nil, errBER
+	}
+	tagLen := 1
+	if b[0]&0x1F == 0x1F {
+		for {
+			if tagLen >= len(b) {
+				return nil, nil, errBER
+			}
+			tagLen++
+			if b[tagLen-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	tag := b[:tagLen]
+	constructed := b[0]&0x20 != 0
+	b = b[tagLen:]
+	if len(b) == 0 {
+		return nil, nil, errBER
+	}
+	var content []byte
+	indefinite := false
+	switch l := int(b[0]); {
+	case l < 0x80:
+		b = b[1:]
+		if len(b) < l {
+			return nil, nil, errBER
+		}
+		content, b = b[:l], b[l:]
+	case l == 0x80:
+		if !constructed {
+			return nil, nil, errBER
+		}
+		indefinite = true
+		b = b[1:]
+	default:
+		n := l & 0x7F
+		if n > 4 || len(b) < 1+n {
+			return nil, nil, errBER
+		}
+		l = 0
+		for _, c := range b[1 : 1+n] {
+			l = l<<8 | int(c)
+		}
+		b = b[1+n:]
+		if l < 0 || len(b) < l {
+			return nil, nil, errBER
+		}
+		content, b = b[:l], b[l:]
+	}
+	if !constructed {
+		out := append(append([]byte{}, tag...), berLength(len(content))...)
+		return append(out, content...), b, nil
+	}
+	if indefinite {
+		content = b
+	}
+	var children [][]byte
+	for {
+		if indefinite {
+			if len(content) < 2 {
+				return nil, nil, errBER
+			}
+			if content[0] == 0 && content[1] == 0 {
+				b = content[2:]
+				break
+			}
+		} else if len(content) == 0 {
+			break
+		}
+		child, r, err := berElement(content, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child)
+		content = r
+	}
+	var inner []byte
+	// Constructed universal OCTET STRING becomes primitive one
+	if len(tag) == 1 && tag[0] == 0x24 {
+		for _, child := range children {
+			if child[0] != 0x04 {
+				return nil, nil, errBER
+			}
+			_, v, err := splitDER(child)
+			if err != nil {
+				return nil, nil, err
+			}
+			inner = append(inner, v...)
+		}
+		out := append([]byte{0x04}, berLength(len(inner))...)
+		return append(out, inner...), b, nil
+	}
+	for _, child := range children {
+		inner = append(inner, child...)
+	}
+	out := append(append([]byte{}, tag...), berLength(len(inner))...)
+	return append(out, inner...), b, nil
+}
+
+// Split DER element to header and its value.
+func splitDER(der []byte) ([]byte, []byte, error) {
+	if len(der) < 2 {
+		return nil, nil, errBER
+	}
+	hdrLen := 2
+	if der[1]&0x80 != 0 {
+		hdrLen += int(der[1] & 0x7F)
+	}
+	if len(der) < hdrLen {
+		return nil, nil, errBER
+	}
+	return der[:hdrLen], der[hdrLen:], nil
+}
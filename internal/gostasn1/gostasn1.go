@@ -0,0 +1,202 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// ASN.1 structures and object identifiers of GOST R 34.10-2012 public
+// keys (RFC 4491, RFC 9215).
+package gostasn1
+
+import (
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+var (
+	OIDGost34102001    = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 19}
+	OIDGost34102012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 1}
+	OIDGost34102012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 2}
+	OIDGost34112012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 2, 2}
+	OIDGost34112012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 2, 3}
+
+	OIDGost341194WithGost34102001    = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 3}
+	OIDSignWithDigestGost34102012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 2}
+	OIDSignWithDigestGost34102012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 3}
+
+	curves = []struct {
+		oid   asn1.ObjectIdentifier
+		curve func() *gost3410.Curve
+		// CryptoPro's curves require digest parameters
+		withDigest bool
+	}{
+		{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 35, 1}, gost3410.CurveIdGostR34102001CryptoProAParamSet, true},
+		{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 35, 2}, gost3410.CurveIdGostR34102001CryptoProBParamSet, true},
+		{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 35, 3}, gost3410.CurveIdGostR34102001CryptoProCParamSet, true},
+		{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 36, 0}, gost3410.CurveIdGostR34102001CryptoProXchAParamSet, true},
+		{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 36, 1}, gost3410.CurveIdGostR34102001CryptoProXchBParamSet, true},
+		{asn1.ObjectIdentifier{1, 2, 643, 7, 1, 2, 1, 1, 1}, gost3410.CurveIdtc26gost34102012256paramSetA, false},
+		{asn1.ObjectIdentifier{1, 2, 643, 7, 1, 2, 1, 2, 1}, gost3410.CurveIdtc26gost341012512paramSetA, false},
+		{asn1.ObjectIdentifier{1, 2, 643, 7, 1, 2, 1, 2, 2}, gost3410.CurveIdtc26gost341012512paramSetB, false},
+		{asn1.ObjectIdentifier{1, 2, 643, 7, 1, 2, 1, 2, 3}, gost3410.CurveIdtc26gost34102012512paramSetC, false},
+	}
+)
+
+// Object identifier of the curve's parameters.
+func CurveOID(curve *gost3410.Curve) (asn1.ObjectIdentifier, error) {
+	for _, c := range curves {
+		if c.curve().Name == curve.Name {
+			return c.oid, nil
+		}
+	}
+	return nil, errors.New("unsupported curve")
+}
+
+// Curve by its parameters object identifier.
+func CurveByOID(oid asn1.ObjectIdentifier) (*gost3410.Curve, error) {
+	for _, c := range curves {
+		if c.oid.Equal(oid) {
+			return c.curve(), nil
+		}
+	}
+	return nil, errors.New("unsupported curve")
+}
+
+type PublicKeyParameters struct {
+	PublicKeyParamSet  asn1.ObjectIdentifier
+	DigestParamSet     asn1.ObjectIdentifier `asn1:"optional"`
+	EncryptionParamSet asn1.ObjectIdentifier `asn1:"optional"`
+}
+
+type PublicKeyAlgorithm struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters PublicKeyParameters
+}
+
+type SubjectPublicKeyInfo struct {
+	Algorithm        PublicKeyAlgorithm
+	SubjectPublicKey asn1.BitString
+}
+
+func publicKeyAlgorithm(curve *gost3410.Curve, mode gost3410.Mode) (PublicKeyAlgorithm, error) {
+	var algo PublicKeyAlgorithm
+	for _, c := range curves {
+		if c.curve().Name != curve.Name {
+			continue
+		}
+		algo.Parameters.PublicKeyParamSet = c.oid
+		if mode == gost3410.Mode2012 {
+			algo.Algorithm = OIDGost34102012512
+		} else {
+			algo.Algorithm = OIDGost34102012256
+			if c.withDigest {
+				algo.Parameters.DigestParamSet = OIDGost34112012256
+			}
+		}
+		return algo, nil
+	}
+	return algo, errors.New("unsupported curve")
+}
+
+// Key mode and curve of the public key algorithm. GOST R 34.10-2001 keys
+// are also accepted.
+func keyParams(algo *PublicKeyAlgorithm) (*gost3410.Curve, gost3410.Mode, error) {
+	var mode gost3410.Mode
+	switch {
+	case algo.Algorithm.Equal(OIDGost34102012256), algo.Algorithm.Equal(OIDGost34102001):
+		mode = gost3410.Mode2001
+	case algo.Algorithm.Equal(OIDGost34102012512):
+		mode = gost3410.Mode2012
+	default:
+		return nil, mode, errors.New("unsupported public key algorithm")
+	}
+	curve, err := CurveByOID(algo.Parameters.PublicKeyParamSet)
+	return curve, mode, err
+}
+
+func MarshalPublicKey(pub *gost3410.PublicKey) (SubjectPublicKeyInfo, error) {
+	var spki SubjectPublicKeyInfo
+	algo, err := publicKeyAlgorithm(pub.C, pub.Mode)
+	if err != nil {
+		return spki, err
+	}
+	spki.Algorithm = algo
+	raw, err := asn1.Marshal(pub.Raw())
+	if err != nil {
+		return spki, err
+	}
+	spki.SubjectPublicKey = asn1.BitString{Bytes: raw, BitLength: 8 * len(raw)}
+	return spki, nil
+}
+
+func ParsePublicKey(spki *SubjectPublicKeyInfo) (*gost3410.PublicKey, error) {
+	curve, mode, err := keyParams(&spki.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	rest, err := asn1.Unmarshal(spki.SubjectPublicKey.RightAlign(), &raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after public key")
+	}
+	pub, err := gost3410.NewPublicKey(curve, mode, raw)
+	if err != nil {
+		return nil, err
+	}
+	if !pub.OnCurve() {
+		return nil, errors.New("public key is not on the curve")
+	}
+	return pub, nil
+}
+
+type tbsCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm asn1.RawValue
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	IssuerUniqueID     asn1.BitString `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString `asn1:"optional,tag:2"`
+	Extensions         asn1.RawValue  `asn1:"optional,explicit,tag:3"`
+}
+
+type certificate struct {
+	TBSCertificate     tbsCertificate
+	SignatureAlgorithm asn1.RawValue
+	Signature          asn1.BitString
+}
+
+// Extract GOST public key from DER-encoded X.509 certificate.
+func CertificatePublicKey(der []byte) (*gost3410.PublicKey, error) {
+	var cert certificate
+	rest, err := asn1.Unmarshal(der, &cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after certificate")
+	}
+	var spki SubjectPublicKeyInfo
+	if _, err = asn1.Unmarshal(cert.TBSCertificate.PublicKey.FullBytes, &spki); err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(&spki)
+}
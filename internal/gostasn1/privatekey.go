@@ -0,0 +1,89 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gostasn1
+
+import (
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+// PKCS #8 PrivateKeyInfo.
+type privateKeyInfo struct {
+	Version    int
+	Algorithm  PublicKeyAlgorithm
+	PrivateKey []byte
+	Attributes asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// Marshal private key to PKCS #8 PrivateKeyInfo. The key itself is
+// encoded as little-endian OCTET STRING.
+func MarshalPrivateKey(prv *gost3410.PrivateKey) ([]byte, error) {
+	algo, err := publicKeyAlgorithm(prv.C, prv.Mode)
+	if err != nil {
+		return nil, err
+	}
+	raw := prv.Raw()
+	defer wipe.Bytes(raw)
+	key, err := asn1.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(key)
+	return asn1.Marshal(privateKeyInfo{Algorithm: algo, PrivateKey: key})
+}
+
+// Parse PKCS #8 PrivateKeyInfo. The key may be encoded as little-endian
+// OCTET STRING, big-endian INTEGER or as bare little-endian value.
+func ParsePrivateKey(der []byte) (*gost3410.PrivateKey, error) {
+	var info privateKeyInfo
+	rest, err := asn1.Unmarshal(der, &info)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after private key")
+	}
+	curve, mode, err := keyParams(&info.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	var i *big.Int
+	if rest, err = asn1.Unmarshal(info.PrivateKey, &raw); err == nil && len(rest) == 0 {
+		defer wipe.Bytes(raw)
+		return gost3410.NewPrivateKey(curve, mode, raw)
+	}
+	if rest, err = asn1.Unmarshal(info.PrivateKey, &i); err == nil && len(rest) == 0 {
+		defer wipe.BigInt(i)
+		if i.Sign() <= 0 || i.BitLen() > 8*int(mode) {
+			return nil, errors.New("invalid private key")
+		}
+		raw = make([]byte, int(mode))
+		defer wipe.Bytes(raw)
+		b := i.Bytes()
+		defer wipe.Bytes(b)
+		for n := 0; n < len(b); n++ {
+			raw[n] = b[len(b)-n-1]
+		}
+		return gost3410.NewPrivateKey(curve, mode, raw)
+	}
+	return gost3410.NewPrivateKey(curve, mode, info.PrivateKey)
+}
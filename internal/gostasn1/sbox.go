@@ -0,0 +1,56 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gostasn1
+
+import (
+	"encoding/asn1"
+	"errors"
+
+	"github.com/ddulesov/gogost/gost28147"
+)
+
+var sboxes = []struct {
+	oid  asn1.ObjectIdentifier
+	sbox *gost28147.Sbox
+}{
+	{asn1.ObjectIdentifier{1, 2, 643, 7, 1, 2, 5, 1, 1}, &gost28147.SboxIdtc26gost28147paramZ},
+	{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 31, 0}, &gost28147.SboxIdGost2814789TestParamSet},
+	{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 31, 1}, &gost28147.SboxIdGost2814789CryptoProAParamSet},
+	{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 31, 2}, &gost28147.SboxIdGost2814789CryptoProBParamSet},
+	{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 31, 3}, &gost28147.SboxIdGost2814789CryptoProCParamSet},
+	{asn1.ObjectIdentifier{1, 2, 643, 2, 2, 31, 4}, &gost28147.SboxIdGost2814789CryptoProDParamSet},
+}
+
+// Object identifier of GOST 28147-89 encryption parameters set.
+func SboxOID(sbox *gost28147.Sbox) (asn1.ObjectIdentifier, error) {
+	for _, s := range sboxes {
+		if s.sbox == sbox || *s.sbox == *sbox {
+			return s.oid, nil
+		}
+	}
+	return nil, errors.New("unknown S-box")
+}
+
+// GOST 28147-89 S-box by its parameters set object identifier.
+func SboxByOID(oid asn1.ObjectIdentifier) (*gost28147.Sbox, error) {
+	for _, s := range sboxes {
+		if s.oid.Equal(oid) {
+			return s.sbox, nil
+		}
+	}
+	return nil, errors.New("unknown S-box")
+}
@@ -0,0 +1,79 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Strict module mode state shared by the primitives and selftest
+// package. In strict mode ciphers and private keys can not be created
+// until the self-tests pass. The self-tests and primitives using ciphers
+// internally (like GOST R 34.11-94 hash) bypass the gate with the
+// constructors below, that are unreachable outside the module.
+package strict
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sync/atomic"
+)
+
+const (
+	Untested uint32 = iota
+	Passed
+	Failed
+)
+
+var (
+	enabled uint32
+	state   uint32
+
+	ErrNotReady = errors.New("strict mode: self-tests have not passed")
+)
+
+// Cipher constructors ignoring the state. They are set by the ciphers
+// packages in init(), as they can not be imported from there. sbox is
+// *gost28147.Sbox.
+var (
+	NewGost28147  func(key []byte, sbox interface{}) cipher.Block
+	NewMagma      func(key []byte) cipher.Block
+	NewKuznyechik func(key []byte) cipher.Block
+)
+
+func Enable() {
+	atomic.StoreUint32(&enabled, 1)
+}
+
+func Enabled() bool {
+	return atomic.LoadUint32(&enabled) == 1
+}
+
+func SetState(s uint32) {
+	atomic.StoreUint32(&state, s)
+}
+
+func State() uint32 {
+	return atomic.LoadUint32(&state)
+}
+
+// Can ciphers and keys be created: strict mode is disabled, or
+// self-tests have passed.
+func Ready() bool {
+	return !Enabled() || State() == Passed
+}
+
+// Panic if not Ready, for constructors without error return.
+func Check() {
+	if !Ready() {
+		panic(ErrNotReady.Error())
+	}
+}
@@ -0,0 +1,61 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Zeroing of sensitive data in memory. Functions are not inlined, so
+// compiler can not eliminate stores to the buffers that are not used
+// afterwards.
+package wipe
+
+import (
+	"math/big"
+)
+
+//go:noinline
+func Bytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+//go:noinline
+func Uint32s(b []uint32) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+//go:noinline
+func Uint64s(b []uint64) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Zero the whole underlying storage of the integer (including its
+// unused capacity) and set it to zero value.
+//
+//go:noinline
+func BigInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	words = words[:cap(words)]
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetInt64(0)
+}
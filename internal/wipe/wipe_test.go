@@ -0,0 +1,50 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package wipe
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBytes(t *testing.T) {
+	b := []byte{1, 2, 3}
+	Bytes(b)
+	for _, v := range b {
+		if v != 0 {
+			t.FailNow()
+		}
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	x := big.NewInt(0).SetBytes([]byte{
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
+	})
+	words := x.Bits()
+	x.Rsh(x, 64)
+	BigInt(x)
+	if x.Sign() != 0 {
+		t.FailNow()
+	}
+	for _, w := range words {
+		if w != 0 {
+			t.FailNow()
+		}
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"math/big"
+
+	"github.com/ddulesov/gogost/internal/wipe"
 )
 
 var (
@@ -248,3 +250,20 @@ func (mgm *MGM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, err
 	mgm.crypt(out, ct)
 	return ret, nil
 }
+
+// Zero the internal buffers. If underlying block cipher has Destroy
+// method, then it is also called. MGM must not be used after that.
+func (mgm *MGM) Destroy() {
+	wipe.Bytes(mgm.icn)
+	wipe.Bytes(mgm.bufP)
+	wipe.Bytes(mgm.bufC)
+	wipe.Bytes(mgm.padded)
+	wipe.Bytes(mgm.sum)
+	wipe.Bytes(mgm.mulBuf)
+	wipe.BigInt(mgm.x)
+	wipe.BigInt(mgm.y)
+	wipe.BigInt(mgm.z)
+	if c, ok := mgm.cipher.(interface{ Destroy() }); ok {
+		c.Destroy()
+	}
+}
@@ -146,3 +146,33 @@ func TestSymmetric(t *testing.T) {
 		nonce[:gost341264.BlockSize],
 	)
 }
+
+func TestDestroy(t *testing.T) {
+	key := make([]byte, gost3412128.KeySize)
+	rand.Read(key)
+	c := gost3412128.NewCipher(key)
+	aead, _ := NewMGM(c, gost3412128.BlockSize)
+	nonce := make([]byte, gost3412128.BlockSize)
+	ct := aead.Seal(nil, nonce, []byte("plaintext"), nil)
+	if _, err := aead.Open(nil, nonce, ct, nil); err != nil {
+		t.Fatal(err)
+	}
+	blk := make([]byte, gost3412128.BlockSize)
+	c.Encrypt(blk, nonce)
+	mgm := aead.(*MGM)
+	mgm.Destroy()
+	for _, buf := range [][]byte{
+		mgm.icn, mgm.bufP, mgm.bufC, mgm.padded, mgm.sum, mgm.mulBuf,
+	} {
+		if bytes.Compare(buf, make([]byte, len(buf))) != 0 {
+			t.FailNow()
+		}
+	}
+	if mgm.x.Sign() != 0 || mgm.y.Sign() != 0 || mgm.z.Sign() != 0 {
+		t.FailNow()
+	}
+	c.Encrypt(nonce, nonce)
+	if bytes.Compare(blk, nonce) == 0 {
+		t.FailNow()
+	}
+}
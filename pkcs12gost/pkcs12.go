@@ -0,0 +1,366 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// PKCS #12 (PFX) containers with GOST algorithms (R 50.1.112-2016,
+// RFC 9548).
+//
+// Integrity is protected by HMAC_GOSTR3411_2012_512 with the key derived
+// by PBKDF2 (last 32 bytes of its 96-byte output). Private keys are
+// stored in PBES2-encrypted shrouded key bags, certificates in
+// certificate bags. Passwords are encoded in UTF-8.
+package pkcs12gost
+
+import (
+	"crypto/hmac"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/pkcs5gost"
+)
+
+const (
+	DefaultIterations = 2000
+
+	macKeySize  = 32
+	macSaltSize = 32
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+
+	oidX509Certificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+)
+
+// Private key with its bag attributes.
+type Key struct {
+	PrivateKey   *gost3410.PrivateKey
+	FriendlyName string
+	LocalKeyID   []byte
+}
+
+// DER-encoded X.509 certificate with its bag attributes.
+type Certificate struct {
+	Raw          []byte
+	FriendlyName string
+	LocalKeyID   []byte
+}
+
+// Decoded contents of PFX.
+type PFX struct {
+	Keys         []Key
+	Certificates []Certificate
+}
+
+// Encoding options.
+type Options struct {
+	// Cipher used for shrouded key bags
+	Cipher pkcs5gost.Cipher
+	// PBKDF2 iterations count for both encryption and MAC
+	Iterations int
+	// Store certificates in EncryptedData instead of plain Data
+	EncryptCertificates bool
+}
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+func unmarshal(der []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+func computeMAC(password, salt []byte, iterations int, data []byte) []byte {
+	key := pkcs5gost.Key(password, salt, iterations, 96)
+	defer wipe.Bytes(key)
+	m := gost34112012512.NewHMAC(key[96-macKeySize:])
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      der,
+	}
+}
+
+// Decode BER/DER-encoded PFX. MAC is required and verified before any
+// decryption.
+func Decode(data []byte, password string) (*PFX, error) {
+	der, err := gostasn1.BER2DER(data)
+	if err != nil {
+		return nil, err
+	}
+	var pdu pfxPdu
+	if err = unmarshal(der, &pdu); err != nil {
+		return nil, err
+	}
+	if pdu.Version != 3 {
+		return nil, errors.New("unsupported PFX version")
+	}
+	if !pdu.AuthSafe.ContentType.Equal(oidData) {
+		return nil, errors.New("only password integrity mode is supported")
+	}
+	var authSafe []byte
+	if err = unmarshal(pdu.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, err
+	}
+	if pdu.MacData.Mac.Algorithm.Algorithm == nil {
+		return nil, errors.New("no MAC")
+	}
+	if !pdu.MacData.Mac.Algorithm.Algorithm.Equal(gostasn1.OIDGost34112012512) {
+		return nil, errors.New("unsupported MAC algorithm")
+	}
+	if pdu.MacData.Iterations <= 0 {
+		return nil, errors.New("invalid MAC iterations count")
+	}
+	if !hmac.Equal(computeMAC(
+		[]byte(password),
+		pdu.MacData.MacSalt,
+		pdu.MacData.Iterations,
+		authSafe,
+	), pdu.MacData.Mac.Digest) {
+		return nil, errors.New("invalid MAC: wrong password or corrupted data")
+	}
+	var cis []contentInfo
+	if err = unmarshal(authSafe, &cis); err != nil {
+		return nil, err
+	}
+	var pfx PFX
+	for _, ci := range cis {
+		var safeContents []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if err = unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return nil, err
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			if safeContents, err = decryptData(ci.Content.Bytes, password); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("unsupported content type")
+		}
+		if err = pfx.parseSafeContents(safeContents, password); err != nil {
+			return nil, err
+		}
+	}
+	return &pfx, nil
+}
+
+func decryptData(der []byte, password string) ([]byte, error) {
+	var ed encryptedData
+	if err := unmarshal(der, &ed); err != nil {
+		return nil, err
+	}
+	eci := &ed.EncryptedContentInfo
+	if !eci.ContentType.Equal(oidData) {
+		return nil, errors.New("unsupported encrypted content type")
+	}
+	params, err := pkcs5gost.ParseAlgorithmIdentifier(eci.ContentEncryptionAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := eci.EncryptedContent.Bytes
+	if eci.EncryptedContent.IsCompound {
+		// Constructed [0] IMPLICIT OCTET STRING
+		ciphertext = nil
+		for rest := eci.EncryptedContent.Bytes; len(rest) > 0; {
+			var chunk []byte
+			if rest, err = asn1.Unmarshal(rest, &chunk); err != nil {
+				return nil, err
+			}
+			ciphertext = append(ciphertext, chunk...)
+		}
+	}
+	return params.Decrypt([]byte(password), ciphertext)
+}
+
+// Encode PFX. If opts is nil, then Kuznyechik CTR-ACPKM with
+// DefaultIterations is used and certificates are not encrypted.
+func Encode(rand io.Reader, pfx *PFX, password string, opts *Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Options{
+			Cipher:     pkcs5gost.KuznyechikCTRACPKM,
+			Iterations: DefaultIterations,
+		}
+	}
+	if opts.Iterations <= 0 {
+		return nil, errors.New("invalid iterations count")
+	}
+	var cis []contentInfo
+	if len(pfx.Keys) > 0 {
+		var bags []safeBag
+		for _, key := range pfx.Keys {
+			bag, err := shroudedKeyBag(rand, &key, password, opts)
+			if err != nil {
+				return nil, err
+			}
+			bags = append(bags, bag)
+		}
+		ci, err := dataContentInfo(bags)
+		if err != nil {
+			return nil, err
+		}
+		cis = append(cis, ci)
+	}
+	if len(pfx.Certificates) > 0 {
+		var bags []safeBag
+		for _, cert := range pfx.Certificates {
+			bag, err := certificateBag(&cert)
+			if err != nil {
+				return nil, err
+			}
+			bags = append(bags, bag)
+		}
+		var ci contentInfo
+		var err error
+		if opts.EncryptCertificates {
+			ci, err = encryptedDataContentInfo(rand, bags, password, opts)
+		} else {
+			ci, err = dataContentInfo(bags)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cis = append(cis, ci)
+	}
+	authSafe, err := asn1.Marshal(cis)
+	if err != nil {
+		return nil, err
+	}
+	authSafeOctets, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+	pdu := pfxPdu{
+		Version:  3,
+		AuthSafe: contentInfo{oidData, explicit(authSafeOctets)},
+		MacData: macData{
+			MacSalt:    make([]byte, macSaltSize),
+			Iterations: opts.Iterations,
+		},
+	}
+	if _, err = io.ReadFull(rand, pdu.MacData.MacSalt); err != nil {
+		return nil, err
+	}
+	pdu.MacData.Mac.Algorithm = pkix.AlgorithmIdentifier{
+		Algorithm:  gostasn1.OIDGost34112012512,
+		Parameters: asn1.NullRawValue,
+	}
+	pdu.MacData.Mac.Digest = computeMAC(
+		[]byte(password),
+		pdu.MacData.MacSalt,
+		pdu.MacData.Iterations,
+		authSafe,
+	)
+	return asn1.Marshal(pdu)
+}
+
+func dataContentInfo(bags []safeBag) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	octets, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{oidData, explicit(octets)}, nil
+}
+
+func encryptedDataContentInfo(rand io.Reader, bags []safeBag, password string, opts *Options) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	params, err := pkcs5gost.NewParams(rand, opts.Cipher, opts.Iterations)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	ed := encryptedData{EncryptedContentInfo: encryptedContentInfo{
+		ContentType: oidData,
+	}}
+	eci := &ed.EncryptedContentInfo
+	if eci.ContentEncryptionAlgorithm, err = params.AlgorithmIdentifier(); err != nil {
+		return contentInfo{}, err
+	}
+	ciphertext, err := params.Encrypt([]byte(password), safeContents)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	eci.EncryptedContent = asn1.RawValue{
+		Class: asn1.ClassContextSpecific,
+		Tag:   0,
+		Bytes: ciphertext,
+	}
+	der, err := asn1.Marshal(ed)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{oidEncryptedData, explicit(der)}, nil
+}
@@ -0,0 +1,190 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs12gost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/pkcs5gost"
+)
+
+func testPFX(t *testing.T) *PFX {
+	prv256, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost34102012256paramSetA(),
+		gost3410.Mode2001,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prv512, err := gost3410.GenPrivateKey(
+		gost3410.CurveIdtc26gost341012512paramSetA(),
+		gost3410.Mode2012,
+		rand.Reader,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := make([]byte, 300)
+	rand.Read(cert)
+	return &PFX{
+		Keys: []Key{
+			{prv256, "Ключ 256", []byte{1, 2, 3, 4}},
+			{PrivateKey: prv512},
+		},
+		Certificates: []Certificate{
+			{cert, "Сертификат", []byte{1, 2, 3, 4}},
+			{Raw: []byte{0x30, 0x00}},
+		},
+	}
+}
+
+func comparePFX(t *testing.T, got, expected *PFX) {
+	if len(got.Keys) != len(expected.Keys) {
+		t.Fatal("keys count mismatch")
+	}
+	for i, k := range expected.Keys {
+		g := got.Keys[i]
+		if g.PrivateKey.Key.Cmp(k.PrivateKey.Key) != 0 ||
+			g.PrivateKey.Mode != k.PrivateKey.Mode ||
+			g.PrivateKey.C.Name != k.PrivateKey.C.Name ||
+			g.FriendlyName != k.FriendlyName ||
+			bytes.Compare(g.LocalKeyID, k.LocalKeyID) != 0 {
+			t.Fatal("key mismatch")
+		}
+	}
+	if len(got.Certificates) != len(expected.Certificates) {
+		t.Fatal("certificates count mismatch")
+	}
+	for i, c := range expected.Certificates {
+		g := got.Certificates[i]
+		if bytes.Compare(g.Raw, c.Raw) != 0 ||
+			g.FriendlyName != c.FriendlyName ||
+			bytes.Compare(g.LocalKeyID, c.LocalKeyID) != 0 {
+			t.Fatal("certificate mismatch")
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	pfx := testPFX(t)
+	for _, c := range []pkcs5gost.Cipher{
+		pkcs5gost.Gost28147CFB,
+		pkcs5gost.MagmaCTRACPKM,
+		pkcs5gost.KuznyechikCTRACPKM,
+	} {
+		for _, encryptCerts := range []bool{false, true} {
+			data, err := Encode(rand.Reader, pfx, "Пароль для PFX", &Options{
+				Cipher:              c,
+				Iterations:          10,
+				EncryptCertificates: encryptCerts,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(data, "Пароль для PFX")
+			if err != nil {
+				t.Fatal(err)
+			}
+			comparePFX(t, got, pfx)
+		}
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	data, err := Encode(rand.Reader, testPFX(t), "password", &Options{
+		Cipher:     pkcs5gost.KuznyechikCTRACPKM,
+		Iterations: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decode(data, "Password"); err == nil {
+		t.FailNow()
+	}
+	data[len(data)/2] ^= 0x01
+	if _, err = Decode(data, "password"); err == nil {
+		t.FailNow()
+	}
+}
+
+// Split DER element, returning its header, value and the rest.
+func splitElement(t *testing.T, der []byte) ([]byte, []byte, []byte) {
+	hdrLen, l := 2, int(der[1])
+	if l >= 0x80 {
+		hdrLen += l & 0x7F
+		l = 0
+		for _, c := range der[2:hdrLen] {
+			l = l<<8 | int(c)
+		}
+	}
+	return der[:hdrLen], der[hdrLen : hdrLen+l], der[hdrLen+l:]
+}
+
+// Re-encode DER with indefinite lengths and chunked (also [0] IMPLICIT)
+// OCTET STRINGs.
+func der2ber(t *testing.T, der []byte) []byte {
+	hdr, value, _ := splitElement(t, der)
+	tag := hdr[0]
+	if (tag == 0x04 || tag == 0x80) && len(value) > 16 {
+		out := []byte{tag | 0x20, 0x80}
+		for len(value) > 0 {
+			n := 16
+			if n > len(value) {
+				n = len(value)
+			}
+			out = append(out, 0x04, byte(n))
+			out = append(out, value[:n]...)
+			value = value[n:]
+		}
+		return append(out, 0, 0)
+	}
+	if tag&0x20 == 0 {
+		return der
+	}
+	out := []byte{tag, 0x80}
+	for len(value) > 0 {
+		h, v, rest := splitElement(t, value)
+		out = append(out, der2ber(t, value[:len(h)+len(v)])...)
+		value = rest
+	}
+	return append(out, 0, 0)
+}
+
+func TestBER(t *testing.T) {
+	pfx := testPFX(t)
+	der, err := Encode(rand.Reader, pfx, "password", &Options{
+		Cipher:              pkcs5gost.MagmaCTRACPKM,
+		Iterations:          1,
+		EncryptCertificates: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ber := der2ber(t, der)
+	if bytes.Compare(ber, der) == 0 {
+		t.FailNow()
+	}
+	got, err := Decode(ber, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparePFX(t, got, pfx)
+}
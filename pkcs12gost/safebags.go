@@ -0,0 +1,233 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pkcs12gost
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"unicode/utf16"
+
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/pkcs5gost"
+)
+
+// encoding/asn1 of older Go versions lacks that constant
+const tagBMPString = 30
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+func bmpString(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return b
+}
+
+func parseBMPString(b []byte) (string, error) {
+	if len(b)%2 != 0 {
+		return "", errors.New("odd length BMPString")
+	}
+	s := make([]uint16, 0, len(b)/2)
+	for i := 0; i < len(b); i += 2 {
+		s = append(s, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(s)), nil
+}
+
+func attribute(id asn1.ObjectIdentifier, value asn1.RawValue) (pkcs12Attribute, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{id, asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      der,
+	}}, nil
+}
+
+func attributes(friendlyName string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	var attrs []pkcs12Attribute
+	if friendlyName != "" {
+		attr, err := attribute(oidFriendlyName, asn1.RawValue{
+			Class: asn1.ClassUniversal,
+			Tag:   tagBMPString,
+			Bytes: bmpString(friendlyName),
+		})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	if len(localKeyID) > 0 {
+		attr, err := attribute(oidLocalKeyID, asn1.RawValue{
+			Class: asn1.ClassUniversal,
+			Tag:   asn1.TagOctetString,
+			Bytes: localKeyID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// Parse known attributes, ignoring unknown ones.
+func parseAttributes(attrs []pkcs12Attribute) (friendlyName string, localKeyID []byte, err error) {
+	for _, attr := range attrs {
+		var v asn1.RawValue
+		if _, err = asn1.Unmarshal(attr.Value.Bytes, &v); err != nil {
+			return
+		}
+		switch {
+		case attr.ID.Equal(oidFriendlyName):
+			if v.Tag != tagBMPString {
+				err = errors.New("friendlyName is not BMPString")
+				return
+			}
+			if friendlyName, err = parseBMPString(v.Bytes); err != nil {
+				return
+			}
+		case attr.ID.Equal(oidLocalKeyID):
+			if v.Tag != asn1.TagOctetString {
+				err = errors.New("localKeyID is not OCTET STRING")
+				return
+			}
+			localKeyID = v.Bytes
+		}
+	}
+	return
+}
+
+func shroudedKeyBag(rand io.Reader, key *Key, password string, opts *Options) (safeBag, error) {
+	var bag safeBag
+	pkcs8, err := gostasn1.MarshalPrivateKey(key.PrivateKey)
+	if err != nil {
+		return bag, err
+	}
+	defer wipe.Bytes(pkcs8)
+	params, err := pkcs5gost.NewParams(rand, opts.Cipher, opts.Iterations)
+	if err != nil {
+		return bag, err
+	}
+	var epki encryptedPrivateKeyInfo
+	if epki.Algorithm, err = params.AlgorithmIdentifier(); err != nil {
+		return bag, err
+	}
+	if epki.EncryptedData, err = params.Encrypt([]byte(password), pkcs8); err != nil {
+		return bag, err
+	}
+	der, err := asn1.Marshal(epki)
+	if err != nil {
+		return bag, err
+	}
+	bag.ID = oidPKCS8ShroudedKeyBag
+	bag.Value = explicit(der)
+	bag.Attributes, err = attributes(key.FriendlyName, key.LocalKeyID)
+	return bag, err
+}
+
+func certificateBag(cert *Certificate) (safeBag, error) {
+	var bag safeBag
+	der, err := asn1.Marshal(certBag{oidX509Certificate, cert.Raw})
+	if err != nil {
+		return bag, err
+	}
+	bag.ID = oidCertBag
+	bag.Value = explicit(der)
+	bag.Attributes, err = attributes(cert.FriendlyName, cert.LocalKeyID)
+	return bag, err
+}
+
+// Parse SafeContents, appending found keys and certificates. Unknown
+// bags are skipped.
+func (pfx *PFX) parseSafeContents(der []byte, password string) error {
+	var bags []safeBag
+	if err := unmarshal(der, &bags); err != nil {
+		return err
+	}
+	for _, bag := range bags {
+		friendlyName, localKeyID, err := parseAttributes(bag.Attributes)
+		if err != nil {
+			return err
+		}
+		switch {
+		case bag.ID.Equal(oidKeyBag):
+			prv, err := gostasn1.ParsePrivateKey(bag.Value.Bytes)
+			if err != nil {
+				return err
+			}
+			pfx.Keys = append(pfx.Keys, Key{prv, friendlyName, localKeyID})
+		case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+			var epki encryptedPrivateKeyInfo
+			if err = unmarshal(bag.Value.Bytes, &epki); err != nil {
+				return err
+			}
+			params, err := pkcs5gost.ParseAlgorithmIdentifier(epki.Algorithm)
+			if err != nil {
+				return err
+			}
+			pkcs8, err := params.Decrypt([]byte(password), epki.EncryptedData)
+			if err != nil {
+				return err
+			}
+			prv, err := gostasn1.ParsePrivateKey(pkcs8)
+			wipe.Bytes(pkcs8)
+			if err != nil {
+				return err
+			}
+			pfx.Keys = append(pfx.Keys, Key{prv, friendlyName, localKeyID})
+		case bag.ID.Equal(oidCertBag):
+			var cb certBag
+			if err = unmarshal(bag.Value.Bytes, &cb); err != nil {
+				return err
+			}
+			if !cb.ID.Equal(oidX509Certificate) {
+				continue
+			}
+			pfx.Certificates = append(pfx.Certificates, Certificate{
+				cb.Data, friendlyName, localKeyID,
+			})
+		}
+	}
+	return nil
+}
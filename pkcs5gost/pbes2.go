@@ -0,0 +1,349 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Password-based encryption with GOST algorithms (RFC 9337,
+// R 50.1.111-2016): PBKDF2 with HMAC_GOSTR3411_2012_512 and PBES2 with
+// GOST 28147-89 CFB, Magma or Kuznyechik CTR-ACPKM encryption.
+//
+// 28147-89 CFB is used without CryptoPro key meshing.
+package pkcs5gost
+
+import (
+	"crypto/cipher"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	KeySize  = 32
+	SaltSize = 32
+
+	// CTR-ACPKM section sizes
+	MagmaSectionSize      = 8 * 1024
+	KuznyechikSectionSize = 256 * 1024
+)
+
+var (
+	OIDPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	OIDPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	OIDHMACGost34112012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 4, 1}
+	OIDHMACGost34112012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 4, 2}
+
+	OIDGost28147          = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 21}
+	OIDMagmaCTRACPKM      = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 5, 1, 1}
+	OIDKuznyechikCTRACPKM = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 5, 2, 1}
+)
+
+// PBKDF2 with HMAC_GOSTR3411_2012_512 PRF.
+func Key(password, salt []byte, iter, keyLen int) []byte {
+	return pbkdf2.Key(password, salt, iter, keyLen, gost34112012512.New)
+}
+
+// Encryption scheme used inside PBES2.
+type Cipher int
+
+const (
+	Gost28147CFB Cipher = iota
+	MagmaCTRACPKM
+	KuznyechikCTRACPKM
+)
+
+func (c Cipher) String() string {
+	switch c {
+	case Gost28147CFB:
+		return "GOST 28147-89 CFB"
+	case MagmaCTRACPKM:
+		return "Magma CTR-ACPKM"
+	case KuznyechikCTRACPKM:
+		return "Kuznyechik CTR-ACPKM"
+	}
+	return "unknown"
+}
+
+// Size of IV: 28147-89 CFB's initialization vector or CTR-ACPKM's ukm
+// (half of the blocksize).
+func (c Cipher) IVSize() int {
+	switch c {
+	case Gost28147CFB:
+		return gost28147.BlockSize
+	case MagmaCTRACPKM:
+		return gost341264.BlockSize / 2
+	case KuznyechikCTRACPKM:
+		return gost3412128.BlockSize / 2
+	}
+	return 0
+}
+
+// PBES2 parameters.
+type Params struct {
+	Cipher     Cipher
+	Salt       []byte
+	Iterations int
+	IV         []byte
+	// S-box for GOST 28147-89, id-tc26-gost-28147-param-Z by default
+	Sbox *gost28147.Sbox
+}
+
+// Generate parameters with random salt and IV.
+func NewParams(rand io.Reader, c Cipher, iterations int) (*Params, error) {
+	if c.IVSize() == 0 {
+		return nil, errors.New("unknown cipher")
+	}
+	if iterations <= 0 {
+		return nil, errors.New("invalid iterations count")
+	}
+	p := Params{
+		Cipher:     c,
+		Salt:       make([]byte, SaltSize),
+		Iterations: iterations,
+		IV:         make([]byte, c.IVSize()),
+	}
+	if _, err := io.ReadFull(rand, p.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand, p.IV); err != nil {
+		return nil, err
+	}
+	if c == Gost28147CFB {
+		p.Sbox = &gost28147.SboxIdtc26gost28147paramZ
+	}
+	return &p, nil
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier
+}
+
+type gost28147Params struct {
+	IV                 []byte
+	EncryptionParamSet asn1.ObjectIdentifier
+}
+
+type gost3412Params struct {
+	UKM []byte
+}
+
+func (p *Params) validate() error {
+	if len(p.Salt) == 0 {
+		return errors.New("empty salt")
+	}
+	if p.Iterations <= 0 {
+		return errors.New("invalid iterations count")
+	}
+	if n := p.Cipher.IVSize(); n == 0 {
+		return errors.New("unknown cipher")
+	} else if len(p.IV) != n {
+		return errors.New("invalid IV size")
+	}
+	return nil
+}
+
+func (p *Params) sbox() *gost28147.Sbox {
+	if p.Sbox == nil {
+		return &gost28147.SboxIdtc26gost28147paramZ
+	}
+	return p.Sbox
+}
+
+// Serialise parameters to PBES2 AlgorithmIdentifier.
+func (p *Params) AlgorithmIdentifier() (ai pkix.AlgorithmIdentifier, err error) {
+	if err = p.validate(); err != nil {
+		return
+	}
+	kdf := pbkdf2Params{
+		Salt:           p.Salt,
+		IterationCount: p.Iterations,
+		KeyLength:      KeySize,
+		PRF: pkix.AlgorithmIdentifier{
+			Algorithm:  OIDHMACGost34112012512,
+			Parameters: asn1.NullRawValue,
+		},
+	}
+	var params pbes2Params
+	params.KeyDerivationFunc.Algorithm = OIDPBKDF2
+	if params.KeyDerivationFunc.Parameters.FullBytes, err = asn1.Marshal(kdf); err != nil {
+		return
+	}
+	var encParams []byte
+	switch p.Cipher {
+	case Gost28147CFB:
+		params.EncryptionScheme.Algorithm = OIDGost28147
+		var oid asn1.ObjectIdentifier
+		if oid, err = gostasn1.SboxOID(p.sbox()); err != nil {
+			return
+		}
+		encParams, err = asn1.Marshal(gost28147Params{p.IV, oid})
+	case MagmaCTRACPKM:
+		params.EncryptionScheme.Algorithm = OIDMagmaCTRACPKM
+		encParams, err = asn1.Marshal(gost3412Params{p.IV})
+	case KuznyechikCTRACPKM:
+		params.EncryptionScheme.Algorithm = OIDKuznyechikCTRACPKM
+		encParams, err = asn1.Marshal(gost3412Params{p.IV})
+	}
+	if err != nil {
+		return
+	}
+	params.EncryptionScheme.Parameters.FullBytes = encParams
+	ai.Algorithm = OIDPBES2
+	ai.Parameters.FullBytes, err = asn1.Marshal(params)
+	return
+}
+
+func unmarshal(der []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+// Parse PBES2 AlgorithmIdentifier.
+func ParseAlgorithmIdentifier(ai pkix.AlgorithmIdentifier) (*Params, error) {
+	if !ai.Algorithm.Equal(OIDPBES2) {
+		return nil, errors.New("not PBES2")
+	}
+	var params pbes2Params
+	if err := unmarshal(ai.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(OIDPBKDF2) {
+		return nil, errors.New("unsupported key derivation function")
+	}
+	var kdf pbkdf2Params
+	if err := unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+	if !kdf.PRF.Algorithm.Equal(OIDHMACGost34112012512) {
+		return nil, errors.New("unsupported PRF")
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != KeySize {
+		return nil, errors.New("invalid key length")
+	}
+	p := Params{Salt: kdf.Salt, Iterations: kdf.IterationCount}
+	scheme := params.EncryptionScheme
+	switch {
+	case scheme.Algorithm.Equal(OIDGost28147):
+		var encParams gost28147Params
+		if err := unmarshal(scheme.Parameters.FullBytes, &encParams); err != nil {
+			return nil, err
+		}
+		p.Cipher = Gost28147CFB
+		p.IV = encParams.IV
+		var err error
+		if p.Sbox, err = gostasn1.SboxByOID(encParams.EncryptionParamSet); err != nil {
+			return nil, err
+		}
+	case scheme.Algorithm.Equal(OIDMagmaCTRACPKM):
+		p.Cipher = MagmaCTRACPKM
+	case scheme.Algorithm.Equal(OIDKuznyechikCTRACPKM):
+		p.Cipher = KuznyechikCTRACPKM
+	default:
+		return nil, errors.New("unsupported encryption scheme")
+	}
+	if p.Cipher != Gost28147CFB {
+		var encParams gost3412Params
+		if err := unmarshal(scheme.Parameters.FullBytes, &encParams); err != nil {
+			return nil, err
+		}
+		p.IV = encParams.UKM
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+type destroyer interface {
+	Destroy()
+}
+
+func (p *Params) stream(password []byte, encrypt bool) (cipher.Stream, destroyer, error) {
+	if !strict.Ready() {
+		return nil, nil, strict.ErrNotReady
+	}
+	if err := p.validate(); err != nil {
+		return nil, nil, err
+	}
+	key := Key(password, p.Salt, p.Iterations, KeySize)
+	defer wipe.Bytes(key)
+	switch p.Cipher {
+	case Gost28147CFB:
+		c := gost28147.NewCipher(key, p.sbox())
+		if encrypt {
+			return c.NewCFBEncrypter(p.IV), c, nil
+		}
+		return c.NewCFBDecrypter(p.IV), c, nil
+	case MagmaCTRACPKM:
+		s := gost3413.NewCTRACPKM(func(k []byte) cipher.Block {
+			return gost341264.NewCipher(k)
+		}, key, p.IV, MagmaSectionSize)
+		return s, s, nil
+	default:
+		s := gost3413.NewCTRACPKM(func(k []byte) cipher.Block {
+			return gost3412128.NewCipher(k)
+		}, key, p.IV, KuznyechikSectionSize)
+		return s, s, nil
+	}
+}
+
+// Encrypt data with the key derived from the password. Ciphertext has
+// the same length as plaintext: no padding is applied.
+func (p *Params) Encrypt(password, plaintext []byte) ([]byte, error) {
+	s, d, err := p.stream(password, true)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Destroy()
+	ciphertext := make([]byte, len(plaintext))
+	s.XORKeyStream(ciphertext, plaintext)
+	return ciphertext, nil
+}
+
+// Decrypt data with the key derived from the password.
+func (p *Params) Decrypt(password, ciphertext []byte) ([]byte, error) {
+	s, d, err := p.stream(password, false)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Destroy()
+	plaintext := make([]byte, len(ciphertext))
+	s.XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
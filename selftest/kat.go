@@ -0,0 +1,535 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package selftest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/gost341194"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/mgm"
+	"github.com/ddulesov/gogost/pkcs5gost"
+)
+
+type test struct {
+	name string
+	run  func() error
+}
+
+var tests = []test{
+	{"GOST 28147-89 ECB", test28147ECB},
+	{"GOST 28147-89 CFB", test28147CFB},
+	{"GOST 28147-89 CNT", test28147CNT},
+	{"GOST 28147-89 MAC", test28147MAC},
+	{"GOST R 34.12-2015 Kuznechik", testKuznechik},
+	{"GOST R 34.12-2015 Magma", testMagma},
+	{"GOST R 34.13-2015 OMAC Kuznechik", testOMACKuznechik},
+	{"GOST R 34.13-2015 OMAC Magma", testOMACMagma},
+	{"CTR-ACPKM", testCTRACPKM},
+	{"KExp15/KImp15", testKExp15},
+	{"MGM", testMGM},
+	{"GOST R 34.11-2012 256", testStreebog256},
+	{"GOST R 34.11-2012 512", testStreebog512},
+	{"GOST R 34.11-94", test341194},
+	{"GOST R 34.10-2001 sign/verify", test3410},
+	{"GOST R 34.10-2012 512 sign/verify", test3410512},
+	{"VKO GOST R 34.10-2001", testVKO2001},
+	{"VKO GOST R 34.10-2012 256", testVKO2012256},
+	{"VKO GOST R 34.10-2012 512", testVKO2012512},
+	{"KDF_GOSTR3411_2012_256", testKDF},
+	{"KDF_TREE_GOSTR3411_2012_256", testKDFTree},
+	{"HMAC_GOSTR3411_2012_256", testHMAC},
+	{"PRF_TLS_GOSTR3411_2012_256", testPRF},
+	{"PBKDF2 GOST R 34.11-2012 512", testPBKDF2},
+	{"TLSTree", testTLSTree},
+}
+
+// Vectors are constants, so decoding never fails.
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Ciphers and private keys are created regardless of the strict mode
+// state, as the self-tests are the ones opening the gate.
+func new28147(key []byte, sbox *gost28147.Sbox) *gost28147.Cipher {
+	return strict.NewGost28147(key, sbox).(*gost28147.Cipher)
+}
+
+func newMagma(key []byte) *gost341264.Cipher {
+	return strict.NewMagma(key).(*gost341264.Cipher)
+}
+
+func newKuznyechik(key []byte) *gost3412128.Cipher {
+	return strict.NewKuznyechik(key).(*gost3412128.Cipher)
+}
+
+// Same as gost3410.NewPrivateKey with the little-endian raw key.
+func privateKey(c *gost3410.Curve, mode gost3410.Mode, raw string) *gost3410.PrivateKey {
+	key := unhex(raw)
+	for i, j := 0, len(key)-1; i < j; i, j = i+1, j-1 {
+		key[i], key[j] = key[j], key[i]
+	}
+	return &gost3410.PrivateKey{C: c, Mode: mode, Key: new(big.Int).SetBytes(key)}
+}
+
+func expect(got, want []byte, what string) error {
+	if bytes.Compare(got, want) != 0 {
+		return errors.New(what + " mismatch")
+	}
+	return nil
+}
+
+// http://cryptomanager.com/tv.html
+var (
+	cryptomanagerKey = unhex("75713134b60fec45a607bb83aa3746af4ff99da6d1b53b5b1b402a1baa030d1b")
+	cryptomanagerPt  = unhex("112233445566778899aabbccdd800000")
+)
+
+func test28147ECB() error {
+	c := new28147(cryptomanagerKey, &gost28147.SboxIdGostR341194TestParamSet)
+	defer c.Destroy()
+	ct := make([]byte, gost28147.BlockSize)
+	c.Encrypt(ct, cryptomanagerPt[:gost28147.BlockSize])
+	if err := expect(ct, unhex("03251e14f9d28acb"), "encryption"); err != nil {
+		return err
+	}
+	c.Decrypt(ct, ct)
+	return expect(ct, cryptomanagerPt[:gost28147.BlockSize], "decryption")
+}
+
+func test28147CFB() error {
+	c := new28147(cryptomanagerKey, &gost28147.SboxIdGostR341194TestParamSet)
+	defer c.Destroy()
+	iv := unhex("0102030405060708")
+	ct := make([]byte, len(cryptomanagerPt))
+	c.NewCFBEncrypter(iv).XORKeyStream(ct, cryptomanagerPt)
+	if err := expect(ct, unhex("6ee84586dd2bca0cad3616940e164242"), "encryption"); err != nil {
+		return err
+	}
+	pt := make([]byte, len(ct))
+	c.NewCFBDecrypter(iv).XORKeyStream(pt, ct)
+	return expect(pt, cryptomanagerPt, "decryption")
+}
+
+// GCL2 vector.
+func test28147CNT() error {
+	c := new28147(
+		unhex("fc7ad2886f455b50d29008fa622b57d5c65b3c637202025799cadf0768519e8a"),
+		&gost28147.SboxIdGost2814789TestParamSet,
+	)
+	defer c.Destroy()
+	pt := unhex("07060504030201000f0e0d0c0b0a090817161514131211101f1e1d1c1b1a191827262524232221202f2e2d2c2b2a2928fffefdfcfb")
+	iv := make([]byte, gost28147.BlockSize)
+	ct := make([]byte, len(pt))
+	c.NewCTR(iv).XORKeyStream(ct, pt)
+	if err := expect(ct, unhex("d0be601a2cf190269b7b23b4d2cce115f60557288875eb1ed362dcda9b62ee9a57878af182379c7f13cc5538b56332c523a4cb7d51"), "encryption"); err != nil {
+		return err
+	}
+	c.NewCTR(iv).XORKeyStream(ct, ct)
+	return expect(ct, pt, "decryption")
+}
+
+func test28147MAC() error {
+	c := new28147([]byte("This is message\xFF length\x0032 bytes"), gost28147.SboxDefault)
+	defer c.Destroy()
+	m, err := c.NewMAC(8, make([]byte, gost28147.BlockSize))
+	if err != nil {
+		return err
+	}
+	m.Write([]byte("abc"))
+	return expect(m.Sum(nil), unhex("28661e40805b1ff9"), "MAC")
+}
+
+// GOST R 34.12-2015 appendix A.1.
+func testKuznechik() error {
+	c := newKuznyechik(kuznechikKey)
+	defer c.Destroy()
+	pt := unhex("1122334455667700ffeeddccbbaa9988")
+	ct := make([]byte, gost3412128.BlockSize)
+	c.Encrypt(ct, pt)
+	if err := expect(ct, unhex("7f679d90bebc24305a468d42b9d4edcd"), "encryption"); err != nil {
+		return err
+	}
+	c.Decrypt(ct, ct)
+	return expect(ct, pt, "decryption")
+}
+
+// GOST R 34.12-2015 appendix A.2.
+func testMagma() error {
+	c := newMagma(magmaKey)
+	defer c.Destroy()
+	pt := unhex("fedcba9876543210")
+	ct := make([]byte, gost341264.BlockSize)
+	c.Encrypt(ct, pt)
+	if err := expect(ct, unhex("4ee901e5c2d8ca3d"), "encryption"); err != nil {
+		return err
+	}
+	c.Decrypt(ct, ct)
+	return expect(ct, pt, "decryption")
+}
+
+var (
+	kuznechikKey = unhex("8899aabbccddeeff0011223344556677fedcba98765432100123456789abcdef")
+	magmaKey     = unhex("ffeeddccbbaa99887766554433221100f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+)
+
+// GOST R 34.13-2015 appendix A.1.6.
+func testOMACKuznechik() error {
+	c := newKuznyechik(kuznechikKey)
+	defer c.Destroy()
+	m, err := gost3413.NewOMAC(c, 8)
+	if err != nil {
+		return err
+	}
+	defer m.Destroy()
+	m.Write(unhex("1122334455667700ffeeddccbbaa998800112233445566778899aabbcceeff0a112233445566778899aabbcceeff0a002233445566778899aabbcceeff0a0011"))
+	return expect(m.Sum(nil), unhex("336f4d296059fbe3"), "MAC")
+}
+
+// GOST R 34.13-2015 appendix A.2.6.
+func testOMACMagma() error {
+	c := newMagma(magmaKey)
+	defer c.Destroy()
+	m, err := gost3413.NewOMAC(c, 4)
+	if err != nil {
+		return err
+	}
+	defer m.Destroy()
+	m.Write(unhex("92def06b3c130a59db54c704f8189d204a98fb2e67a8024c8912409b17b57e41"))
+	return expect(m.Sum(nil), unhex("154e7210"), "MAC")
+}
+
+// RFC 8645 A.1 with N=256 bits section size.
+func testCTRACPKM() error {
+	iv := unhex("1234567890abcef0")
+	pt := unhex("1122334455667700ffeeddccbbaa998800112233445566778899aabbcceeff0a" +
+		"112233445566778899aabbcceeff0a002233445566778899aabbcceeff0a0011" +
+		"33445566778899aabbcceeff0a001122445566778899aabbcceeff0a00112233" +
+		"5566778899aabbcceeff0a0011223344")
+	ct := make([]byte, len(pt))
+	s := gost3413.NewCTRACPKM(strict.NewKuznyechik, kuznechikKey, iv, 32)
+	s.XORKeyStream(ct, pt)
+	s.Destroy()
+	if err := expect(ct, unhex("f195d8bec10ed1dbd57b5fa240bda1b885eee733f6a13e5df33ce4b33c45dee4"+
+		"4bceeb8f646f4c55001706275e85e800587c4df568d094393e4834afd0805046"+
+		"cf30f57686aeece11cfc6c316b8a896edffd07ec813636460c4f3b743423163e"+
+		"6409a9c282fac8d469d221e7fbd6de5d"), "encryption"); err != nil {
+		return err
+	}
+	s = gost3413.NewCTRACPKM(strict.NewKuznyechik, kuznechikKey, iv, 32)
+	s.XORKeyStream(ct, ct)
+	s.Destroy()
+	return expect(ct, pt, "decryption")
+}
+
+// R 1323565.1.017-2018 appendix A key export example with Magma.
+func testKExp15() error {
+	key := unhex("8899aabbccddeeff0011223344556677fedcba98765432100123456789abcdef")
+	kExpMAC := unhex("08090a0b0c0d0e0f0001020304050607101112131415161718191a1b1c1d1e1f")
+	kExpEnc := unhex("202122232425262728292a2b2c2d2e2f38393a3b3c3d3e3f3031323334353637")
+	iv := unhex("67bed654")
+	exported, err := gost3413.KExp15(strict.NewMagma, key, kExpMAC, kExpEnc, iv)
+	if err != nil {
+		return err
+	}
+	if err = expect(exported, unhex("cfd5a12d5b81b6e1e99c916d07900c6ac12703fb3abded55567bf3742c899c755dafe7b42e3a8bd9"), "export"); err != nil {
+		return err
+	}
+	imported, err := gost3413.KImp15(strict.NewMagma, exported, kExpMAC, kExpEnc, iv)
+	if err != nil {
+		return err
+	}
+	if err = expect(imported, key, "import"); err != nil {
+		return err
+	}
+	exported[0] ^= 0x01
+	if _, err = gost3413.KImp15(strict.NewMagma, exported, kExpMAC, kExpEnc, iv); err == nil {
+		return errors.New("tampered export accepted")
+	}
+	return nil
+}
+
+// R 1323565.1.026-2019 appendix A.2.
+func testMGM() error {
+	c := newKuznyechik(kuznechikKey)
+	defer c.Destroy()
+	aead, err := mgm.NewMGM(c, gost3412128.BlockSize)
+	if err != nil {
+		return err
+	}
+	defer aead.(*mgm.MGM).Destroy()
+	ad := unhex("0202020202020202010101010101010104040404040404040303030303030303ea0505050505050505")
+	pt := unhex("1122334455667700ffeeddccbbaa998800112233445566778899aabbcceeff0a112233445566778899aabbcceeff0a002233445566778899aabbcceeff0a0011aabbcc")
+	nonce := pt[:gost3412128.BlockSize]
+	sealed := aead.Seal(nil, nonce, pt, ad)
+	if err = expect(sealed, unhex("a9757b8147956e9055b8a33de89f42fc8075d2212bf9fd5bd3f7069aadc16b39497ab15915a6ba85936b5d0ea9f6851cc60c14d4d3f883d0ab94420695c76deb2c7552cf5d656f40c34f5c46e8bb0e29fcdb4c"), "seal"); err != nil {
+		return err
+	}
+	opened, err := aead.Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return err
+	}
+	if err = expect(opened, pt, "open"); err != nil {
+		return err
+	}
+	sealed[0] ^= 0x01
+	if _, err = aead.Open(nil, nonce, sealed, ad); err == nil {
+		return errors.New("tampered ciphertext accepted")
+	}
+	return nil
+}
+
+// GOST R 34.11-2012 appendix A, example 1.
+var streebogM1 = []byte("012345678901234567890123456789012345678901234567890123456789012")
+
+func testStreebog256() error {
+	h := gost34112012256.New()
+	h.Write(streebogM1)
+	return expect(h.Sum(nil), unhex("9d151eefd8590b89daa6ba6cb74af9275dd051026bb149a452fd84e5e57b5500"), "digest")
+}
+
+func testStreebog512() error {
+	h := gost34112012512.New()
+	h.Write(streebogM1)
+	return expect(h.Sum(nil), unhex("1b54d01a4af5b9d5cc3d86d68d285462b19abc2475222f35c085122be4ba1ffa00ad30f8767b3a82384c6574f024c311e2a481332b08ef7f41797891c1646f48"), "digest")
+}
+
+func test341194() error {
+	h := gost341194.New(gost341194.SboxDefault)
+	h.Write([]byte("abc"))
+	return expect(h.Sum(nil), unhex("f3134348c44fb1b2a277729e2285ebb5cb5e0f29c975bc753b70497c06a4d51d"), "digest")
+}
+
+// Deterministic signing with the given k, then verification of the
+// expected signature and rejection of the modified digest.
+func signVerify(prv *gost3410.PrivateKey, pubRaw, digest, k, signature []byte) error {
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return err
+	}
+	if err = expect(pub.Raw(), pubRaw, "public key"); err != nil {
+		return err
+	}
+	sign, err := prv.SignDigest(digest, bytes.NewReader(k))
+	if err != nil {
+		return err
+	}
+	if err = expect(sign, signature, "signature"); err != nil {
+		return err
+	}
+	valid, err := pub.VerifyDigest(digest, signature)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("valid signature rejected")
+	}
+	tampered := append([]byte{}, digest...)
+	tampered[0] ^= 0x01
+	if valid, _ = pub.VerifyDigest(tampered, signature); valid {
+		return errors.New("invalid signature accepted")
+	}
+	return nil
+}
+
+// RFC 5832 section 7.
+func test3410() error {
+	c := gost3410.CurveIdGostR34102001TestParamSet()
+	prv := privateKey(c, gost3410.Mode2001, "283bec9198ce191dee7e39491f96601bc1729ad39d35ed10beb99b78de9a927a")
+	defer prv.Destroy()
+	return signVerify(
+		prv,
+		unhex("0bd86fe5d8db89668f789b4e1dba8585c5508b45ec5b59d8906ddb70e2492b7fda77ff871a10fbdf2766d293c5d164afbb3c7b973a41c885d11d70d689b4f126"),
+		unhex("2dfbc1b372d89a1188c09c52e0eec61fce52032ab1022e8e67ece6672b043ee5"),
+		unhex("77105c9b20bcd3122823c8cf6fcc7b956de33814e95b7fe64fed924594dceab3"),
+		unhex("01456c64ba4642a1653c235a98a60249bcd6d3f746b631df928014f6c5bf9c4041aa28d2f1ab148280cd9ed56feda41974053554a42767b83ad043fd39dc0493"),
+	)
+}
+
+func unbig(s string) *big.Int {
+	return new(big.Int).SetBytes(unhex(s))
+}
+
+// GOST R 34.10-2012 appendix A.2.
+func test3410512() error {
+	c, err := gost3410.NewCurve(
+		"GOST R 34.10-2012 512 test",
+		unbig("4531acd1fe0023c7550d267b6b2fee80922b14b2ffb90f04d4eb7c09b5d2d15df1d852741af4704a0458047e80e4546d35b8336fac224dd81664bbf528be6373"),
+		unbig("4531acd1fe0023c7550d267b6b2fee80922b14b2ffb90f04d4eb7c09b5d2d15da82f2d7ecb1dbac719905c5eecc423f1d86e25edbe23c595d644aaf187e6e6df"),
+		big.NewInt(7),
+		unbig("1cff0806a31116da29d8cfa54e57eb748bc5f377e49400fdd788b649eca1ac4361834013b2ad7322480a89ca58e0cf74bc9e540c2add6897fad0a3084f302adc"),
+		unbig("24d19cc64572ee30f396bf6ebbfd7a6c5213b3b3d7057cc825f91093a68cd762fd60611262cd838dc6b60aa7eee804e28bc849977fac33b4b530f1b120248a9a"),
+		unbig("2bb312a43bd2ce6e0d020613c857acddcfbf061e91e5f2c3f32447c259f39b2c83ab156d77f1496bf7eb3351e1ee4e43dc1a18b91b24640b6dbb92cb1add371e"),
+		nil,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	prv := privateKey(c, gost3410.Mode2012, "d48da11f826729c6dfaa18fd7b6b63a214277e82d2da223356a000223b12e87220108b508e50e70e70694651e8a09130c9d75677d43609a41b24aead8a04a60b")
+	defer prv.Destroy()
+	return signVerify(
+		prv,
+		unhex("e1ef30d52c6133ddd99d1d5c41455cf7df4d8b4c925bbc69af1433d15658515add2146850c325c5b81c133be655aa8c4d440e7b98a8d59487b0c7696bcc55d11"+
+			"ecbe7736a9ec357ff2fd39931f4e114cb8cda359270ac7f0e7ff43d9419419ea61fd2ab77f5d9f63523d3b50a04f63e2a0cf51b7c13adc21560f0bd40cc9c737"),
+		unhex("3754f3cfacc9e0615c4f4a7c4d8dab531b09b6f9c170c533a71d147035b0c5917184ee536593f4414339976c647c5d5a407adedb1d560c4fc6777d2972075b8c"),
+		unhex("0359e7f4b1410feacc570456c6801496946312120b39d019d455986e364f365886748ed7a44b3e794434006011842286212273a6d14cf70ea3af71bb1ae679f1"),
+		unhex("1081b394696ffe8e6585e7a9362d26b6325f56778aadbc081c0bfbe933d52ff5823ce288e8c4f362526080df7f70ce406a6eeb1f56919cb92a9853bde73e5b4a"+
+			"2f86fa60a081091a23dd795e1e3c689ee512a3c82ee0dcc2643c78eea8fcacd35492558486b20f1c9ec197c90699850260c93bcbcd9c5c3317e19344e173ae36"),
+	)
+}
+
+func vko(prv1, prv2 *gost3410.PrivateKey, kek func(prv *gost3410.PrivateKey, pub *gost3410.PublicKey) ([]byte, error), want []byte) error {
+	pub1, err := prv1.PublicKey()
+	if err != nil {
+		return err
+	}
+	pub2, err := prv2.PublicKey()
+	if err != nil {
+		return err
+	}
+	kek1, err := kek(prv1, pub2)
+	if err != nil {
+		return err
+	}
+	kek2, err := kek(prv2, pub1)
+	if err != nil {
+		return err
+	}
+	if err = expect(kek1, want, "KEK"); err != nil {
+		return err
+	}
+	return expect(kek2, want, "peer KEK")
+}
+
+func testVKO2001() error {
+	c := gost3410.CurveIdGostR34102001TestParamSet()
+	prv1 := privateKey(c, gost3410.Mode2001, "1df129e43dab345b68f6a852f4162dc69f36b2f84717d08755cc5c44150bf928")
+	defer prv1.Destroy()
+	prv2 := privateKey(c, gost3410.Mode2001, "5b9356c6474f913f1e83885ea0edd5df1a43fd9d799d219093241157ac9ed473")
+	defer prv2.Destroy()
+	ukm := gost3410.NewUKM(unhex("5172be25f852a233"))
+	return vko(prv1, prv2, func(prv *gost3410.PrivateKey, pub *gost3410.PublicKey) ([]byte, error) {
+		return prv.KEK2001(pub, ukm)
+	}, unhex("ee4618a0dbb10cb31777b4b86a53d9e7ef6cb3e400101410f0c0f2af46c494a6"))
+}
+
+// R 50.1.113-2016 appendix A keys.
+func vko2012(kek func(prv *gost3410.PrivateKey, pub *gost3410.PublicKey, ukm *big.Int) ([]byte, error), want []byte) error {
+	c := gost3410.CurveIdtc26gost341012512paramSetA()
+	prv1 := privateKey(c, gost3410.Mode2012, "c990ecd972fce84ec4db022778f50fcac726f46708384b8d458304962d7147f8c2db41cef22c90b102f2968404f9b9be6d47c79692d81826b32b8daca43cb667")
+	defer prv1.Destroy()
+	prv2 := privateKey(c, gost3410.Mode2012, "48c859f7b6f11585887cc05ec6ef1390cfea739b1a18c0d4662293ef63b79e3b8014070b44918590b4b996acfea4edfbbbcccc8c06edd8bf5bda92a51392d0db")
+	defer prv2.Destroy()
+	ukm := gost3410.NewUKM(unhex("1d80603c8544c727"))
+	return vko(prv1, prv2, func(prv *gost3410.PrivateKey, pub *gost3410.PublicKey) ([]byte, error) {
+		return kek(prv, pub, ukm)
+	}, want)
+}
+
+func testVKO2012256() error {
+	return vko2012(
+		(*gost3410.PrivateKey).KEK2012256,
+		unhex("c9a9a77320e2cc559ed72dce6f47e2192ccea95fa648670582c054c0ef36c221"),
+	)
+}
+
+func testVKO2012512() error {
+	return vko2012(
+		(*gost3410.PrivateKey).KEK2012512,
+		unhex("79f002a96940ce7bde3259a52e015297adaad84597a0d205b50e3e1719f97bfa7ee1d2661fa9979a5aa235b558a7e6d9f88f982dd63fc35a8ec0dd5e242d3bdf"),
+	)
+}
+
+// R 50.1.113-2016 section 4.5.
+func testKDF() error {
+	kdf := gost34112012256.NewKDF(unhex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))
+	defer kdf.Destroy()
+	return expect(
+		kdf.Derive(nil, unhex("26bdb878"), unhex("af21434145656378")),
+		unhex("a1aa5f7de402d7b3d323f2991c8d4534013137010a83754fd0af6d7cd4922ed9"),
+		"key",
+	)
+}
+
+var r501113Key = unhex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+
+// R 50.1.113-2016 KDF_TREE example.
+func testKDFTree() error {
+	return expect(
+		gost34112012256.KDFTree(r501113Key, unhex("26bdb878"), unhex("af21434145656378"), 1, 512),
+		unhex("22b6837845c6bef65ea71672b265831086d3c76aebe6dae91cad51d83f79d16b"+
+			"074c9330599d7f8d712fca54392f4ddde93751206b3584c8f43f9e6dc51531f9"),
+		"key",
+	)
+}
+
+// R 50.1.113-2016 HMAC example.
+func testHMAC() error {
+	m := gost34112012256.NewHMAC(r501113Key)
+	m.Write(unhex("0126bdb87800af214341456563780100"))
+	return expect(m.Sum(nil), unhex("a1aa5f7de402d7b3d323f2991c8d4534013137010a83754fd0af6d7cd4922ed9"), "MAC")
+}
+
+// R 50.1.113-2016 PRF example.
+func testPRF() error {
+	return expect(
+		gost34112012256.PRF(
+			r501113Key,
+			unhex("1122334455"),
+			unhex("18471d622dc655c4d2d2269691ca4a560b50aba663553af241f1ada882c9f29a"),
+			64,
+		),
+		unhex("ff09664a44745865944f839ebb48965f1544ff1cc8e8f16f247ee5f8a9ebe97f"+
+			"c4e3c7900e46cad3db6a01643063040ec67fc0fd5cd9f90465235237bdff2c02"),
+		"output",
+	)
+}
+
+// R 50.1.111-2016 example with c=1.
+func testPBKDF2() error {
+	return expect(
+		pkcs5gost.Key([]byte("password"), []byte("salt"), 1, 64),
+		unhex("64770af7f748c3b1c9ac831dbcfd85c26111b30a8a657ddc3056b80ca73e040d"+
+			"2854fd36811f6d825cc4ab66ec0a68a490a9e5cf5156b3a2b7eecddbf9a16b47"),
+		"key",
+	)
+}
+
+// R 1323565.1.030-2019 appendix A.
+func testTLSTree() error {
+	tt := gost34112012256.NewTLSTree(
+		gost34112012256.TLSGOSTR341112256WithMagmaCTROMAC,
+		bytes.Repeat([]byte{0xFF}, 32),
+	)
+	defer tt.Destroy()
+	return expect(tt.Derive(0), unhex("507642d958c520c6d7eef5ca8a5316d4f34b855d2dd4bcbf4e5bf0ff641a19ff"), "key")
+}
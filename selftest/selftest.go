@@ -0,0 +1,131 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Power-on self-tests of the library primitives and strict module mode.
+//
+// Run executes known-answer tests for every primitive and returns
+// structured results. After EnableStrict, cipher constructors panic,
+// while private key constructors and higher level functions (key wraps,
+// PBES2, CMS, TLS record protection, HPKE) return ErrNotReady until Run
+// succeeds; key generation additionally performs pairwise consistency
+// test. Hash functions using ciphers internally are never gated.
+package selftest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddulesov/gogost/internal/strict"
+)
+
+var ErrNotReady = strict.ErrNotReady
+
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+type Report struct {
+	Results []Result
+}
+
+func (r *Report) Passed() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed tests results.
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Passed() {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Error describing all failed tests, nil if everything passed.
+func (r *Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(failed))
+	for _, res := range failed {
+		msgs = append(msgs, res.Name+": "+res.Err.Error())
+	}
+	return errors.New("selftest: " + strings.Join(msgs, "; "))
+}
+
+func runTest(t test) (res Result) {
+	res.Name = t.name
+	started := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			res.Err = fmt.Errorf("panic: %v", r)
+		}
+		res.Duration = time.Since(started)
+	}()
+	res.Err = t.run()
+	return
+}
+
+var (
+	once   sync.Once
+	report *Report
+)
+
+// Run all known-answer tests. They are executed only on the first call,
+// subsequent ones return the same report. In strict mode primitives are
+// unavailable to the callers until the tests finish and afterwards are
+// allowed only if all of them passed.
+func Run() *Report {
+	once.Do(func() {
+		r := Report{Results: make([]Result, 0, len(tests))}
+		for _, t := range tests {
+			r.Results = append(r.Results, runTest(t))
+		}
+		report = &r
+		if r.Passed() {
+			strict.SetState(strict.Passed)
+		} else {
+			strict.SetState(strict.Failed)
+		}
+	})
+	return report
+}
+
+// Enable strict mode. It can not be disabled.
+func EnableStrict() {
+	strict.Enable()
+}
+
+func Strict() bool {
+	return strict.Enabled()
+}
+
+// Have self-tests passed: Run was called and all tests succeeded.
+func Passed() bool {
+	return strict.State() == strict.Passed
+}
@@ -0,0 +1,164 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package selftest
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost341194"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/pkcs5gost"
+)
+
+func panics(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return
+}
+
+// Strict mode state is global, so that test must go before any other
+// calling Run.
+func TestStrict(t *testing.T) {
+	key := make([]byte, 32)
+	curve := gost3410.CurveIdGostR34102001TestParamSet()
+	EnableStrict()
+	if !Strict() || Passed() {
+		t.FailNow()
+	}
+	if !panics(func() { gost28147.NewCipher(key, gost28147.SboxDefault) }) {
+		t.Fatal("28147-89 cipher created")
+	}
+	if !panics(func() { gost3412128.NewCipher(key) }) {
+		t.Fatal("Kuznechik cipher created")
+	}
+	if !panics(func() { gost341264.NewCipher(key) }) {
+		t.Fatal("Magma cipher created")
+	}
+	if panics(func() { strict.NewKuznyechik(key) }) {
+		t.Fatal("internal constructor panicked")
+	}
+	if _, err := gost3410.GenPrivateKey(curve, gost3410.Mode2001, rand.Reader); err != ErrNotReady {
+		t.Fatal("private key created")
+	}
+	if _, err := gost28147.WrapCryptoPro(key, key[:gost28147.UKMSize], key, gost28147.SboxDefault); err != ErrNotReady {
+		t.Fatal("key wrapped")
+	}
+	params, err := pkcs5gost.NewParams(rand.Reader, pkcs5gost.Gost28147CFB, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = params.Encrypt([]byte("password"), []byte("data")); err != ErrNotReady {
+		t.Fatal("PBES2 encryption done")
+	}
+	// Hashes using ciphers internally are not gated
+	if panics(func() {
+		h := gost341194.New(gost341194.SboxDefault)
+		h.Write([]byte("abc"))
+		h.Sum(nil)
+	}) {
+		t.Fatal("34.11-94 panicked")
+	}
+
+	// Gate is closed for everyone but self-tests during the run and
+	// after the failed run
+	origTests := tests
+	defer func() { tests = origTests }()
+	rerun := func() *Report {
+		once = sync.Once{}
+		return Run()
+	}
+	gateClosed := test{"gate", func() error {
+		if !panics(func() { gost3412128.NewCipher(key) }) {
+			return errors.New("gate is open")
+		}
+		return nil
+	}}
+	tests = append(append([]test{}, origTests...), gateClosed)
+	report := Run()
+	if !report.Passed() {
+		t.Fatal(report.Err())
+	}
+	if !Passed() {
+		t.FailNow()
+	}
+	tests = nil
+	if Run() != report || !Passed() {
+		t.Fatal("tests rerun")
+	}
+	gost3412128.NewCipher(key)
+	if _, err = gost3410.GenPrivateKey(curve, gost3410.Mode2001, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = params.Encrypt([]byte("password"), []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	tests = append(append([]test{}, origTests...), test{"failing", func() error {
+		return errors.New("mismatch")
+	}})
+	if report := rerun(); report.Passed() || Passed() {
+		t.FailNow()
+	}
+	if !panics(func() { gost341264.NewCipher(key) }) {
+		t.Fatal("Magma cipher created after failure")
+	}
+	if _, err = gost3410.GenPrivateKey(curve, gost3410.Mode2001, rand.Reader); err != ErrNotReady {
+		t.Fatal("private key created after failure")
+	}
+	tests = origTests
+	if report := rerun(); !report.Passed() || !Passed() {
+		t.FailNow()
+	}
+}
+
+func TestRun(t *testing.T) {
+	report := Run()
+	if len(report.Results) != len(tests) {
+		t.FailNow()
+	}
+	for _, res := range report.Results {
+		if res.Err != nil {
+			t.Error(res.Name, res.Err)
+		}
+	}
+	if !report.Passed() || report.Err() != nil || len(report.Failed()) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestFailure(t *testing.T) {
+	res := runTest(test{"failing", func() error { return errors.New("mismatch") }})
+	if res.Passed() || res.Name != "failing" {
+		t.FailNow()
+	}
+	res = runTest(test{"panicking", func() error { panic("oops") }})
+	if res.Passed() {
+		t.FailNow()
+	}
+	report := Report{Results: []Result{{Name: "ok"}, res}}
+	if report.Passed() || len(report.Failed()) != 1 || report.Err() == nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,198 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sespake
+
+import (
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+// Parameter sets of RFC 8133 with its point Q_1 for each curve.
+var (
+	ParamsIdGostR34102001CryptoProAParamSet func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdGostR34102001CryptoProAParamSet(),
+			Mode:     gost3410.Mode2001,
+			Cofactor: 1,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0xa6, 0x9d, 0x51, 0xca, 0xf1, 0xa3, 0x09, 0xfa,
+					0x9e, 0x9b, 0x66, 0x18, 0x77, 0x59, 0xb0, 0x17,
+					0x4c, 0x27, 0x4e, 0x08, 0x03, 0x56, 0xf2, 0x3c,
+					0xfc, 0xbf, 0xe8, 0x4d, 0x39, 0x6a, 0xd7, 0xbb,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x5d, 0x26, 0xf2, 0x9e, 0xcc, 0x2e, 0x9a, 0xc0,
+					0x40, 0x4d, 0xcf, 0x79, 0x86, 0xfa, 0x55, 0xfe,
+					0x94, 0x98, 0x63, 0x62, 0x17, 0x0f, 0x54, 0xb9,
+					0x61, 0x64, 0x26, 0xa6, 0x59, 0x78, 0x6d, 0xac,
+				}),
+			}},
+		}
+	}
+	ParamsIdGostR34102001CryptoProBParamSet func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdGostR34102001CryptoProBParamSet(),
+			Mode:     gost3410.Mode2001,
+			Cofactor: 1,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0x3d, 0x71, 0x5a, 0x87, 0x4a, 0x4b, 0x17, 0xcb,
+					0x3b, 0x51, 0x78, 0x93, 0xa9, 0x79, 0x4a, 0x2b,
+					0x36, 0xc8, 0x9d, 0x2f, 0xfc, 0x69, 0x3f, 0x01,
+					0xee, 0x4c, 0xc2, 0x7e, 0x7f, 0x49, 0xe3, 0x99,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x1c, 0x5a, 0x64, 0x1f, 0xcf, 0x7c, 0xe7, 0xe8,
+					0x7c, 0xdf, 0x8c, 0xea, 0x38, 0xf3, 0xdb, 0x30,
+					0x96, 0xea, 0xce, 0x2f, 0xad, 0x15, 0x83, 0x84,
+					0xb5, 0x39, 0x53, 0x36, 0x5f, 0x4f, 0xe7, 0xfe,
+				}),
+			}},
+		}
+	}
+	ParamsIdGostR34102001CryptoProCParamSet func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdGostR34102001CryptoProCParamSet(),
+			Mode:     gost3410.Mode2001,
+			Cofactor: 1,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0x1e, 0x36, 0x38, 0x3e, 0x43, 0xbb, 0x6c, 0xfa,
+					0x29, 0x17, 0x16, 0x7d, 0x71, 0xb7, 0xb5, 0xdd,
+					0x3d, 0x6d, 0x46, 0x2b, 0x43, 0xd7, 0xc6, 0x42,
+					0x82, 0xae, 0x67, 0xdf, 0xbe, 0xc2, 0x55, 0x9d,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x13, 0x74, 0x78, 0xa9, 0xf7, 0x21, 0xc7, 0x39,
+					0x32, 0xea, 0x06, 0xb4, 0x5c, 0xf7, 0x2e, 0x37,
+					0xeb, 0x78, 0xa6, 0x3f, 0x29, 0xa5, 0x42, 0xe5,
+					0x63, 0xc6, 0x14, 0x65, 0x0c, 0x8b, 0x63, 0x99,
+				}),
+			}},
+		}
+	}
+	ParamsIdtc26gost34102012512paramSetA func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdtc26gost341012512paramSetA(),
+			Mode:     gost3410.Mode2012,
+			Cofactor: 1,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0x2a, 0x17, 0xf8, 0x83, 0x3a, 0x32, 0x79, 0x53,
+					0x27, 0x47, 0x88, 0x71, 0xb5, 0xc5, 0xe8, 0x8a,
+					0xef, 0xb9, 0x11, 0x26, 0xc6, 0x4b, 0x4b, 0x83,
+					0x27, 0x28, 0x9b, 0xea, 0x62, 0x55, 0x94, 0x25,
+					0xd1, 0x81, 0x98, 0xf1, 0x33, 0xf4, 0x00, 0x87,
+					0x43, 0x28, 0xb2, 0x20, 0xc7, 0x44, 0x97, 0xcd,
+					0x24, 0x05, 0x86, 0xcb, 0x24, 0x9e, 0x15, 0x85,
+					0x32, 0xcb, 0x80, 0x90, 0x77, 0x6c, 0xd6, 0x1c,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x72, 0x8f, 0x0c, 0x4a, 0x73, 0xb4, 0x8d, 0xa4,
+					0x1c, 0xe9, 0x28, 0x35, 0x8f, 0xad, 0x26, 0xb4,
+					0x7a, 0x6e, 0x09, 0x4e, 0x93, 0x62, 0xba, 0xe8,
+					0x25, 0x59, 0xf8, 0x3c, 0xdd, 0xc4, 0xec, 0x3a,
+					0x46, 0x76, 0xbd, 0x37, 0x07, 0xed, 0xea, 0xf4,
+					0xcd, 0x85, 0xe9, 0x96, 0x95, 0xc6, 0x4c, 0x24,
+					0x1e, 0xdc, 0x62, 0x2b, 0xe8, 0x7d, 0xc0, 0xcf,
+					0x87, 0xf5, 0x1f, 0x43, 0x67, 0xf7, 0x23, 0xc5,
+				}),
+			}},
+		}
+	}
+	ParamsIdtc26gost34102012512paramSetB func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdtc26gost341012512paramSetB(),
+			Mode:     gost3410.Mode2012,
+			Cofactor: 1,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0x7e, 0x1f, 0xae, 0x82, 0x85, 0xe0, 0x35, 0xbe,
+					0xc2, 0x44, 0xbe, 0xf2, 0xd0, 0xe5, 0xeb, 0xf4,
+					0x36, 0x63, 0x3c, 0xf5, 0x0e, 0x55, 0x23, 0x1d,
+					0xea, 0x9c, 0x9c, 0xf2, 0x1d, 0x4c, 0x8c, 0x33,
+					0xdf, 0x85, 0xd4, 0x30, 0x5d, 0xe9, 0x29, 0x71,
+					0xf0, 0xa4, 0xb4, 0xc0, 0x7e, 0x00, 0xd8, 0x7b,
+					0xdb, 0xc7, 0x20, 0xeb, 0x66, 0xe4, 0x90, 0x79,
+					0x28, 0x5a, 0xaf, 0x12, 0xe0, 0x17, 0x11, 0x49,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x2c, 0xc8, 0x99, 0x98, 0xb8, 0x75, 0xd4, 0x46,
+					0x38, 0x05, 0xba, 0x0d, 0x85, 0x8a, 0x19, 0x65,
+					0x92, 0xdb, 0x20, 0xab, 0x16, 0x15, 0x58, 0xff,
+					0x2f, 0x4e, 0xf7, 0xa8, 0x57, 0x25, 0xd2, 0x09,
+					0x53, 0x96, 0x7a, 0xe6, 0x21, 0xaf, 0xde, 0xae,
+					0x89, 0xbb, 0x77, 0xc8, 0x3a, 0x25, 0x28, 0xef,
+					0x6f, 0xce, 0x02, 0xf6, 0x8b, 0xda, 0x46, 0x79,
+					0xd7, 0xf2, 0x70, 0x49, 0x47, 0xdb, 0xc4, 0x08,
+				}),
+			}},
+		}
+	}
+	ParamsIdtc26gost34102012256paramSetA func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdtc26gost34102012256paramSetA(),
+			Mode:     gost3410.Mode2001,
+			Cofactor: 4,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0xb5, 0x1a, 0xdf, 0x93, 0xa4, 0x0a, 0xb1, 0x57,
+					0x92, 0x16, 0x4f, 0xad, 0x33, 0x52, 0xf9, 0x5b,
+					0x66, 0x36, 0x9e, 0xb2, 0xa4, 0xef, 0x5e, 0xfa,
+					0xe3, 0x28, 0x29, 0x32, 0x03, 0x63, 0x35, 0x0e,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x74, 0xa3, 0x58, 0xcc, 0x08, 0x59, 0x36, 0x12,
+					0xf5, 0x95, 0x5d, 0x24, 0x9c, 0x96, 0xaf, 0xb7,
+					0xe8, 0xb0, 0xbb, 0x6d, 0x8b, 0xd2, 0xbb, 0xe4,
+					0x91, 0x04, 0x66, 0x50, 0xd8, 0x22, 0xbe, 0x18,
+				}),
+			}},
+		}
+	}
+	ParamsIdtc26gost34102012512paramSetC func() *Params = func() *Params {
+		return &Params{
+			Curve:    gost3410.CurveIdtc26gost34102012512paramSetC(),
+			Mode:     gost3410.Mode2012,
+			Cofactor: 4,
+			Points: []Point{{
+				X: new(big.Int).SetBytes([]byte{
+					0x48, 0x9c, 0x91, 0x78, 0x4e, 0x02, 0xe9, 0x8f,
+					0x19, 0xa8, 0x03, 0xab, 0xca, 0x31, 0x99, 0x17,
+					0xf3, 0x76, 0x89, 0xe5, 0xa1, 0x89, 0x65, 0x25,
+					0x1c, 0xe2, 0xff, 0x4e, 0x8d, 0x8b, 0x29, 0x8f,
+					0x5b, 0xa7, 0x47, 0x0f, 0x9e, 0x0e, 0x71, 0x34,
+					0x87, 0xf9, 0x6f, 0x4a, 0x83, 0x97, 0xb3, 0xd0,
+					0x9a, 0x27, 0x0c, 0x9d, 0x36, 0x7e, 0xb5, 0xe0,
+					0xe6, 0x56, 0x1a, 0xde, 0xeb, 0x51, 0x58, 0x1d,
+				}),
+				Y: new(big.Int).SetBytes([]byte{
+					0x68, 0x4e, 0xa8, 0x85, 0xac, 0xa6, 0x4e, 0xaf,
+					0x1b, 0x3f, 0xee, 0x36, 0xc0, 0x85, 0x2a, 0x3b,
+					0xe3, 0xbd, 0x80, 0x11, 0xb0, 0xef, 0x18, 0xe2,
+					0x03, 0xff, 0x87, 0x02, 0x8d, 0x6e, 0xb5, 0xdb,
+					0x2c, 0x14, 0x4a, 0x0d, 0xcc, 0x71, 0x27, 0x65,
+					0x42, 0xbf, 0xd7, 0x2c, 0xa2, 0xa4, 0x3f, 0xa4,
+					0xf4, 0x93, 0x9d, 0xa6, 0x6d, 0x9a, 0x60, 0x79,
+					0x3c, 0x70, 0x4a, 0x8c, 0x94, 0xe1, 0x6f, 0x18,
+				}),
+			}},
+		}
+	}
+)
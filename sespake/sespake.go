@@ -0,0 +1,531 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// SESPAKE balanced password-authenticated key exchange protocol
+// (R 50.1.115-2016, RFC 8133) on GOST R 34.10 elliptic curves.
+//
+// Client (A) and server (B) share the password. Server keeps only the
+// Verifier: index of the curve's point Q_ind, salt and password point
+// Q_PW = INT(F(PW, salt, n)) * Q_ind, where F is PBKDF2 with
+// HMAC_GOSTR3411_2012_512. Protocol flow:
+//
+//	A: u1 = alpha * P - Q_PW                 --> u1
+//	B: K_B = HASH((m/q * beta mod q) * (u1 + Q_PW))
+//	   u2 = beta * P + Q_PW                  <-- u2
+//	A: K_A = HASH((m/q * alpha mod q) * (u2 - Q_PW))
+//	   MAC_A = HMAC(K_A, 0x01 || ID_A || ind || salt || u1 || u2 || DATA_A)
+//	                                         --> MAC_A
+//	B: checks MAC_A
+//	   MAC_B = HMAC(K_B, 0x02 || ID_B || ind || salt || u1 || u2 ||
+//	                MAC_A || DATA_B)         <-- MAC_B
+//	A: checks MAC_B
+//
+// HASH is GOST R 34.11-2012 256-bit, HMAC is HMAC_GOSTR3411_2012_256.
+// Points are encoded as gost3410.PublicKey.Raw. After successful
+// confirmation both sides share K_A = K_B.
+package sespake
+
+import (
+	"crypto/hmac"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/pkcs5gost"
+)
+
+const (
+	KeySize = gost34112012256.Size
+
+	// Default number of PBKDF2 iterations for the password point.
+	DefaultIterations = 2000
+)
+
+var (
+	bigInt1 = big.NewInt(1)
+	bigInt3 = big.NewInt(3)
+
+	ErrState       = errors.New("sespake: unexpected protocol step")
+	ErrAuthFailed  = errors.New("sespake: authentication failed")
+	ErrInvalidPeer = errors.New("sespake: invalid peer's point")
+)
+
+// Affine point of the curve. Point at infinity has nil coordinates.
+type Point struct {
+	X *big.Int
+	Y *big.Int
+}
+
+func (pt Point) infinity() bool {
+	return pt.X == nil
+}
+
+// Protocol parameters: the curve and its points Q_1..Q_N with unknown
+// discrete logarithms to the base point.
+type Params struct {
+	Curve *gost3410.Curve
+	Mode  gost3410.Mode
+	// Curve's cofactor m/q, 1 if zero
+	Cofactor int64
+	Points   []Point
+	// PBKDF2 iterations, DefaultIterations if zero
+	Iterations int
+}
+
+func (p *Params) cofactor() int64 {
+	if p.Cofactor == 0 {
+		return 1
+	}
+	return p.Cofactor
+}
+
+func (p *Params) iterations() int {
+	if p.Iterations == 0 {
+		return DefaultIterations
+	}
+	return p.Iterations
+}
+
+func (p *Params) base() Point {
+	return Point{p.Curve.X, p.Curve.Y}
+}
+
+func (p *Params) onCurve(pt Point) bool {
+	if pt.infinity() {
+		return false
+	}
+	pub := gost3410.PublicKey{C: p.Curve, Mode: p.Mode, X: pt.X, Y: pt.Y}
+	return pub.OnCurve()
+}
+
+// Point Q_ind, indexed from 1 as in the standard.
+func (p *Params) point(ind int) (Point, error) {
+	if ind < 1 || ind > len(p.Points) || ind > 0xFF {
+		return Point{}, errors.New("sespake: invalid point index")
+	}
+	pt := p.Points[ind-1]
+	if !p.onCurve(pt) {
+		return Point{}, errors.New("sespake: point is not on curve")
+	}
+	return pt, nil
+}
+
+func (p *Params) add(a, b Point) Point {
+	if a.infinity() {
+		return b
+	}
+	if b.infinity() {
+		return a
+	}
+	c := p.Curve
+	l := new(big.Int)
+	d := new(big.Int)
+	if a.X.Cmp(b.X) == 0 {
+		if a.Y.Cmp(b.Y) != 0 || a.Y.Sign() == 0 {
+			return Point{}
+		}
+		l.Mul(a.X, a.X)
+		l.Mul(l, bigInt3)
+		l.Add(l, c.A)
+		d.Lsh(a.Y, 1)
+	} else {
+		l.Sub(b.Y, a.Y)
+		d.Sub(b.X, a.X)
+		d.Mod(d, c.P)
+	}
+	d.ModInverse(d, c.P)
+	l.Mul(l, d)
+	l.Mod(l, c.P)
+	x := new(big.Int).Mul(l, l)
+	x.Sub(x, a.X)
+	x.Sub(x, b.X)
+	x.Mod(x, c.P)
+	y := new(big.Int).Sub(a.X, x)
+	y.Mul(y, l)
+	y.Sub(y, a.Y)
+	y.Mod(y, c.P)
+	return Point{x, y}
+}
+
+func (p *Params) neg(a Point) Point {
+	if a.infinity() {
+		return a
+	}
+	y := new(big.Int).Neg(a.Y)
+	return Point{a.X, y.Mod(y, p.Curve.P)}
+}
+
+// k * pt for the point of order q.
+func (p *Params) mul(k *big.Int, pt Point) (Point, error) {
+	k = new(big.Int).Mod(k, p.Curve.Q)
+	defer wipe.BigInt(k)
+	if k.Sign() == 0 || pt.infinity() {
+		return Point{}, nil
+	}
+	x, y, err := p.Curve.Exp(k, pt.X, pt.Y)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{x, y}, nil
+}
+
+// Is m/q * pt the point at infinity. Cofactor is small, so it is
+// multiplied by repeated addition, handling the infinity.
+func (p *Params) lowOrder(pt Point) bool {
+	acc := Point{}
+	for i := int64(0); i < p.cofactor(); i++ {
+		acc = p.add(acc, pt)
+	}
+	return acc.infinity()
+}
+
+func (p *Params) encode(pt Point) []byte {
+	pub := gost3410.PublicKey{C: p.Curve, Mode: p.Mode, X: pt.X, Y: pt.Y}
+	return pub.Raw()
+}
+
+func (p *Params) decode(raw []byte) (Point, error) {
+	pub, err := gost3410.NewPublicKey(p.Curve, p.Mode, raw)
+	if err != nil {
+		return Point{}, err
+	}
+	pt := Point{pub.X, pub.Y}
+	if !p.onCurve(pt) {
+		return Point{}, ErrInvalidPeer
+	}
+	return pt, nil
+}
+
+// Random scalar in [1, q-1].
+func (p *Params) scalar(rand io.Reader) (*big.Int, error) {
+	raw := make([]byte, int(p.Mode))
+	defer wipe.Bytes(raw)
+	for {
+		if _, err := io.ReadFull(rand, raw); err != nil {
+			return nil, err
+		}
+		k := new(big.Int).SetBytes(raw)
+		k.Mod(k, p.Curve.Q)
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+// Little-endian integer, as GOST represents numbers in byte strings.
+func leInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := 0; i < len(b); i++ {
+		be[i] = b[len(b)-1-i]
+	}
+	defer wipe.Bytes(be)
+	return new(big.Int).SetBytes(be)
+}
+
+// Password point Q_PW = INT(F(PW, salt, n)) * Q_ind.
+func (p *Params) PasswordPoint(password, salt []byte, ind int) (Point, error) {
+	q, err := p.point(ind)
+	if err != nil {
+		return Point{}, err
+	}
+	f := pkcs5gost.Key(password, salt, p.iterations(), int(p.Mode))
+	defer wipe.Bytes(f)
+	k := leInt(f)
+	defer wipe.BigInt(k)
+	pw, err := p.mul(k, q)
+	if err != nil {
+		return Point{}, err
+	}
+	if pw.infinity() {
+		return Point{}, errors.New("sespake: degenerate password point")
+	}
+	return pw, nil
+}
+
+// Shared key HASH((m/q * k mod q) * pt) and whether m/q * pt is
+// infinity. In that case the base point is used instead, to process it
+// indistinguishably, but the protocol must fail.
+func (p *Params) sharedKey(k *big.Int, pt Point) ([]byte, bool, error) {
+	degenerate := p.lowOrder(pt)
+	if degenerate {
+		pt = p.base()
+	}
+	e := new(big.Int).Mul(big.NewInt(p.cofactor()), k)
+	defer wipe.BigInt(e)
+	src, err := p.mul(e, pt)
+	if err != nil {
+		return nil, false, err
+	}
+	if src.infinity() {
+		return nil, false, ErrInvalidPeer
+	}
+	h := gost34112012256.New()
+	h.Write(p.encode(src))
+	return h.Sum(nil), degenerate, nil
+}
+
+// Server-side password verifier.
+type Verifier struct {
+	Index int
+	Salt  []byte
+	Point Point
+}
+
+// Create server-side verifier of the password with given salt and
+// point's index.
+func NewVerifier(params *Params, password, salt []byte, ind int) (*Verifier, error) {
+	pw, err := params.PasswordPoint(password, salt, ind)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{ind, salt, pw}, nil
+}
+
+// State common to both sides.
+type session struct {
+	params *Params
+	rand   io.Reader
+	idA    []byte
+	idB    []byte
+	ind    int
+	salt   []byte
+	qpw    Point
+	k      *big.Int
+	u1     []byte
+	u2     []byte
+	key    []byte
+	bad    bool
+	macA   []byte
+	step   int
+}
+
+func (s *session) mac(tag byte, id []byte, tail ...[]byte) []byte {
+	m := gost34112012256.NewHMAC(s.key)
+	m.Write([]byte{tag})
+	m.Write(id)
+	m.Write([]byte{byte(s.ind)})
+	m.Write(s.salt)
+	m.Write(s.u1)
+	m.Write(s.u2)
+	for _, b := range tail {
+		m.Write(b)
+	}
+	return m.Sum(nil)
+}
+
+// Random k and k * P + sign * Q_PW, that must not be infinity.
+func (s *session) ephemeral(neg bool) ([]byte, error) {
+	p := s.params
+	qpw := s.qpw
+	if neg {
+		qpw = p.neg(qpw)
+	}
+	for {
+		k, err := p.scalar(s.rand)
+		if err != nil {
+			return nil, err
+		}
+		kp, err := p.mul(k, p.base())
+		if err != nil {
+			return nil, err
+		}
+		u := p.add(kp, qpw)
+		if u.infinity() {
+			wipe.BigInt(k)
+			continue
+		}
+		s.k = k
+		return p.encode(u), nil
+	}
+}
+
+// Shared key from the peer's u: HASH((m/q * k mod q) * (u + sign * Q_PW)).
+func (s *session) agree(u []byte, neg bool) error {
+	p := s.params
+	pt, err := p.decode(u)
+	if err != nil {
+		return err
+	}
+	qpw := s.qpw
+	if neg {
+		qpw = p.neg(qpw)
+	}
+	q := p.add(pt, qpw)
+	if q.infinity() {
+		return ErrInvalidPeer
+	}
+	s.key, s.bad, err = p.sharedKey(s.k, q)
+	return err
+}
+
+func (s *session) sharedKey(step int) ([]byte, error) {
+	if s.step != step || s.key == nil || s.bad {
+		return nil, ErrState
+	}
+	return append([]byte{}, s.key...), nil
+}
+
+// Zero the session's secrets.
+func (s *session) Destroy() {
+	if s.k != nil {
+		wipe.BigInt(s.k)
+	}
+	if s.key != nil {
+		wipe.Bytes(s.key)
+	}
+}
+
+// Client (A) side of the protocol.
+type Client struct {
+	session
+}
+
+// Client knowing the password, salt and point's index, received
+// beforehand from the server.
+func NewClient(
+	rand io.Reader,
+	params *Params,
+	password, salt []byte,
+	ind int,
+	idA, idB []byte,
+) (*Client, error) {
+	pw, err := params.PasswordPoint(password, salt, ind)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{session{
+		params: params,
+		rand:   rand,
+		idA:    idA,
+		idB:    idB,
+		ind:    ind,
+		salt:   salt,
+		qpw:    pw,
+	}}, nil
+}
+
+// Generate alpha and return u1 to be sent to the server.
+func (c *Client) Start() ([]byte, error) {
+	if c.step != 0 {
+		return nil, ErrState
+	}
+	c.step++
+	u1, err := c.ephemeral(true)
+	if err != nil {
+		return nil, err
+	}
+	c.u1 = u1
+	return u1, nil
+}
+
+// Process server's u2 and return MAC_A authenticating optional dataA.
+func (c *Client) Finish(u2, dataA []byte) ([]byte, error) {
+	if c.step != 1 {
+		return nil, ErrState
+	}
+	c.step++
+	if err := c.agree(u2, true); err != nil {
+		return nil, err
+	}
+	c.u2 = append([]byte{}, u2...)
+	c.macA = c.mac(0x01, c.idA, dataA)
+	return c.macA, nil
+}
+
+// Check server's MAC_B authenticating optional dataB. After that the
+// shared key is available.
+func (c *Client) Verify(macB, dataB []byte) error {
+	if c.step != 2 {
+		return ErrState
+	}
+	c.step++
+	if !hmac.Equal(macB, c.mac(0x02, c.idB, c.macA, dataB)) || c.bad {
+		c.Destroy()
+		c.bad = true
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// Shared key after successful confirmation.
+func (c *Client) Key() ([]byte, error) {
+	return c.sharedKey(3)
+}
+
+// Server (B) side of the protocol.
+type Server struct {
+	session
+}
+
+func NewServer(rand io.Reader, params *Params, verifier *Verifier, idA, idB []byte) (*Server, error) {
+	if _, err := params.point(verifier.Index); err != nil {
+		return nil, err
+	}
+	if !params.onCurve(verifier.Point) {
+		return nil, errors.New("sespake: invalid verifier")
+	}
+	return &Server{session{
+		params: params,
+		rand:   rand,
+		idA:    idA,
+		idB:    idB,
+		ind:    verifier.Index,
+		salt:   verifier.Salt,
+		qpw:    verifier.Point,
+	}}, nil
+}
+
+// Process client's u1 and return u2 to be sent back.
+func (s *Server) Exchange(u1 []byte) ([]byte, error) {
+	if s.step != 0 {
+		return nil, ErrState
+	}
+	s.step++
+	u2, err := s.ephemeral(false)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.agree(u1, false); err != nil {
+		return nil, err
+	}
+	s.u1 = append([]byte{}, u1...)
+	s.u2 = u2
+	return u2, nil
+}
+
+// Check client's MAC_A over optional dataA and return MAC_B
+// authenticating optional dataB. After that the shared key is
+// available.
+func (s *Server) Confirm(macA, dataA, dataB []byte) ([]byte, error) {
+	if s.step != 1 {
+		return nil, ErrState
+	}
+	s.step++
+	if !hmac.Equal(macA, s.mac(0x01, s.idA, dataA)) || s.bad {
+		s.Destroy()
+		s.bad = true
+		return nil, ErrAuthFailed
+	}
+	return s.mac(0x02, s.idB, macA, dataB), nil
+}
+
+// Shared key after successful confirmation.
+func (s *Server) Key() ([]byte, error) {
+	return s.sharedKey(2)
+}
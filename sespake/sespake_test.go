@@ -0,0 +1,486 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sespake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+)
+
+// Parameters with random points Q_i: their discrete logarithms are
+// known, so they are suitable only for tests.
+func testParams(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode, cofactor int64) *Params {
+	p := &Params{Curve: curve, Mode: mode, Cofactor: cofactor, Iterations: 10}
+	for i := 0; i < 3; i++ {
+		k, err := p.scalar(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := p.mul(k, p.base())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Points = append(p.Points, q)
+	}
+	return p
+}
+
+func allParams(t *testing.T) []*Params {
+	return []*Params{
+		testParams(t, gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001, 1),
+		testParams(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001, 4),
+		testParams(t, gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012, 1),
+		testParams(t, gost3410.CurveIdtc26gost34102012512paramSetC(), gost3410.Mode2012, 4),
+	}
+}
+
+type run struct {
+	client *Client
+	server *Server
+	u1     []byte
+	u2     []byte
+	macA   []byte
+}
+
+func start(t *testing.T, p *Params, clientPassword, serverPassword []byte) *run {
+	salt := []byte("salt salt salt salt")
+	verifier, err := NewVerifier(p, serverPassword, salt, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := run{}
+	if r.client, err = NewClient(rand.Reader, p, clientPassword, salt, 1, []byte("A"), []byte("B")); err != nil {
+		t.Fatal(err)
+	}
+	if r.server, err = NewServer(rand.Reader, p, verifier, []byte("A"), []byte("B")); err != nil {
+		t.Fatal(err)
+	}
+	if r.u1, err = r.client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if r.u2, err = r.server.Exchange(r.u1); err != nil {
+		t.Fatal(err)
+	}
+	if r.macA, err = r.client.Finish(r.u2, []byte("data A")); err != nil {
+		t.Fatal(err)
+	}
+	return &r
+}
+
+func TestExchange(t *testing.T) {
+	for _, p := range allParams(t) {
+		r := start(t, p, []byte("123456"), []byte("123456"))
+		macB, err := r.server.Confirm(r.macA, []byte("data A"), []byte("data B"))
+		if err != nil {
+			t.Fatal(p.Curve.Name, err)
+		}
+		if err = r.client.Verify(macB, []byte("data B")); err != nil {
+			t.Fatal(p.Curve.Name, err)
+		}
+		keyA, err := r.client.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyB, err := r.server.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keyA) != KeySize || bytes.Compare(keyA, keyB) != 0 {
+			t.Fatal(p.Curve.Name)
+		}
+		r.client.Destroy()
+		r.server.Destroy()
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	p := allParams(t)[1]
+	r := start(t, p, []byte("123457"), []byte("123456"))
+	if _, err := r.server.Confirm(r.macA, []byte("data A"), nil); err != ErrAuthFailed {
+		t.FailNow()
+	}
+	if _, err := r.server.Key(); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestDataAuthenticated(t *testing.T) {
+	p := allParams(t)[0]
+	r := start(t, p, []byte("123456"), []byte("123456"))
+	if _, err := r.server.Confirm(r.macA, []byte("data a"), nil); err != ErrAuthFailed {
+		t.FailNow()
+	}
+	r = start(t, p, []byte("123456"), []byte("123456"))
+	macB, err := r.server.Confirm(r.macA, []byte("data A"), []byte("data B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.client.Verify(macB, []byte("data b")); err != ErrAuthFailed {
+		t.FailNow()
+	}
+	if _, err = r.client.Key(); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestInvalidPoints(t *testing.T) {
+	p := allParams(t)[0]
+	salt := []byte("salt")
+	verifier, err := NewVerifier(p, []byte("password"), salt, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(rand.Reader, p, verifier, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := Point{verifier.Point.X, new(big.Int).Add(verifier.Point.Y, big.NewInt(1))}
+	if _, err = server.Exchange(p.encode(offCurve)); err != ErrInvalidPeer {
+		t.FailNow()
+	}
+	// u1 + Q_PW is the point at infinity
+	server, err = NewServer(rand.Reader, p, verifier, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = server.Exchange(p.encode(p.neg(verifier.Point))); err != ErrInvalidPeer {
+		t.FailNow()
+	}
+	if _, err = server.Exchange(p.encode(p.base())); err != ErrState {
+		t.FailNow()
+	}
+}
+
+func TestState(t *testing.T) {
+	p := allParams(t)[0]
+	client, err := NewClient(rand.Reader, p, []byte("password"), []byte("salt"), 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Finish(p.encode(p.base()), nil); err != ErrState {
+		t.FailNow()
+	}
+	if err = client.Verify(nil, nil); err != ErrState {
+		t.FailNow()
+	}
+	if _, err = client.Key(); err != ErrState {
+		t.FailNow()
+	}
+	if _, err = client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Start(); err != ErrState {
+		t.FailNow()
+	}
+	if _, err = NewClient(rand.Reader, p, []byte("password"), []byte("salt"), 4, nil, nil); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestPointArithmetic(t *testing.T) {
+	for _, p := range allParams(t) {
+		a, _ := p.scalar(rand.Reader)
+		b, _ := p.scalar(rand.Reader)
+		ap, _ := p.mul(a, p.base())
+		bp, _ := p.mul(b, p.base())
+		abp, _ := p.mul(new(big.Int).Add(a, b), p.base())
+		sum := p.add(ap, bp)
+		if sum.X.Cmp(abp.X) != 0 || sum.Y.Cmp(abp.Y) != 0 {
+			t.Fatal(p.Curve.Name, "add")
+		}
+		dbl := p.add(ap, ap)
+		a2p, _ := p.mul(new(big.Int).Lsh(a, 1), p.base())
+		if dbl.X.Cmp(a2p.X) != 0 || dbl.Y.Cmp(a2p.Y) != 0 {
+			t.Fatal(p.Curve.Name, "double")
+		}
+		if !p.add(ap, p.neg(ap)).infinity() {
+			t.Fatal(p.Curve.Name, "neg")
+		}
+		if p.lowOrder(ap) {
+			t.Fatal(p.Curve.Name, "low order")
+		}
+	}
+}
+
+// RFC 8133 appendix B examples: password "123456", the salt below and
+// index 1.
+type rfcVector struct {
+	params *Params
+	qpwX   string
+	qpwY   string
+	alpha  string
+	alphaX string
+	alphaY string
+	beta   string
+	betaX  string
+	betaY  string
+}
+
+var rfcSalt = []byte{
+	0x29, 0x23, 0xBE, 0x84, 0xE1, 0x6C, 0xD6, 0xAE,
+	0x52, 0x90, 0x49, 0xF1, 0xF1, 0xBB, 0xE9, 0xEB,
+}
+
+var rfcVectors = []rfcVector{
+	{
+		params: ParamsIdGostR34102001CryptoProAParamSet(),
+		qpwX:   "59495655D1E7C7424C622485F575CCF121F3122D274101E8AB734CC9C9A9B45E",
+		qpwY:   "48D1C311D33C9B701F3B03618562A4A07A044E3AF31E3999E67B487778B53C62",
+		alpha:  "1F2538097D5A031FA68BBB43C84D12B3DE47B7061C0D5E24993E0C873CDBA6B3",
+		alphaX: "BBC77CF42DC1E62D06227935379B4AA4D14FEA4F565DDF4CB4FA4D31579F9676",
+		alphaY: "8E16604A4AFDF28246684D4996274781F6CB80ABBBA1414C1513EC988509DABF",
+		beta:   "DC497D9EF6324912FD367840EE509A2032AEDB1C0A890D133B45F596FCCBD45D",
+		betaX:  "6097341C1BE388E83E7CA2DF47FAB86E2271FD942E5B7B2EB2409E49F742BC29",
+		betaY:  "C81AA48BDB4CA6FA0EF18B9788AE25FE30857AA681B3942217F9FED151BAB7D0",
+	},
+	{
+		params: ParamsIdGostR34102001CryptoProBParamSet(),
+		qpwX:   "6DC2AE26BC691FCA5A73D9C452790D15E34BA5404D92955B914C8D2662ABB985",
+		qpwY:   "3B02AAA9DD65AE30C335CED12F3154BBAC059F66B088306747453EDF6E5DB077",
+		alpha:  "499D72B90299CAB0DA1F8BE19D9122F622A13B32B730C46BD0664044F2144FAD",
+		alphaX: "61D6F916DB717222D74877F179F7EBEF7CD4D24D8C1F523C048E34A1DF30F8DD",
+		alphaY: "3EC48863049CFCFE662904082E78503F4973A4E105E2F1B18C69A5E7FB209000",
+		beta:   "0F69FF614957EF83668EDC2D7ED614BE76F7B253DB23C5CC9C52BF7DF8F4669D",
+		betaX:  "33BC6F7E9C0BA10CFB2B72546C327171295508EA97F8C8BA9F890F2478AB4D6C",
+		betaY:  "75D57B396C396F492F057E9222CCC686437A2AAD464E452EF426FC8EEED1A4A6",
+	},
+	{
+		params: ParamsIdGostR34102001CryptoProCParamSet(),
+		qpwX:   "945821DAF91E158B839939630655A3B21FF3E146D27041E86C05650EB3B46B59",
+		qpwY:   "3A0C2816AC97421FA0E879605F17F0C9C3EB734CFF196937F6284438D70BDC48",
+		alpha:  "3A54AC3F19AD9D0B1EAC8ACDCEA70E581F1DAC33D13FEAFD81E762378639C1A8",
+		alphaX: "96B7F09C94D297C257A7DA48364C0076E59E48D221CBA604AE111CA3933B446A",
+		alphaY: "54E4953D86B77ECCEB578500931E822300F7E091F79592CA202A020D762C34A6",
+		beta:   "448781782BF7C0E52A1DD9E6758FD3482D90D3CFCCF42232CF357E59A4D49FD4",
+		betaX:  "4B9C0AB55A938121F282F48A2CC4396EB16E7E0068B495B0C1DD4667786A3EB7",
+		betaY:  "223460AA8E09383E9DF9844C5A0F2766484738E5B30128A171B69A77D9509B96",
+	},
+	{
+		params: ParamsIdtc26gost34102012512paramSetA(),
+		qpwX: "0C0AB53D0E0A9C607CAD758F558915A0A7DC5DC87B45E9A58FDDF30EC3385960" +
+			"283E030CD322D9E46B070637785FD49D2CD711F46807A24C40AF9A42C8E2D740",
+		qpwY: "DF93A8012B86D3A3D4F8A4D487DA15FC739EB31B20B3B0E8C8C032AAF8072C63" +
+			"37CF7D5B404719E5B4407C41D9A3216A08CA69C271484E9ED72B8AAA52E28B8B",
+		alpha: "3CE54325DB52FE798824AEAD11BB16FA766857D04A4AF7D468672F16D90E7396" +
+			"046A46F815693E85B1CE5464DA9270181F82333B0715057BBE8D61D400505F0E",
+		alphaX: "B93093EB0FCC463239B7DF276E09E592FCFC9B635504EA4531655D76A0A3078E" +
+			"2B4E51CFE2FA400CC5DE9FBE369DB204B3E8ED7EDD85EE5CCA654C1AED70E396",
+		alphaY: "809770B8D910EA30BD2FA89736E91DC31815D2D9B31128077EEDC371E9F69466" +
+			"F497DC64DD5B1FADC587F860EE256109138C4A9CD96B628E65A8F590520FC882",
+		beta: "B5C286A79AA8E97EC0E19BC1959A1D15F12F8C97870BA9D68CC12811A56A3BB1" +
+			"1440610825796A49D468CDC9C2D02D76598A27973D5960C5F50BCE28D8D345F4",
+		betaX: "238B38644E440452A99FA6B93D9FD7DA0CB83C32D3C1E3CFE5DF5C3EB0F9DB91" +
+			"E588DAEDC849EA2FB867AE855A21B4077353C0794716A6480995113D8C20C7AF",
+		betaY: "B2273D5734C1897F8D15A7008B862938C8C74CA7E877423D95243EB7EBD02FD2" +
+			"C456CF9FC956F078A59AA86F19DD1075E5167E4ED35208718EA93161C530ED14",
+	},
+	{
+		params: ParamsIdtc26gost34102012512paramSetB(),
+		qpwX: "7D03E65B8050D1E12CBB601A17B9273B0E728F5021CD47C8A4DD822E4627BA5F" +
+			"9C696286A2CDDA9A065509866B4DEDEDC4A118409604AD549F87A60AFA621161",
+		qpwY: "16037DAD45421EC50B00D50BDC6AC3B85348BC1D3A2F85DB27C3373580FEF87C" +
+			"2C743B7ED30F22BE22958044E716F93A61CA3213A361A2797A16A3AE62957377",
+		alpha: "715E893FA639BF341296E0623E6D29DADF26B163C278767A7982A989462A3863" +
+			"FE12AEF8BD403D59C4DC4720570D4163DB0805C7C10C4E818F9CB785B04B9997",
+		alphaX: "10C479EA1C04D3C2C02B0576A9C42D96226FF033C1191436777F66916030D87D" +
+			"02FB93738ED7669D07619FFCE7C1F3C4DB5E5DF49E2186D6FA1E2EB5767602B9",
+		alphaY: "039F6044191404E707F26D59D979136A831CCE43E1C5F0600D1DDF8F39D0CA3D" +
+			"52FBD943BF04DDCED1AA2CE8F5EBD7487ACDEF239C07D015084D796784F35436",
+		beta: "30FA8C2B4146C2DBBE82BED04D7378877E8C06753BD0A0FF71EBF2BEFE8DA8F3" +
+			"DC0836468E2CE7C5C961281B6505140F8407413F03C2CB1D201EA1286CE30E6D",
+		betaX: "34C0149E7BB91AE377B02573FCC48AF7BFB7B16DEB8F9CE870F384688E3241A3" +
+			"A868588CC0EF4364CCA67D17E3260CD82485C202ADC76F895D5DF673B1788E67",
+		betaY: "608E944929BD643569ED5189DB871453F13333A1EAF82B2FE1BE8100E775F13D" +
+			"D9925BD317B63BFAF05024D4A738852332B64501195C1B2EF789E34F23DDAFC5",
+	},
+	{
+		params: ParamsIdtc26gost34102012256paramSetA(),
+		qpwX:   "DBF99827078956812FA48C6E695DF589DEF1D18A2D4D35A96D75BF6854237629",
+		qpwY:   "9FDDD48BFBC57BEE1DA0CFF282884F284D471B388893C48F5ECB02FC18D67589",
+		alpha:  "147B72F6684FB8FD1B418A899F7DBECAF5FCE60B13685BAA95328654A7F0707F",
+		alphaX: "33FBAC14EAE538275A769417829C431BD9FA622B6F02427EF55BD60EE6BC2888",
+		alphaY: "22F2EBCF960A82E6CDB4042D3DDDA511B2FBA925383C2273D952EA2D406EAE46",
+		beta:   "30D5CFADAA0E31B405E6734C03EC4C5DF0F02F4BA25C9A3B320EE6453567B4CB",
+		betaX:  "2B2D89FAB735433970564F2F28CFA1B57D640CB902BC6334A538F44155022CB2",
+		betaY:  "10EF6A82EEF1E70F942AA81D6B4CE5DEC0DDB9447512962874870E6F2849A96F",
+	},
+	{
+		params: ParamsIdtc26gost34102012512paramSetC(),
+		qpwX: "0185AE6271A81BB7F236A955F7CAA26FB63849813C0287D96C83A15AE6B6A864" +
+			"67AB13B6D88CE8CD7DC2E5B97FF5F28FAC2C108F2A3CF3DB5515C9E6D7D210E8",
+		qpwY: "ED0220F92EF771A71C64ECC77986DB7C03D37B3E2AB3E83F32CE5E074A762EC0" +
+			"8253C9E2102B87532661275C4B1D16D2789CDABC58ACFDF7318DE70AB64F09B8",
+		alpha: "332F930421D14CFE260042159F18E49FD5A54167E94108AD80B1DE60B13DE799" +
+			"9A34D611E63F3F870E5110247DF8EC7466E648ACF385E52CCB889ABF491EDFF0",
+		alphaX: "561655966D52952E805574F4281F1ED3A2D498932B00CBA9DECB42837F09835B" +
+			"FFBFE2D84D6B6B242FE7B57F92E1A6F2413E12DDD6383E4437E13D72693469AD",
+		alphaY: "F6B18328B2715BD7F4178615273A36135BC0BF62F7D8BB9F080164AD36470AD0" +
+			"3660F51806C64C6691BADEF30F793720F8E3FEAED631D6A54A4C372DCBF80E82",
+		beta: "38481771E7D054F96212686B613881880BD8A6C89DDBC656178F014D2C093432" +
+			"A033EE10415F13A160D44C2AD61E6E2E05A7F7EC286BCEA3EA4D4D53F8634FA2",
+		betaX: "B7C5818687083433BC1AFF61CB5CA79E38232025E0C1F123B8651E62173CE687" +
+			"3F3E6FFE7281C2E45F4F524F66B0C263616ED08FD210AC4355CA3292B51D71C3",
+		betaY: "497F14205DBDC89BDDAF50520ED3B1429AD30777310186BE5E68070F016A44E0" +
+			"C766DB08E8AC23FBDFDE6D675AA4DF591EB18BA0D348DF7AA40973A2F1DCFA55",
+	},
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func rfcPoint(x, y string) Point {
+	return Point{
+		new(big.Int).SetBytes(unhex(x)),
+		new(big.Int).SetBytes(unhex(y)),
+	}
+}
+
+func TestPasswordPointVectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		verifier, err := NewVerifier(v.params, []byte("123456"), rfcSalt, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qpw := rfcPoint(v.qpwX, v.qpwY)
+		if verifier.Point.X.Cmp(qpw.X) != 0 || verifier.Point.Y.Cmp(qpw.Y) != 0 {
+			t.Fatal(v.params.Curve.Name)
+		}
+	}
+}
+
+// Exchange with RFC's alpha and beta. u1 and u2 must be formed from
+// RFC's alpha*P, beta*P and Q_PW. Keys and MACs are checked against
+// their definitions computed directly with gost3410 and HMAC.
+func TestRFCExchange(t *testing.T) {
+	id := []byte{0x00, 0x00, 0x00, 0x00}
+	dataA := []byte("data A")
+	dataB := []byte("data B")
+	for _, v := range rfcVectors {
+		p := v.params
+		qpw := rfcPoint(v.qpwX, v.qpwY)
+		alphaP := rfcPoint(v.alphaX, v.alphaY)
+		betaP := rfcPoint(v.betaX, v.betaY)
+		client, err := NewClient(bytes.NewReader(unhex(v.alpha)), p, []byte("123456"), rfcSalt, 1, id, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier := &Verifier{1, rfcSalt, qpw}
+		server, err := NewServer(bytes.NewReader(unhex(v.beta)), p, verifier, id, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u1, err := client.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(u1, p.encode(p.add(alphaP, p.neg(qpw)))) != 0 {
+			t.Fatal(p.Curve.Name, "u1")
+		}
+		u2, err := server.Exchange(u1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(u2, p.encode(p.add(betaP, qpw))) != 0 {
+			t.Fatal(p.Curve.Name, "u2")
+		}
+		macA, err := client.Finish(u2, dataA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		macB, err := server.Confirm(macA, dataA, dataB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = client.Verify(macB, dataB); err != nil {
+			t.Fatal(err)
+		}
+
+		// src = (m/q * alpha * beta mod q) * P
+		e := new(big.Int).Mul(new(big.Int).SetBytes(unhex(v.alpha)), new(big.Int).SetBytes(unhex(v.beta)))
+		e.Mul(e, big.NewInt(p.cofactor()))
+		e.Mod(e, p.Curve.Q)
+		x, y, err := p.Curve.Exp(e, p.Curve.X, p.Curve.Y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := gost3410.PublicKey{C: p.Curve, Mode: p.Mode, X: x, Y: y}
+		h := gost34112012256.New()
+		h.Write(src.Raw())
+		k := h.Sum(nil)
+		keyA, _ := client.Key()
+		keyB, _ := server.Key()
+		if bytes.Compare(keyA, k) != 0 || bytes.Compare(keyB, k) != 0 {
+			t.Fatal(p.Curve.Name, "key")
+		}
+		m := gost34112012256.NewHMAC(k)
+		m.Write([]byte{0x01})
+		m.Write(id)
+		m.Write([]byte{0x01})
+		m.Write(rfcSalt)
+		m.Write(u1)
+		m.Write(u2)
+		m.Write(dataA)
+		if bytes.Compare(macA, m.Sum(nil)) != 0 {
+			t.Fatal(p.Curve.Name, "MAC_A")
+		}
+		m = gost34112012256.NewHMAC(k)
+		m.Write([]byte{0x02})
+		m.Write(id)
+		m.Write([]byte{0x01})
+		m.Write(rfcSalt)
+		m.Write(u1)
+		m.Write(u2)
+		m.Write(macA)
+		m.Write(dataB)
+		if bytes.Compare(macB, m.Sum(nil)) != 0 {
+			t.Fatal(p.Curve.Name, "MAC_B")
+		}
+	}
+}
+
+func TestExchangeParamSets(t *testing.T) {
+	for _, p := range []*Params{
+		ParamsIdGostR34102001CryptoProAParamSet(),
+		ParamsIdGostR34102001CryptoProBParamSet(),
+		ParamsIdGostR34102001CryptoProCParamSet(),
+		ParamsIdtc26gost34102012256paramSetA(),
+		ParamsIdtc26gost34102012512paramSetA(),
+		ParamsIdtc26gost34102012512paramSetB(),
+		ParamsIdtc26gost34102012512paramSetC(),
+	} {
+		p.Iterations = 10
+		r := start(t, p, []byte("123456"), []byte("123456"))
+		macB, err := r.server.Confirm(r.macA, []byte("data A"), nil)
+		if err != nil {
+			t.Fatal(p.Curve.Name, err)
+		}
+		if err = r.client.Verify(macB, nil); err != nil {
+			t.Fatal(p.Curve.Name, err)
+		}
+	}
+}
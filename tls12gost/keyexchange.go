@@ -0,0 +1,324 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls12gost
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"io"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+const (
+	PreMasterSecretSize = 32
+	// Size of the KEG's output: K_EXP_MAC || K_EXP_ENC
+	ExportKeySize = 2 * KeySize
+)
+
+// KEG export keys generation algorithm. h is 32 bytes long hash (for
+// TLS it is Streebog-256 of client and server randoms). Result is
+// K_EXP_MAC || K_EXP_ENC.
+func KEG(prv *gost3410.PrivateKey, pub *gost3410.PublicKey, h []byte) ([]byte, error) {
+	return prv.KEG(pub, h)
+}
+
+// KExp15 key export algorithm: CTR(K_EXP_ENC, IV, key ||
+// OMAC(K_EXP_MAC, IV || key)). kExp is K_EXP_MAC || K_EXP_ENC, IV is
+// half of the cipher's blocksize.
+func KExp15(suite CipherSuite, key, kExp, iv []byte) ([]byte, error) {
+	if len(kExp) != ExportKeySize {
+		return nil, errors.New("invalid export key size")
+	}
+	return gost3413.KExp15(suite.newCipher, key, kExp[:KeySize], kExp[KeySize:], iv)
+}
+
+// KImp15 key import algorithm, reverse of KExp15.
+func KImp15(suite CipherSuite, exported, kExp, iv []byte) ([]byte, error) {
+	if len(kExp) != ExportKeySize {
+		return nil, errors.New("invalid export key size")
+	}
+	return gost3413.KImp15(suite.newCipher, exported, kExp[:KeySize], kExp[KeySize:], iv)
+}
+
+// ClientKeyExchange's exchange_keys structure:
+//
+//	GostKeyTransport ::= SEQUENCE {
+//	    keyExp OCTET STRING,
+//	    ephemeralPublicKey SubjectPublicKeyInfo,
+//	    ukm OCTET STRING OPTIONAL }
+type GostKeyTransport struct {
+	KeyExp             []byte
+	EphemeralPublicKey gostasn1.SubjectPublicKeyInfo
+	UKM                []byte `asn1:"optional"`
+}
+
+// H = Streebog-256(r_C || r_S)
+func randomsHash(clientRandom, serverRandom []byte) []byte {
+	h := gost34112012256.New()
+	h.Write(clientRandom)
+	h.Write(serverRandom)
+	return h.Sum(nil)
+}
+
+// Client side of the key exchange: generate the premaster secret and
+// ephemeral key on the server's curve, export premaster secret with
+// KExp15 and return it with DER-encoded GostKeyTransport structure
+// (ClientKeyExchange's exchange_keys).
+func ClientKeyExchange(
+	suite CipherSuite,
+	serverPub *gost3410.PublicKey,
+	clientRandom, serverRandom []byte,
+	rand io.Reader,
+) (preMasterSecret, exchangeKeys []byte, err error) {
+	preMasterSecret = make([]byte, PreMasterSecretSize)
+	if _, err = io.ReadFull(rand, preMasterSecret); err != nil {
+		return nil, nil, err
+	}
+	eph, err := gost3410.GenPrivateKey(serverPub.C, serverPub.Mode, rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer eph.Destroy()
+	ephPub, err := eph.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	h := randomsHash(clientRandom, serverRandom)
+	kExp, err := KEG(eph, serverPub, h)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer wipe.Bytes(kExp)
+	var kt GostKeyTransport
+	kt.KeyExp, err = KExp15(suite, preMasterSecret, kExp, h[24:24+suite.IVSize()])
+	if err != nil {
+		return nil, nil, err
+	}
+	if kt.EphemeralPublicKey, err = gostasn1.MarshalPublicKey(ephPub); err != nil {
+		return nil, nil, err
+	}
+	exchangeKeys, err = asn1.Marshal(kt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return preMasterSecret, exchangeKeys, nil
+}
+
+// Server side of the key exchange: parse GostKeyTransport and import
+// premaster secret from it.
+func ServerKeyExchange(
+	suite CipherSuite,
+	prv *gost3410.PrivateKey,
+	clientRandom, serverRandom []byte,
+	exchangeKeys []byte,
+) ([]byte, error) {
+	var kt GostKeyTransport
+	rest, err := asn1.Unmarshal(exchangeKeys, &kt)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after GostKeyTransport")
+	}
+	ephPub, err := gostasn1.ParsePublicKey(&kt.EphemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if ephPub.C.Name != prv.C.Name || ephPub.Mode != prv.Mode {
+		return nil, errors.New("ephemeral key on different curve")
+	}
+	h := randomsHash(clientRandom, serverRandom)
+	kExp, err := KEG(prv, ephPub, h)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(kExp)
+	pms, err := KImp15(suite, kt.KeyExp, kExp, h[24:24+suite.IVSize()])
+	if err != nil {
+		return nil, err
+	}
+	if len(pms) != PreMasterSecretSize {
+		wipe.Bytes(pms)
+		return nil, errors.New("invalid premaster secret size")
+	}
+	return pms, nil
+}
+
+// GOST 28147-89 key transport of TLS_GOSTR341112_256_WITH_28147_CNT_IMIT
+// cipher suite. ClientKeyExchange's exchange_keys structure:
+//
+//	TLSGostKeyTransportBlob ::= SEQUENCE {
+//	    keyBlob GostR3410-KeyTransport,
+//	    proxyKeyBlobs SEQUENCE OF TLSProxyKeyTransportBlob OPTIONAL }
+//	GostR3410-KeyTransport ::= SEQUENCE {
+//	    sessionEncryptedKey Gost28147-89-EncryptedKey,
+//	    transportParameters [0] IMPLICIT GostR3410-TransportParameters OPTIONAL }
+//	Gost28147-89-EncryptedKey ::= SEQUENCE {
+//	    encryptedKey OCTET STRING (SIZE (32)),
+//	    maskKey [0] IMPLICIT OCTET STRING OPTIONAL,
+//	    macKey OCTET STRING (SIZE (1..4)) }
+//	GostR3410-TransportParameters ::= SEQUENCE {
+//	    encryptionParamSet OBJECT IDENTIFIER,
+//	    ephemeralPublicKey [0] IMPLICIT SubjectPublicKeyInfo OPTIONAL,
+//	    ukm OCTET STRING }
+type TLSGostKeyTransportBlob struct {
+	KeyBlob       GostR3410KeyTransport
+	ProxyKeyBlobs []asn1.RawValue `asn1:"optional"`
+}
+
+type GostR3410KeyTransport struct {
+	SessionEncryptedKey Gost28147EncryptedKey
+	TransportParameters GostR3410TransportParameters `asn1:"optional,tag:0"`
+}
+
+type Gost28147EncryptedKey struct {
+	EncryptedKey []byte
+	MaskKey      []byte `asn1:"optional,tag:0"`
+	MACKey       []byte
+}
+
+type GostR3410TransportParameters struct {
+	EncryptionParamSet asn1.ObjectIdentifier
+	EphemeralPublicKey gostasn1.SubjectPublicKeyInfo `asn1:"optional,tag:0"`
+	UKM                []byte
+}
+
+// S-box of the key transport: id-tc26-gost-28147-param-Z.
+var keyTransport28147Sbox = &gost28147.SboxIdtc26gost28147paramZ
+
+// Premaster secret wrapped with CryptoPro key wrap (RFC 4357 6.3) on
+// VKO_256 key with UKM = H[1..8], which is the same as KExp28147 on
+// KEG_28147's key. Result is UKM || encrypted key || MAC.
+func wrap28147(
+	prv *gost3410.PrivateKey,
+	pub *gost3410.PublicKey,
+	h, pms []byte,
+) ([]byte, error) {
+	ukm := h[:gost28147.UKMSize]
+	kek, err := prv.KEK2012256(pub, gost3410.NewUKM(ukm))
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(kek)
+	return gost28147.WrapCryptoPro(kek, ukm, pms, keyTransport28147Sbox)
+}
+
+// Client side of the GOST 28147-89 key exchange: generate the premaster
+// secret and ephemeral key on the server's curve, wrap premaster
+// secret and return it with DER-encoded TLSGostKeyTransportBlob
+// structure.
+func ClientKeyExchange28147(
+	serverPub *gost3410.PublicKey,
+	clientRandom, serverRandom []byte,
+	rand io.Reader,
+) (preMasterSecret, exchangeKeys []byte, err error) {
+	preMasterSecret = make([]byte, PreMasterSecretSize)
+	if _, err = io.ReadFull(rand, preMasterSecret); err != nil {
+		return nil, nil, err
+	}
+	eph, err := gost3410.GenPrivateKey(serverPub.C, serverPub.Mode, rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer eph.Destroy()
+	ephPub, err := eph.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	h := randomsHash(clientRandom, serverRandom)
+	wrapped, err := wrap28147(eph, serverPub, h, preMasterSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	sboxOID, err := gostasn1.SboxOID(keyTransport28147Sbox)
+	if err != nil {
+		return nil, nil, err
+	}
+	var blob TLSGostKeyTransportBlob
+	kt := &blob.KeyBlob
+	kt.SessionEncryptedKey.EncryptedKey = wrapped[gost28147.UKMSize : gost28147.UKMSize+gost28147.KeySize]
+	kt.SessionEncryptedKey.MACKey = wrapped[gost28147.UKMSize+gost28147.KeySize:]
+	kt.TransportParameters.EncryptionParamSet = sboxOID
+	kt.TransportParameters.UKM = wrapped[:gost28147.UKMSize]
+	if kt.TransportParameters.EphemeralPublicKey, err = gostasn1.MarshalPublicKey(ephPub); err != nil {
+		return nil, nil, err
+	}
+	exchangeKeys, err = asn1.Marshal(blob)
+	if err != nil {
+		return nil, nil, err
+	}
+	return preMasterSecret, exchangeKeys, nil
+}
+
+// Server side of the GOST 28147-89 key exchange: parse
+// TLSGostKeyTransportBlob and unwrap premaster secret from it.
+func ServerKeyExchange28147(
+	prv *gost3410.PrivateKey,
+	clientRandom, serverRandom []byte,
+	exchangeKeys []byte,
+) ([]byte, error) {
+	var blob TLSGostKeyTransportBlob
+	rest, err := asn1.Unmarshal(exchangeKeys, &blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after TLSGostKeyTransportBlob")
+	}
+	kt := &blob.KeyBlob
+	params := &kt.TransportParameters
+	sbox, err := gostasn1.SboxByOID(params.EncryptionParamSet)
+	if err != nil {
+		return nil, err
+	}
+	if sbox != keyTransport28147Sbox {
+		return nil, errors.New("unexpected key transport S-box")
+	}
+	if len(params.EphemeralPublicKey.SubjectPublicKey.Bytes) == 0 {
+		return nil, errors.New("no ephemeral public key")
+	}
+	ephPub, err := gostasn1.ParsePublicKey(&params.EphemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if ephPub.C.Name != prv.C.Name || ephPub.Mode != prv.Mode {
+		return nil, errors.New("ephemeral key on different curve")
+	}
+	h := randomsHash(clientRandom, serverRandom)
+	ukm := h[:gost28147.UKMSize]
+	if !bytes.Equal(params.UKM, ukm) {
+		return nil, errors.New("UKM differs from randoms hash")
+	}
+	ek := &kt.SessionEncryptedKey
+	if len(ek.EncryptedKey) != gost28147.KeySize || len(ek.MACKey) != gost28147.WrapMACSize {
+		return nil, errors.New("invalid encrypted key size")
+	}
+	kek, err := prv.KEK2012256(ephPub, gost3410.NewUKM(ukm))
+	if err != nil {
+		return nil, err
+	}
+	defer wipe.Bytes(kek)
+	wrapped := append(append(append([]byte{}, ukm...), ek.EncryptedKey...), ek.MACKey...)
+	return gost28147.UnwrapCryptoPro(kek, wrapped, keyTransport28147Sbox)
+}
@@ -0,0 +1,278 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls12gost
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+func genKey(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode) (*gost3410.PrivateKey, *gost3410.PublicKey) {
+	prv, err := gost3410.GenPrivateKey(curve, mode, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prv, pub
+}
+
+func TestKEGSymmetric(t *testing.T) {
+	h := make([]byte, 32)
+	rand.Read(h)
+	for _, c := range []struct {
+		curve *gost3410.Curve
+		mode  gost3410.Mode
+	}{
+		{gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001},
+		{gost3410.CurveIdGostR34102001CryptoProXchAParamSet(), gost3410.Mode2001},
+		{gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012},
+	} {
+		prvA, pubA := genKey(t, c.curve, c.mode)
+		prvB, pubB := genKey(t, c.curve, c.mode)
+		kA, err := KEG(prvA, pubB, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kB, err := KEG(prvB, pubA, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(kA) != ExportKeySize || bytes.Compare(kA, kB) != 0 {
+			t.Fatal(c.curve.Name)
+		}
+	}
+}
+
+// 256-bit KEG is VKO followed by KDF_TREE with L=512 and R=1.
+func TestKEG256(t *testing.T) {
+	curve := gost3410.CurveIdtc26gost34102012256paramSetA()
+	prvA, _ := genKey(t, curve, gost3410.Mode2001)
+	_, pubB := genKey(t, curve, gost3410.Mode2001)
+	h := make([]byte, 32)
+	rand.Read(h)
+	kek, _ := prvA.KEK2012256(pubB, gost3410.NewUKM(h[:16]))
+	mac := hmac.New(gost34112012256.New, kek)
+	mac.Write([]byte{0x01})
+	mac.Write([]byte("kdf tree"))
+	mac.Write([]byte{0x00})
+	mac.Write(h[16:24])
+	mac.Write([]byte{0x02, 0x00})
+	k, _ := KEG(prvA, pubB, h)
+	if bytes.Compare(k[:32], mac.Sum(nil)) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestKExp15(t *testing.T) {
+	for _, suite := range suites {
+		key := make([]byte, 32)
+		kExp := make([]byte, ExportKeySize)
+		iv := make([]byte, suite.IVSize())
+		rand.Read(key)
+		rand.Read(kExp)
+		rand.Read(iv)
+		exported, err := KExp15(suite, key, kExp, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(exported) != len(key)+suite.MACSize() {
+			t.FailNow()
+		}
+
+		m, _ := gost3413.NewOMAC(suite.newCipher(kExp[:KeySize]), suite.MACSize())
+		m.Write(append(append([]byte{}, iv...), key...))
+		expected := m.Sum(append([]byte{}, key...))
+		ctr := make([]byte, suite.blockSize())
+		copy(ctr, iv)
+		cipher.NewCTR(suite.newCipher(kExp[KeySize:]), ctr).XORKeyStream(expected, expected)
+		if bytes.Compare(exported, expected) != 0 {
+			t.Fatal("differs from CTR(K||OMAC(IV||K))")
+		}
+
+		imported, err := KImp15(suite, exported, kExp, iv)
+		if err != nil || bytes.Compare(imported, key) != 0 {
+			t.Fatal("import failed")
+		}
+		for i := 0; i < len(exported); i++ {
+			exported[i] ^= 0x80
+			if _, err = KImp15(suite, exported, kExp, iv); err == nil {
+				t.Fatal("tampered key imported", i)
+			}
+			exported[i] ^= 0x80
+		}
+	}
+}
+
+func TestKeyTransport(t *testing.T) {
+	cr := make([]byte, 32)
+	sr := make([]byte, 32)
+	rand.Read(cr)
+	rand.Read(sr)
+	for _, c := range []struct {
+		curve *gost3410.Curve
+		mode  gost3410.Mode
+	}{
+		{gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001},
+		{gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001},
+		{gost3410.CurveIdtc26gost341012512paramSetB(), gost3410.Mode2012},
+	} {
+		for _, suite := range suites {
+			prv, pub := genKey(t, c.curve, c.mode)
+			pms, blob, err := ClientKeyExchange(suite, pub, cr, sr, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kt GostKeyTransport
+			if _, err = asn1.Unmarshal(blob, &kt); err != nil {
+				t.Fatal(err)
+			}
+			if len(kt.KeyExp) != PreMasterSecretSize+suite.MACSize() {
+				t.FailNow()
+			}
+			got, err := ServerKeyExchange(suite, prv, cr, sr, blob)
+			if err != nil {
+				t.Fatal(c.curve.Name, suite, err)
+			}
+			if bytes.Compare(got, pms) != 0 {
+				t.Fatal(c.curve.Name, suite)
+			}
+			if _, err = ServerKeyExchange(suite, prv, sr, cr, blob); err == nil {
+				t.Fatal("swapped randoms accepted")
+			}
+			ephPub, err := gostasn1.ParsePublicKey(&kt.EphemeralPublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ephPub.Y.Add(ephPub.Y, big.NewInt(1))
+			if kt.EphemeralPublicKey, err = gostasn1.MarshalPublicKey(ephPub); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = gostasn1.ParsePublicKey(&kt.EphemeralPublicKey); err == nil {
+				t.Fatal("point off the curve parsed")
+			}
+			if blob, err = asn1.Marshal(kt); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = ServerKeyExchange(suite, prv, cr, sr, blob); err == nil {
+				t.Fatal("ephemeral key off the curve accepted")
+			}
+		}
+	}
+}
+
+// R 1323565.1.017-2018 appendix A key export example with Magma.
+func TestKExp15Vector(t *testing.T) {
+	key, _ := hex.DecodeString("8899AABBCCDDEEFF0011223344556677FEDCBA98765432100123456789ABCDEF")
+	kExpMAC, _ := hex.DecodeString("08090A0B0C0D0E0F0001020304050607101112131415161718191A1B1C1D1E1F")
+	kExpEnc, _ := hex.DecodeString("202122232425262728292A2B2C2D2E2F38393A3B3C3D3E3F3031323334353637")
+	iv, _ := hex.DecodeString("67BED654")
+	expected, _ := hex.DecodeString(
+		"CFD5A12D5B81B6E1E99C916D07900C6AC12703FB3ABDED55567BF3742C899C75" +
+			"5DAFE7B42E3A8BD9",
+	)
+	kExp := append(append([]byte{}, kExpMAC...), kExpEnc...)
+	exported, err := KExp15(TLSGOSTR341112256WithMagmaCTROMAC, key, kExp, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(exported, expected) != 0 {
+		t.FailNow()
+	}
+	imported, err := KImp15(TLSGOSTR341112256WithMagmaCTROMAC, exported, kExp, iv)
+	if err != nil || bytes.Compare(imported, key) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestKeyTransport28147(t *testing.T) {
+	cr := make([]byte, 32)
+	sr := make([]byte, 32)
+	rand.Read(cr)
+	rand.Read(sr)
+	for _, c := range []struct {
+		curve *gost3410.Curve
+		mode  gost3410.Mode
+	}{
+		{gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001},
+		{gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001},
+		{gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012},
+	} {
+		prv, pub := genKey(t, c.curve, c.mode)
+		pms, blob, err := ClientKeyExchange28147(pub, cr, sr, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ServerKeyExchange28147(prv, cr, sr, blob)
+		if err != nil {
+			t.Fatal(c.curve.Name, err)
+		}
+		if bytes.Compare(got, pms) != 0 {
+			t.Fatal(c.curve.Name)
+		}
+
+		// encryptedKey is ECB of PMS on CryptoPro diversified VKO_256
+		// key with UKM = H[1..8]
+		var kt TLSGostKeyTransportBlob
+		if _, err = asn1.Unmarshal(blob, &kt); err != nil {
+			t.Fatal(err)
+		}
+		params := kt.KeyBlob.TransportParameters
+		h := randomsHash(cr, sr)
+		if bytes.Compare(params.UKM, h[:8]) != 0 {
+			t.FailNow()
+		}
+		ephPub, err := gostasn1.ParsePublicKey(&params.EphemeralPublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kek, _ := prv.KEK2012256(ephPub, gost3410.NewUKM(h[:8]))
+		kek, err = gost28147.DiversifyCryptoPro(kek, h[:8], &gost28147.SboxIdtc26gost28147paramZ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted := make([]byte, len(pms))
+		ecb := gost28147.NewCipher(kek, &gost28147.SboxIdtc26gost28147paramZ).NewECBEncrypter()
+		ecb.CryptBlocks(encrypted, pms)
+		if bytes.Compare(kt.KeyBlob.SessionEncryptedKey.EncryptedKey, encrypted) != 0 {
+			t.FailNow()
+		}
+
+		if _, err = ServerKeyExchange28147(prv, sr, cr, blob); err == nil {
+			t.Fatal("swapped randoms accepted")
+		}
+		kt.KeyBlob.SessionEncryptedKey.EncryptedKey[0] ^= 0x01
+		tampered, _ := asn1.Marshal(kt)
+		if _, err = ServerKeyExchange28147(prv, cr, sr, tampered); err == nil {
+			t.Fatal("tampered key accepted")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls12gost
+
+import (
+	"github.com/ddulesov/gogost/gost34112012256"
+)
+
+const (
+	MasterSecretSize = 48
+	VerifyDataSize   = 32
+)
+
+// Extended master secret (RFC 7627), mandatory for GOST suites.
+// sessionHash is the Streebog-256 hash of the handshake messages up to
+// and including ClientKeyExchange.
+func MasterSecret(preMasterSecret, sessionHash []byte) []byte {
+	return gost34112012256.PRF(
+		preMasterSecret, []byte("extended master secret"), sessionHash,
+		MasterSecretSize,
+	)
+}
+
+type KeyBlock struct {
+	ClientMACKey []byte
+	ServerMACKey []byte
+	ClientKey    []byte
+	ServerKey    []byte
+	ClientIV     []byte
+	ServerIV     []byte
+}
+
+// Expand master secret to the keys of both directions.
+func NewKeyBlock(suite CipherSuite, masterSecret, clientRandom, serverRandom []byte) *KeyBlock {
+	ivSize := suite.IVSize()
+	seed := make([]byte, 0, len(serverRandom)+len(clientRandom))
+	seed = append(append(seed, serverRandom...), clientRandom...)
+	raw := gost34112012256.PRF(
+		masterSecret, []byte("key expansion"), seed, 4*KeySize+2*ivSize,
+	)
+	return &KeyBlock{
+		ClientMACKey: raw[:KeySize],
+		ServerMACKey: raw[KeySize : 2*KeySize],
+		ClientKey:    raw[2*KeySize : 3*KeySize],
+		ServerKey:    raw[3*KeySize : 4*KeySize],
+		ClientIV:     raw[4*KeySize : 4*KeySize+ivSize],
+		ServerIV:     raw[4*KeySize+ivSize:],
+	}
+}
+
+// Record protection of client's writes (and server's reads).
+func (kb *KeyBlock) Client(suite CipherSuite) (*RecordProtection, error) {
+	return NewRecordProtection(suite, kb.ClientMACKey, kb.ClientKey, kb.ClientIV)
+}
+
+// Record protection of server's writes (and client's reads).
+func (kb *KeyBlock) Server(suite CipherSuite) (*RecordProtection, error) {
+	return NewRecordProtection(suite, kb.ServerMACKey, kb.ServerKey, kb.ServerIV)
+}
+
+// Finished message's verify_data. handshakeHash is the Streebog-256
+// hash of the handshake messages.
+func FinishedVerifyData(masterSecret []byte, client bool, handshakeHash []byte) []byte {
+	label := "server finished"
+	if client {
+		label = "client finished"
+	}
+	return gost34112012256.PRF(masterSecret, []byte(label), handshakeHash, VerifyDataSize)
+}
@@ -0,0 +1,277 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// GOST TLS 1.2 CTR_OMAC cipher suites record protection (RFC 9189).
+//
+// Every record is MAC-ed with OMAC and then encrypted in CTR mode of
+// either Kuznyechik or Magma cipher. Both keys are derived with TLSTREE
+// function from the record's sequence number.
+package tls12gost
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/gost3413"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+)
+
+type CipherSuite uint16
+
+const (
+	TLSGOSTR341112256WithKuznyechikCTROMAC CipherSuite = 0xC100
+	TLSGOSTR341112256WithMagmaCTROMAC      CipherSuite = 0xC101
+
+	KeySize = 32
+
+	RecordHeaderSize = 5
+	MaxPlaintextSize = 1 << 14
+)
+
+type ContentType byte
+
+const (
+	ContentTypeChangeCipherSpec ContentType = 20
+	ContentTypeAlert            ContentType = 21
+	ContentTypeHandshake        ContentType = 22
+	ContentTypeApplicationData  ContentType = 23
+)
+
+func (cs CipherSuite) String() string {
+	switch cs {
+	case TLSGOSTR341112256WithKuznyechikCTROMAC:
+		return "TLS_GOSTR341112_256_WITH_KUZNYECHIK_CTR_OMAC"
+	case TLSGOSTR341112256WithMagmaCTROMAC:
+		return "TLS_GOSTR341112_256_WITH_MAGMA_CTR_OMAC"
+	}
+	return fmt.Sprintf("CipherSuite(0x%04X)", uint16(cs))
+}
+
+// Is that cipher suite known.
+func (cs CipherSuite) Supported() bool {
+	return cs == TLSGOSTR341112256WithKuznyechikCTROMAC ||
+		cs == TLSGOSTR341112256WithMagmaCTROMAC
+}
+
+func (cs CipherSuite) blockSize() int {
+	if cs == TLSGOSTR341112256WithKuznyechikCTROMAC {
+		return gost3412128.BlockSize
+	}
+	return gost341264.BlockSize
+}
+
+// Size of the fixed IV, half of the cipher's blocksize.
+func (cs CipherSuite) IVSize() int {
+	return cs.blockSize() / 2
+}
+
+// Size of the MAC, equal to the cipher's blocksize.
+func (cs CipherSuite) MACSize() int {
+	return cs.blockSize()
+}
+
+func (cs CipherSuite) TLSTreeParams() gost34112012256.TLSTreeParams {
+	switch cs {
+	case TLSGOSTR341112256WithKuznyechikCTROMAC:
+		return gost34112012256.TLSGOSTR341112256WithKuznyechikCTROMAC
+	case TLSGOSTR341112256WithMagmaCTROMAC:
+		return gost34112012256.TLSGOSTR341112256WithMagmaCTROMAC
+	}
+	panic("unsupported cipher suite")
+}
+
+func (cs CipherSuite) newCipher(key []byte) cipher.Block {
+	if cs == TLSGOSTR341112256WithKuznyechikCTROMAC {
+		return gost3412128.NewCipher(key)
+	}
+	return gost341264.NewCipher(key)
+}
+
+// Record protection state of the single direction (either read or
+// write) of the connection.
+type RecordProtection struct {
+	suite     CipherSuite
+	macTree   *gost34112012256.TLSTree
+	encTree   *gost34112012256.TLSTree
+	macCipher cipher.Block
+	omac      *gost3413.OMAC
+	encCipher cipher.Block
+	iv        []byte
+	ctr       []byte
+}
+
+// Create record protection with the given MAC key, encryption key and
+// fixed IV, taken from the key block.
+func NewRecordProtection(suite CipherSuite, macKey, encKey, iv []byte) (*RecordProtection, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if !suite.Supported() {
+		return nil, errors.New("unsupported cipher suite")
+	}
+	if len(macKey) != KeySize || len(encKey) != KeySize {
+		return nil, errors.New("invalid key size")
+	}
+	if len(iv) != suite.IVSize() {
+		return nil, errors.New("invalid IV size")
+	}
+	rp := RecordProtection{
+		suite:   suite,
+		macTree: gost34112012256.NewTLSTree(suite.TLSTreeParams(), macKey),
+		encTree: gost34112012256.NewTLSTree(suite.TLSTreeParams(), encKey),
+		iv:      make([]byte, len(iv)),
+		ctr:     make([]byte, suite.blockSize()),
+	}
+	copy(rp.iv, iv)
+	return &rp, nil
+}
+
+func (rp *RecordProtection) Suite() CipherSuite {
+	return rp.suite
+}
+
+// MAC over seq_num || type || version || length || content, computed
+// with K_MAC^{seqnum} = TLSTREE(K_MAC, seqnum). Cipher and OMAC are
+// recreated only when the key changes.
+func (rp *RecordProtection) mac(dst []byte, seqNum uint64, header, content []byte) []byte {
+	key, cached := rp.macTree.DeriveCached(seqNum)
+	if !cached || rp.omac == nil {
+		if rp.omac != nil {
+			rp.omac.Destroy()
+			destroy(rp.macCipher)
+		}
+		rp.macCipher = rp.suite.newCipher(key)
+		m, err := gost3413.NewOMAC(rp.macCipher, rp.suite.MACSize())
+		if err != nil {
+			panic(err)
+		}
+		rp.omac = m
+	}
+	m := rp.omac
+	m.Reset()
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], seqNum)
+	m.Write(seq[:])
+	m.Write(header[:3])
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(content)))
+	m.Write(length[:])
+	m.Write(content)
+	return m.Sum(dst)
+}
+
+// CTR keystream with K_ENC^{seqnum} = TLSTREE(K_ENC, seqnum) and
+// IV^{seqnum} = (IV + seqnum) mod 2^{n/2} as the counter's upper half.
+func (rp *RecordProtection) xor(dst, src []byte, seqNum uint64) {
+	key, cached := rp.encTree.DeriveCached(seqNum)
+	if !cached || rp.encCipher == nil {
+		if rp.encCipher != nil {
+			destroy(rp.encCipher)
+		}
+		rp.encCipher = rp.suite.newCipher(key)
+	}
+	half := len(rp.iv)
+	var iv uint64
+	for _, b := range rp.iv {
+		iv = iv<<8 | uint64(b)
+	}
+	iv += seqNum
+	for i := half - 1; i >= 0; i-- {
+		rp.ctr[i] = byte(iv)
+		iv >>= 8
+	}
+	for i := half; i < len(rp.ctr); i++ {
+		rp.ctr[i] = 0
+	}
+	cipher.NewCTR(rp.encCipher, rp.ctr).XORKeyStream(dst, src)
+}
+
+func destroy(c cipher.Block) {
+	if d, ok := c.(interface{ Destroy() }); ok {
+		d.Destroy()
+	}
+}
+
+// Create TLSCiphertext record (with header) of the content with the
+// given type.
+func (rp *RecordProtection) Seal(dst []byte, seqNum uint64, typ ContentType, content []byte) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if len(content) > MaxPlaintextSize {
+		return nil, errors.New("too long plaintext")
+	}
+	header := []byte{byte(typ), 0x03, 0x03, 0, 0}
+	binary.BigEndian.PutUint16(header[3:], uint16(len(content)+rp.suite.MACSize()))
+	payload := make([]byte, len(content), len(content)+rp.suite.MACSize())
+	copy(payload, content)
+	payload = rp.mac(payload, seqNum, header, content)
+	rp.xor(payload, payload, seqNum)
+	return append(append(dst, header...), payload...), nil
+}
+
+// Decrypt and authenticate the whole TLSCiphertext record (with
+// header), returning content's type and content itself.
+func (rp *RecordProtection) Open(seqNum uint64, record []byte) (ContentType, []byte, error) {
+	if !strict.Ready() {
+		return 0, nil, strict.ErrNotReady
+	}
+	if len(record) < RecordHeaderSize {
+		return 0, nil, errors.New("too short record")
+	}
+	length := int(binary.BigEndian.Uint16(record[3:]))
+	if len(record) != RecordHeaderSize+length {
+		return 0, nil, errors.New("invalid record length")
+	}
+	macSize := rp.suite.MACSize()
+	if length < macSize {
+		return 0, nil, errors.New("too short payload")
+	}
+	if length > MaxPlaintextSize+macSize {
+		return 0, nil, errors.New("too long record")
+	}
+	payload := make([]byte, length)
+	rp.xor(payload, record[RecordHeaderSize:], seqNum)
+	content := payload[:length-macSize]
+	expected := rp.mac(nil, seqNum, record[:RecordHeaderSize], content)
+	if !hmac.Equal(expected, payload[length-macSize:]) {
+		wipe.Bytes(payload)
+		return 0, nil, errors.New("invalid MAC")
+	}
+	return ContentType(record[0]), content, nil
+}
+
+// Zero the key material. It must not be used after that.
+func (rp *RecordProtection) Destroy() {
+	rp.macTree.Destroy()
+	rp.encTree.Destroy()
+	if rp.omac != nil {
+		rp.omac.Destroy()
+		destroy(rp.macCipher)
+	}
+	if rp.encCipher != nil {
+		destroy(rp.encCipher)
+	}
+	wipe.Bytes(rp.iv)
+	wipe.Bytes(rp.ctr)
+}
@@ -0,0 +1,190 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls12gost
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+	"testing/quick"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3413"
+)
+
+var suites = []CipherSuite{
+	TLSGOSTR341112256WithKuznyechikCTROMAC,
+	TLSGOSTR341112256WithMagmaCTROMAC,
+}
+
+func newPair(t *testing.T, suite CipherSuite) (*RecordProtection, *RecordProtection) {
+	ms := make([]byte, MasterSecretSize)
+	cr := make([]byte, 32)
+	sr := make([]byte, 32)
+	rand.Read(ms)
+	rand.Read(cr)
+	rand.Read(sr)
+	kb := NewKeyBlock(suite, ms, cr, sr)
+	w, err := kb.Client(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := kb.Client(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, r
+}
+
+func TestRecordSymmetric(t *testing.T) {
+	for _, suite := range suites {
+		w, r := newPair(t, suite)
+		var seqNum uint64
+		f := func(content []byte) bool {
+			record, err := w.Seal(nil, seqNum, ContentTypeApplicationData, content)
+			if err != nil {
+				return false
+			}
+			if len(record) != RecordHeaderSize+len(content)+suite.MACSize() {
+				return false
+			}
+			typ, got, err := r.Open(seqNum, record)
+			seqNum++
+			return err == nil &&
+				typ == ContentTypeApplicationData &&
+				bytes.Compare(got, content) == 0
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Error(suite, err)
+		}
+	}
+}
+
+// Check record against independent composition of TLSTREE, OMAC and
+// CTR.
+func TestRecordConstruction(t *testing.T) {
+	for _, suite := range suites {
+		macKey := make([]byte, KeySize)
+		encKey := make([]byte, KeySize)
+		iv := make([]byte, suite.IVSize())
+		rand.Read(macKey)
+		rand.Read(encKey)
+		rand.Read(iv)
+		iv[0] = 0xFF
+		rp, err := NewRecordProtection(suite, macKey, encKey, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		macTree := gost34112012256.NewTLSTree(suite.TLSTreeParams(), macKey)
+		encTree := gost34112012256.NewTLSTree(suite.TLSTreeParams(), encKey)
+		content := []byte("some TLS 1.2 record content")
+		// Includes records sharing the TLSTREE key and returning to the
+		// previous one
+		for _, seqNum := range []uint64{0, 1, 2, 1 << 31, 1<<31 + 1, 1<<32 + 5, 3} {
+			record, _ := rp.Seal(nil, seqNum, ContentTypeHandshake, content)
+
+			m, _ := gost3413.NewOMAC(suite.newCipher(macTree.Derive(seqNum)), suite.MACSize())
+			macData := make([]byte, 8+5)
+			binary.BigEndian.PutUint64(macData, seqNum)
+			macData[8] = byte(ContentTypeHandshake)
+			macData[9] = 3
+			macData[10] = 3
+			binary.BigEndian.PutUint16(macData[11:], uint16(len(content)))
+			m.Write(append(macData, content...))
+			payload := m.Sum(append([]byte{}, content...))
+
+			ctr := make([]byte, suite.blockSize())
+			copy(ctr, iv)
+			var carry uint64 = seqNum
+			for i := len(iv) - 1; i >= 0; i-- {
+				sum := uint64(ctr[i]) + carry&0xFF
+				ctr[i] = byte(sum)
+				carry = carry>>8 + sum>>8
+			}
+			cipher.NewCTR(suite.newCipher(encTree.Derive(seqNum)), ctr).XORKeyStream(payload, payload)
+			if bytes.Compare(record[RecordHeaderSize:], payload) != 0 {
+				t.Fatal(suite, seqNum)
+			}
+		}
+	}
+}
+
+func TestRecordTampered(t *testing.T) {
+	for _, suite := range suites {
+		w, r := newPair(t, suite)
+		record, _ := w.Seal(nil, 7, ContentTypeApplicationData, []byte("data"))
+		if _, _, err := r.Open(8, record); err == nil {
+			t.Fatal("wrong seqnum accepted")
+		}
+		for i := 0; i < len(record); i++ {
+			tampered := make([]byte, len(record))
+			copy(tampered, record)
+			tampered[i] ^= 0x01
+			if _, _, err := r.Open(7, tampered); err == nil {
+				t.Fatal("tampered record accepted", i)
+			}
+		}
+		if _, _, err := r.Open(7, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKeyBlock(t *testing.T) {
+	ms := make([]byte, MasterSecretSize)
+	for _, suite := range suites {
+		kb := NewKeyBlock(suite, ms, make([]byte, 32), make([]byte, 32))
+		if len(kb.ClientIV) != suite.IVSize() || len(kb.ServerIV) != suite.IVSize() {
+			t.FailNow()
+		}
+		if bytes.Compare(kb.ClientKey, kb.ServerKey) == 0 {
+			t.FailNow()
+		}
+		w, _ := kb.Client(suite)
+		r, _ := kb.Server(suite)
+		record, _ := w.Seal(nil, 0, ContentTypeHandshake, []byte("finished"))
+		if _, _, err := r.Open(0, record); err == nil {
+			t.Fatal("opened with other direction's keys")
+		}
+	}
+	if len(FinishedVerifyData(ms, true, make([]byte, 32))) != VerifyDataSize {
+		t.FailNow()
+	}
+}
+
+func TestRecordDestroy(t *testing.T) {
+	w, _ := newPair(t, TLSGOSTR341112256WithKuznyechikCTROMAC)
+	w.Destroy()
+	for _, b := range w.iv {
+		if b != 0 {
+			t.FailNow()
+		}
+	}
+}
+
+func BenchmarkSeal(b *testing.B) {
+	suite := TLSGOSTR341112256WithKuznyechikCTROMAC
+	rp, _ := NewRecordProtection(suite, make([]byte, 32), make([]byte, 32), make([]byte, 8))
+	content := make([]byte, 1<<10)
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rp.Seal(nil, uint64(i), ContentTypeApplicationData, content)
+	}
+}
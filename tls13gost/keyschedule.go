@@ -0,0 +1,194 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls13gost
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Hash function of the cipher suite. All RFC 9367 suites use
+// GOST R 34.11-2012 256-bit.
+func (cs CipherSuite) Hash() func() hash.Hash {
+	return gost34112012256.New
+}
+
+// HKDF-Expand-Label(Secret, Label, Context, Length) from RFC 8446.
+func HKDFExpandLabel(h func() hash.Hash, secret []byte, label string, context []byte, length int) []byte {
+	label = "tls13 " + label
+	info := make([]byte, 2+1+len(label)+1+len(context))
+	binary.BigEndian.PutUint16(info, uint16(length))
+	info[2] = byte(len(label))
+	copy(info[3:], label)
+	info[3+len(label)] = byte(len(context))
+	copy(info[4+len(label):], context)
+	out := make([]byte, length)
+	if _, err := hkdf.Expand(h, secret, info).Read(out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Derive-Secret(Secret, Label, Messages), where transcriptHash is
+// already computed Transcript-Hash(Messages).
+func DeriveSecret(h func() hash.Hash, secret []byte, label string, transcriptHash []byte) []byte {
+	return HKDFExpandLabel(h, secret, label, transcriptHash, h().Size())
+}
+
+// Transcript-Hash of the empty messages list.
+func emptyHash(h func() hash.Hash) []byte {
+	return h().Sum(nil)
+}
+
+// TLS 1.3 key schedule (RFC 8446 section 7.1). It is moved through
+// Early, Handshake and Master secrets stages consequently.
+type KeySchedule struct {
+	h      func() hash.Hash
+	secret []byte
+}
+
+// Start key schedule with the Early Secret. Nil PSK is replaced with
+// the string of zeros.
+func NewKeySchedule(h func() hash.Hash, psk []byte) *KeySchedule {
+	size := h().Size()
+	if psk == nil {
+		psk = make([]byte, size)
+	}
+	return &KeySchedule{h: h, secret: hkdf.Extract(h, psk, make([]byte, size))}
+}
+
+// Current stage's secret.
+func (ks *KeySchedule) Secret() []byte {
+	return ks.secret
+}
+
+func (ks *KeySchedule) next(ikm []byte) {
+	size := ks.h().Size()
+	if ikm == nil {
+		ikm = make([]byte, size)
+	}
+	salt := DeriveSecret(ks.h, ks.secret, "derived", emptyHash(ks.h))
+	wipe.Bytes(ks.secret)
+	ks.secret = hkdf.Extract(ks.h, ikm, salt)
+	wipe.Bytes(salt)
+}
+
+// Move from the Early Secret to the Handshake Secret with the (EC)DHE
+// shared secret. Nil is replaced with the string of zeros.
+func (ks *KeySchedule) Handshake(sharedSecret []byte) {
+	ks.next(sharedSecret)
+}
+
+// Move from the Handshake Secret to the Master Secret.
+func (ks *KeySchedule) Master() {
+	ks.next(nil)
+}
+
+func (ks *KeySchedule) Derive(label string, transcriptHash []byte) []byte {
+	return DeriveSecret(ks.h, ks.secret, label, transcriptHash)
+}
+
+// Early Secret stage derivations.
+
+func (ks *KeySchedule) BinderKey(external bool) []byte {
+	if external {
+		return ks.Derive("ext binder", emptyHash(ks.h))
+	}
+	return ks.Derive("res binder", emptyHash(ks.h))
+}
+
+func (ks *KeySchedule) ClientEarlyTrafficSecret(transcriptHash []byte) []byte {
+	return ks.Derive("c e traffic", transcriptHash)
+}
+
+func (ks *KeySchedule) EarlyExporterMasterSecret(transcriptHash []byte) []byte {
+	return ks.Derive("e exp master", transcriptHash)
+}
+
+// Handshake Secret stage derivations.
+
+func (ks *KeySchedule) ClientHandshakeTrafficSecret(transcriptHash []byte) []byte {
+	return ks.Derive("c hs traffic", transcriptHash)
+}
+
+func (ks *KeySchedule) ServerHandshakeTrafficSecret(transcriptHash []byte) []byte {
+	return ks.Derive("s hs traffic", transcriptHash)
+}
+
+// Master Secret stage derivations.
+
+func (ks *KeySchedule) ClientApplicationTrafficSecret(transcriptHash []byte) []byte {
+	return ks.Derive("c ap traffic", transcriptHash)
+}
+
+func (ks *KeySchedule) ServerApplicationTrafficSecret(transcriptHash []byte) []byte {
+	return ks.Derive("s ap traffic", transcriptHash)
+}
+
+func (ks *KeySchedule) ExporterMasterSecret(transcriptHash []byte) []byte {
+	return ks.Derive("exp master", transcriptHash)
+}
+
+func (ks *KeySchedule) ResumptionMasterSecret(transcriptHash []byte) []byte {
+	return ks.Derive("res master", transcriptHash)
+}
+
+// Zero the current stage's secret.
+func (ks *KeySchedule) Destroy() {
+	wipe.Bytes(ks.secret)
+}
+
+// Traffic key and IV for the record protection.
+func TrafficKeys(suite CipherSuite, trafficSecret []byte) (key, iv []byte) {
+	h := suite.Hash()
+	key = HKDFExpandLabel(h, trafficSecret, "key", nil, KeySize)
+	iv = HKDFExpandLabel(h, trafficSecret, "iv", nil, suite.IVSize())
+	return
+}
+
+// Create record protection from the traffic secret.
+func NewRecordProtectionFromSecret(suite CipherSuite, trafficSecret []byte) (*RecordProtection, error) {
+	key, iv := TrafficKeys(suite, trafficSecret)
+	rp, err := NewRecordProtection(suite, key, iv)
+	wipe.Bytes(key)
+	wipe.Bytes(iv)
+	return rp, err
+}
+
+// finished_key = HKDF-Expand-Label(BaseKey, "finished", "", Hash.length)
+func FinishedKey(h func() hash.Hash, baseKey []byte) []byte {
+	return HKDFExpandLabel(h, baseKey, "finished", nil, h().Size())
+}
+
+// verify_data = HMAC(finished_key, Transcript-Hash(...))
+func FinishedVerifyData(h func() hash.Hash, baseKey, transcriptHash []byte) []byte {
+	key := FinishedKey(h, baseKey)
+	mac := hmac.New(h, key)
+	mac.Write(transcriptHash)
+	wipe.Bytes(key)
+	return mac.Sum(nil)
+}
+
+// application_traffic_secret_N+1 used after KeyUpdate.
+func NextTrafficSecret(h func() hash.Hash, trafficSecret []byte) []byte {
+	return HKDFExpandLabel(h, trafficSecret, "traffic upd", nil, h().Size())
+}
@@ -0,0 +1,147 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls13gost
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Key schedule is independent of the hash function, so it is checked
+// with RFC 8448 "Simple 1-RTT Handshake" trace over SHA-256.
+func TestKeyScheduleRFC8448(t *testing.T) {
+	h := sha256.New
+	ks := NewKeySchedule(h, nil)
+	if bytes.Compare(ks.Secret(), unhex(
+		"33ad0a1c607ec03b09e6cd9893680ce210adf300aa1f2660e1b22e10f170f92a",
+	)) != 0 {
+		t.Fatal("early secret")
+	}
+	ks.Handshake(unhex(
+		"8bd4054fb55b9d63fdfbacf9f04b9f0d35e6d63f537563efd46272900f89492d",
+	))
+	if bytes.Compare(ks.Secret(), unhex(
+		"1dc826e93606aa6fdc0aadc12f741b01046aa6b99f691ed221a9f0ca043fbeac",
+	)) != 0 {
+		t.Fatal("handshake secret")
+	}
+	th := unhex("860c06edc07858ee8e78f0e7428c58edd6b43f2ca3e6e95f02ed063cf0e1cad8")
+	if bytes.Compare(ks.ClientHandshakeTrafficSecret(th), unhex(
+		"b3eddb126e067f35a780b3abf45e2d8f3b1a950738f52e9600746a0e27a55a21",
+	)) != 0 {
+		t.Fatal("client handshake traffic secret")
+	}
+	sHS := ks.ServerHandshakeTrafficSecret(th)
+	if bytes.Compare(sHS, unhex(
+		"b67b7d690cc16c4e75e54213cb2d37b4e9c912bcded9105d42befd59d391ad38",
+	)) != 0 {
+		t.Fatal("server handshake traffic secret")
+	}
+	if bytes.Compare(HKDFExpandLabel(h, sHS, "key", nil, 16), unhex(
+		"3fce516009c21727d0f2e4e86ee403bc",
+	)) != 0 {
+		t.Fatal("server handshake key")
+	}
+	if bytes.Compare(HKDFExpandLabel(h, sHS, "iv", nil, 12), unhex(
+		"5d313eb2671276ee13000b30",
+	)) != 0 {
+		t.Fatal("server handshake iv")
+	}
+	if bytes.Compare(FinishedKey(h, sHS), unhex(
+		"008d3b66f816ea559f96b537e885c31fc068bf492c652f01f288a1d8cdc19fc8",
+	)) != 0 {
+		t.Fatal("server finished key")
+	}
+	ks.Master()
+	if bytes.Compare(ks.Secret(), unhex(
+		"18df06843d13a08bf2a449844c5f8a478001bc4d4c627984d5a41da8d0402919",
+	)) != 0 {
+		t.Fatal("master secret")
+	}
+}
+
+func TestFinishedVerifyData(t *testing.T) {
+	h := TLSGOSTR341112256WithKuznyechikMGML.Hash()
+	base := bytes.Repeat([]byte{0x11}, 32)
+	th := bytes.Repeat([]byte{0x22}, 32)
+	mac := hmac.New(h, FinishedKey(h, base))
+	mac.Write(th)
+	if bytes.Compare(FinishedVerifyData(h, base, th), mac.Sum(nil)) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestTrafficKeys(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x33}, 32)
+	for _, suite := range suites {
+		key, iv := TrafficKeys(suite, secret)
+		if len(key) != KeySize || len(iv) != suite.IVSize() {
+			t.Fatal(suite)
+		}
+	}
+}
+
+func TestKeyUpdate(t *testing.T) {
+	suite := TLSGOSTR341112256WithMagmaMGMS
+	h := suite.Hash()
+	ks := NewKeySchedule(h, nil)
+	ks.Handshake(bytes.Repeat([]byte{0x44}, 32))
+	ks.Master()
+	th := bytes.Repeat([]byte{0x55}, 32)
+	secret := ks.ClientApplicationTrafficSecret(th)
+	if bytes.Compare(secret, ks.ServerApplicationTrafficSecret(th)) == 0 {
+		t.FailNow()
+	}
+	w, err := NewRecordProtectionFromSecret(suite, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, _ := w.Seal(nil, 0, ContentTypeApplicationData, []byte("data"), 0)
+	next := NextTrafficSecret(h, secret)
+	if bytes.Compare(next, secret) == 0 || len(next) != h().Size() {
+		t.FailNow()
+	}
+	r, _ := NewRecordProtectionFromSecret(suite, next)
+	if _, _, err = r.Open(0, record); err == nil {
+		t.Fatal("updated key opens old record")
+	}
+	r, _ = NewRecordProtectionFromSecret(suite, secret)
+	if _, _, err = r.Open(0, record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyScheduleDestroy(t *testing.T) {
+	ks := NewKeySchedule(TLSGOSTR341112256WithKuznyechikMGMS.Hash(), nil)
+	ks.Destroy()
+	for _, b := range ks.Secret() {
+		if b != 0 {
+			t.FailNow()
+		}
+	}
+}
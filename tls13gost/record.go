@@ -0,0 +1,284 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// GOST TLS 1.3 cipher suites record protection (RFC 9367).
+//
+// Every record is encrypted with MGM mode of either Kuznyechik or Magma
+// cipher. Its key is derived from the traffic key with TLSTREE function
+// and record's sequence number, nonce is the traffic IV xored with the
+// sequence number.
+package tls13gost
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/internal/strict"
+	"github.com/ddulesov/gogost/internal/wipe"
+	"github.com/ddulesov/gogost/mgm"
+)
+
+type CipherSuite uint16
+
+const (
+	TLSGOSTR341112256WithKuznyechikMGML CipherSuite = 0xC103
+	TLSGOSTR341112256WithMagmaMGML      CipherSuite = 0xC104
+	TLSGOSTR341112256WithKuznyechikMGMS CipherSuite = 0xC105
+	TLSGOSTR341112256WithMagmaMGMS      CipherSuite = 0xC106
+
+	KeySize = 32
+
+	RecordHeaderSize = 5
+	MaxPlaintextSize = 1 << 14
+	// Maximal TLSCiphertext.length value
+	MaxCiphertextSize = MaxPlaintextSize + 256
+)
+
+type ContentType byte
+
+const (
+	ContentTypeChangeCipherSpec ContentType = 20
+	ContentTypeAlert            ContentType = 21
+	ContentTypeHandshake        ContentType = 22
+	ContentTypeApplicationData  ContentType = 23
+)
+
+func (cs CipherSuite) String() string {
+	switch cs {
+	case TLSGOSTR341112256WithKuznyechikMGML:
+		return "TLS_GOSTR341112_256_WITH_KUZNYECHIK_MGM_L"
+	case TLSGOSTR341112256WithMagmaMGML:
+		return "TLS_GOSTR341112_256_WITH_MAGMA_MGM_L"
+	case TLSGOSTR341112256WithKuznyechikMGMS:
+		return "TLS_GOSTR341112_256_WITH_KUZNYECHIK_MGM_S"
+	case TLSGOSTR341112256WithMagmaMGMS:
+		return "TLS_GOSTR341112_256_WITH_MAGMA_MGM_S"
+	}
+	return fmt.Sprintf("CipherSuite(0x%04X)", uint16(cs))
+}
+
+// Is that cipher suite known.
+func (cs CipherSuite) Supported() bool {
+	switch cs {
+	case TLSGOSTR341112256WithKuznyechikMGML,
+		TLSGOSTR341112256WithMagmaMGML,
+		TLSGOSTR341112256WithKuznyechikMGMS,
+		TLSGOSTR341112256WithMagmaMGMS:
+		return true
+	}
+	return false
+}
+
+func (cs CipherSuite) kuznyechik() bool {
+	return cs == TLSGOSTR341112256WithKuznyechikMGML ||
+		cs == TLSGOSTR341112256WithKuznyechikMGMS
+}
+
+// Size of the traffic IV, equal to the cipher's blocksize.
+func (cs CipherSuite) IVSize() int {
+	if cs.kuznyechik() {
+		return gost3412128.BlockSize
+	}
+	return gost341264.BlockSize
+}
+
+// Size of the authentication tag, equal to the cipher's blocksize.
+func (cs CipherSuite) TagSize() int {
+	return cs.IVSize()
+}
+
+func (cs CipherSuite) TLSTreeParams() gost34112012256.TLSTreeParams {
+	switch cs {
+	case TLSGOSTR341112256WithKuznyechikMGML:
+		return gost34112012256.TLSGOSTR341112256WithKuznyechikMGML
+	case TLSGOSTR341112256WithMagmaMGML:
+		return gost34112012256.TLSGOSTR341112256WithMagmaMGML
+	case TLSGOSTR341112256WithKuznyechikMGMS:
+		return gost34112012256.TLSGOSTR341112256WithKuznyechikMGMS
+	case TLSGOSTR341112256WithMagmaMGMS:
+		return gost34112012256.TLSGOSTR341112256WithMagmaMGMS
+	}
+	panic("unsupported cipher suite")
+}
+
+// Record protection state of the single direction (either read or
+// write) of the connection.
+type RecordProtection struct {
+	suite CipherSuite
+	tree  *gost34112012256.TLSTree
+	iv    []byte
+	nonce []byte
+	aead  cipher.AEAD
+}
+
+// Create record protection with the given traffic key and IV, that are
+// derived from the traffic secret.
+func NewRecordProtection(suite CipherSuite, key, iv []byte) (*RecordProtection, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if !suite.Supported() {
+		return nil, errors.New("unsupported cipher suite")
+	}
+	if len(key) != KeySize {
+		return nil, errors.New("invalid key size")
+	}
+	if len(iv) != suite.IVSize() {
+		return nil, errors.New("invalid IV size")
+	}
+	rp := RecordProtection{
+		suite: suite,
+		tree:  gost34112012256.NewTLSTree(suite.TLSTreeParams(), key),
+		iv:    make([]byte, len(iv)),
+		nonce: make([]byte, len(iv)),
+	}
+	copy(rp.iv, iv)
+	return &rp, nil
+}
+
+func (rp *RecordProtection) Suite() CipherSuite {
+	return rp.suite
+}
+
+// Per-record key K^{seqnum} = TLSTREE(K, seqnum). Cipher is recreated
+// only when the key changes.
+func (rp *RecordProtection) prepare(seqNum uint64) cipher.AEAD {
+	key, cached := rp.tree.DeriveCached(seqNum)
+	if !cached || rp.aead == nil {
+		if rp.aead != nil {
+			rp.aead.(*mgm.MGM).Destroy()
+		}
+		var c cipher.Block
+		if rp.suite.kuznyechik() {
+			c = gost3412128.NewCipher(key)
+		} else {
+			c = gost341264.NewCipher(key)
+		}
+		aead, err := mgm.NewMGM(c, c.BlockSize())
+		if err != nil {
+			panic(err)
+		}
+		rp.aead = aead
+	}
+	copy(rp.nonce, rp.iv)
+	for i := 0; i < 8; i++ {
+		rp.nonce[len(rp.nonce)-1-i] ^= byte(seqNum >> uint(8*i))
+	}
+	// MGM takes (n-1)-bit nonce
+	rp.nonce[0] &= 0x7F
+	return rp.aead
+}
+
+// Encrypt record payload (TLSInnerPlaintext) with the given sequence
+// number and additional data (record header).
+func (rp *RecordProtection) SealPayload(dst []byte, seqNum uint64, payload, additionalData []byte) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	aead := rp.prepare(seqNum)
+	return aead.Seal(dst, rp.nonce, payload, additionalData), nil
+}
+
+// Decrypt and authenticate record payload.
+func (rp *RecordProtection) OpenPayload(dst []byte, seqNum uint64, payload, additionalData []byte) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if len(payload) < rp.suite.TagSize() {
+		return nil, errors.New("too short payload")
+	}
+	aead := rp.prepare(seqNum)
+	return aead.Open(dst, rp.nonce, payload, additionalData)
+}
+
+// Create TLSCiphertext record (with header) of the content with the
+// given type. Padding is the number of zero bytes appended to the
+// TLSInnerPlaintext.
+func (rp *RecordProtection) Seal(dst []byte, seqNum uint64, typ ContentType, content []byte, padding int) ([]byte, error) {
+	if !strict.Ready() {
+		return nil, strict.ErrNotReady
+	}
+	if len(content)+1+padding > MaxPlaintextSize+1 {
+		return nil, errors.New("too long plaintext")
+	}
+	inner := make([]byte, len(content)+1+padding)
+	copy(inner, content)
+	inner[len(content)] = byte(typ)
+	header := make([]byte, RecordHeaderSize)
+	header[0] = byte(ContentTypeApplicationData)
+	header[1] = 0x03
+	header[2] = 0x03
+	binary.BigEndian.PutUint16(header[3:], uint16(len(inner)+rp.suite.TagSize()))
+	dst = append(dst, header...)
+	dst, err := rp.SealPayload(dst, seqNum, inner, header)
+	wipe.Bytes(inner)
+	return dst, err
+}
+
+// Decrypt the whole TLSCiphertext record (with header), returning
+// content's type and content itself.
+func (rp *RecordProtection) Open(seqNum uint64, record []byte) (ContentType, []byte, error) {
+	if !strict.Ready() {
+		return 0, nil, strict.ErrNotReady
+	}
+	if len(record) < RecordHeaderSize {
+		return 0, nil, errors.New("too short record")
+	}
+	if ContentType(record[0]) != ContentTypeApplicationData {
+		return 0, nil, errors.New("unexpected outer content type")
+	}
+	length := int(binary.BigEndian.Uint16(record[3:]))
+	if length > MaxCiphertextSize {
+		return 0, nil, errors.New("too long record")
+	}
+	if len(record) != RecordHeaderSize+length {
+		return 0, nil, errors.New("invalid record length")
+	}
+	inner, err := rp.OpenPayload(
+		nil, seqNum, record[RecordHeaderSize:], record[:RecordHeaderSize],
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	i := len(inner) - 1
+	for ; i >= 0; i-- {
+		if inner[i] != 0 {
+			break
+		}
+	}
+	if i < 0 {
+		return 0, nil, errors.New("no content type")
+	}
+	if i > MaxPlaintextSize {
+		return 0, nil, errors.New("too long plaintext")
+	}
+	return ContentType(inner[i]), inner[:i], nil
+}
+
+// Zero the key material. It must not be used after that.
+func (rp *RecordProtection) Destroy() {
+	rp.tree.Destroy()
+	wipe.Bytes(rp.iv)
+	wipe.Bytes(rp.nonce)
+	if rp.aead != nil {
+		rp.aead.(*mgm.MGM).Destroy()
+	}
+}
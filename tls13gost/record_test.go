@@ -0,0 +1,222 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tls13gost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost3412128"
+	"github.com/ddulesov/gogost/gost341264"
+	"github.com/ddulesov/gogost/mgm"
+)
+
+var suites = []CipherSuite{
+	TLSGOSTR341112256WithKuznyechikMGML,
+	TLSGOSTR341112256WithMagmaMGML,
+	TLSGOSTR341112256WithKuznyechikMGMS,
+	TLSGOSTR341112256WithMagmaMGMS,
+}
+
+func newPair(t *testing.T, suite CipherSuite) (*RecordProtection, *RecordProtection) {
+	key := make([]byte, KeySize)
+	iv := make([]byte, suite.IVSize())
+	rand.Read(key)
+	rand.Read(iv)
+	w, err := NewRecordProtection(suite, key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRecordProtection(suite, key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, r
+}
+
+func TestRecordSymmetric(t *testing.T) {
+	for _, suite := range suites {
+		w, r := newPair(t, suite)
+		var seqNum uint64
+		f := func(content []byte, padding uint8) bool {
+			if len(content) == 0 {
+				return true
+			}
+			record, err := w.Seal(nil, seqNum, ContentTypeHandshake, content, int(padding))
+			if err != nil {
+				return false
+			}
+			if len(record) != RecordHeaderSize+len(content)+1+int(padding)+suite.TagSize() {
+				return false
+			}
+			typ, got, err := r.Open(seqNum, record)
+			seqNum++
+			return err == nil &&
+				typ == ContentTypeHandshake &&
+				bytes.Compare(got, content) == 0
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Error(suite, err)
+		}
+	}
+}
+
+// Check that payload protection is exactly MGM keyed with TLSTREE
+// derived key and nonce equal to IV xored with sequence number.
+func TestRecordConstruction(t *testing.T) {
+	for _, suite := range suites {
+		key := make([]byte, KeySize)
+		iv := make([]byte, suite.IVSize())
+		rand.Read(key)
+		rand.Read(iv)
+		rp, err := NewRecordProtection(suite, key, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree := gost34112012256.NewTLSTree(suite.TLSTreeParams(), key)
+		ad := []byte{0x17, 0x03, 0x03, 0x00, 0x00}
+		pt := []byte("some TLSInnerPlaintext")
+		for _, seqNum := range []uint64{0, 1, 63, 64, 4095, 4096, 1 << 32, 1<<63 + 1} {
+			var c interface {
+				BlockSize() int
+				Encrypt(dst, src []byte)
+				Decrypt(dst, src []byte)
+			}
+			if suite.kuznyechik() {
+				c = gost3412128.NewCipher(tree.Derive(seqNum))
+			} else {
+				c = gost341264.NewCipher(tree.Derive(seqNum))
+			}
+			aead, err := mgm.NewMGM(c, c.BlockSize())
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce := make([]byte, len(iv))
+			copy(nonce, iv)
+			for i := 0; i < 8; i++ {
+				nonce[len(nonce)-1-i] ^= byte(seqNum >> uint(8*i))
+			}
+			nonce[0] &= 0x7F
+			sealed, err := rp.SealPayload(nil, seqNum, pt, ad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Compare(sealed, aead.Seal(nil, nonce, pt, ad)) != 0 {
+				t.Fatal(suite, seqNum)
+			}
+		}
+	}
+}
+
+func TestRecordTampered(t *testing.T) {
+	for _, suite := range suites {
+		w, r := newPair(t, suite)
+		record, err := w.Seal(nil, 10, ContentTypeApplicationData, []byte("data"), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = r.Open(11, record); err == nil {
+			t.Fatal("wrong seqnum accepted")
+		}
+		for i := 0; i < len(record); i++ {
+			tampered := make([]byte, len(record))
+			copy(tampered, record)
+			tampered[i] ^= 0x01
+			if _, _, err = r.Open(10, tampered); err == nil {
+				t.Fatal("tampered record accepted", i)
+			}
+		}
+		typ, got, err := r.Open(10, record)
+		if err != nil || typ != ContentTypeApplicationData || string(got) != "data" {
+			t.FailNow()
+		}
+	}
+}
+
+func TestRecordNoContentType(t *testing.T) {
+	w, r := newPair(t, TLSGOSTR341112256WithKuznyechikMGML)
+	header := []byte{0x17, 0x03, 0x03, 0x00, 4 + 16}
+	record, err := w.SealPayload(header, 0, make([]byte, 4), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = r.Open(0, record); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestRecordKeyChange(t *testing.T) {
+	suite := TLSGOSTR341112256WithMagmaMGML
+	w, _ := newPair(t, suite)
+	pt := make([]byte, 8)
+	ad := []byte{}
+	params := suite.TLSTreeParams()
+	// Returning to the previous TLSTREE leaf must rederive its key
+	a, _ := w.SealPayload(nil, 0, pt, ad)
+	b, _ := w.SealPayload(nil, 0, pt, ad)
+	if bytes.Compare(a, b) != 0 {
+		t.FailNow()
+	}
+	boundary := ^params[2] + 1
+	c, _ := w.SealPayload(nil, boundary, pt, ad)
+	d, _ := w.SealPayload(nil, 0, pt, ad)
+	if bytes.Compare(a, d) != 0 || bytes.Compare(a, c) == 0 {
+		t.FailNow()
+	}
+}
+
+func TestRecordDestroy(t *testing.T) {
+	w, r := newPair(t, TLSGOSTR341112256WithKuznyechikMGMS)
+	record, _ := w.Seal(nil, 0, ContentTypeApplicationData, []byte("data"), 0)
+	w.Destroy()
+	for _, b := range w.iv {
+		if b != 0 {
+			t.FailNow()
+		}
+	}
+	if _, _, err := r.Open(0, record); err != nil {
+		t.FailNow()
+	}
+}
+
+func TestBadParameters(t *testing.T) {
+	if _, err := NewRecordProtection(0x1234, make([]byte, 32), make([]byte, 16)); err == nil {
+		t.FailNow()
+	}
+	if _, err := NewRecordProtection(TLSGOSTR341112256WithMagmaMGML, make([]byte, 32), make([]byte, 16)); err == nil {
+		t.FailNow()
+	}
+	if _, err := NewRecordProtection(TLSGOSTR341112256WithMagmaMGML, make([]byte, 31), make([]byte, 8)); err == nil {
+		t.FailNow()
+	}
+}
+
+func BenchmarkSeal(b *testing.B) {
+	key := make([]byte, KeySize)
+	iv := make([]byte, 16)
+	rp, _ := NewRecordProtection(TLSGOSTR341112256WithKuznyechikMGML, key, iv)
+	content := make([]byte, 1<<10)
+	dst := make([]byte, 0, 2<<10)
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rp.Seal(dst, uint64(i), ContentTypeApplicationData, content, 0)
+	}
+}
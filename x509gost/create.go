@@ -0,0 +1,243 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+const keyIDSize = 20
+
+// Key identifier: leftmost 160 bits of Streebog-256 over the
+// subjectPublicKey BIT STRING value (like RFC 7093's method 1).
+func keyID(spki *gostasn1.SubjectPublicKeyInfo) []byte {
+	h := gost34112012256.New()
+	h.Write(spki.SubjectPublicKey.Bytes)
+	return h.Sum(nil)[:keyIDSize]
+}
+
+// Default signature algorithm for the key: Streebog of the corresponding
+// size.
+func signatureAlgorithmFor(prv *gost3410.PrivateKey) SignatureAlgorithm {
+	if prv.Mode == gost3410.Mode2012 {
+		return GostR34102012WithStreebog512
+	}
+	return GostR34102012WithStreebog256
+}
+
+func samePublicKey(a, b *gost3410.PublicKey) bool {
+	return a.C.Name == b.C.Name && a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+}
+
+func sign(rand io.Reader, prv *gost3410.PrivateKey, algo SignatureAlgorithm, tbs []byte) ([]byte, error) {
+	if !algo.suits(prv.Mode) {
+		return nil, errors.New("signature algorithm does not suit the private key")
+	}
+	return prv.SignDigest(algo.Digest(tbs), rand)
+}
+
+func marshalName(name pkix.Name, raw []byte) (asn1.RawValue, error) {
+	if len(raw) > 0 {
+		return asn1.RawValue{FullBytes: raw}, nil
+	}
+	der, err := asn1.Marshal(name.ToRDNSequence())
+	return asn1.RawValue{FullBytes: der}, err
+}
+
+func marshalExtension(oid asn1.ObjectIdentifier, critical bool, v interface{}) (pkix.Extension, error) {
+	der, err := asn1.Marshal(v)
+	return pkix.Extension{Id: oid, Critical: critical, Value: der}, err
+}
+
+func marshalKeyUsage(ku x509.KeyUsage) (pkix.Extension, error) {
+	var b [2]byte
+	bitLength := 0
+	for i := 0; i < 9; i++ {
+		if ku&(1<<uint(i)) != 0 {
+			b[i/8] |= 0x80 >> uint(i%8)
+			bitLength = i + 1
+		}
+	}
+	return marshalExtension(oidExtensionKeyUsage, true, asn1.BitString{
+		Bytes:     b[:(bitLength+7)/8],
+		BitLength: bitLength,
+	})
+}
+
+// Usage related extensions common for certificates and requests.
+func usageExtensions(ku x509.KeyUsage, eku []asn1.ObjectIdentifier) ([]pkix.Extension, error) {
+	var exts []pkix.Extension
+	if ku != 0 {
+		ext, err := marshalKeyUsage(ku)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	if len(eku) > 0 {
+		ext, err := marshalExtension(oidExtensionExtendedKeyUsage, false, eku)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+type tbsCertificateOut struct {
+	Version            int `asn1:"explicit,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           validity
+	Subject            asn1.RawValue
+	PublicKey          gostasn1.SubjectPublicKeyInfo
+	Extensions         []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+type certificateOut struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// Create DER-encoded X.509 v3 certificate from the template, signed by
+// parent's private key prv. Certificate is self-signed if parent is the
+// template itself. Used template fields: SerialNumber, Subject (or
+// RawSubject), NotBefore, NotAfter, SignatureAlgorithm (chosen by the
+// prv's mode if unknown), KeyUsage, ExtKeyUsage, BasicConstraintsValid,
+// IsCA, MaxPathLen, MaxPathLenZero, SubjectKeyID, AuthorityKeyID and
+// ExtraExtensions. Subject key identifier is computed if not set.
+func CreateCertificate(
+	rand io.Reader,
+	template, parent *Certificate,
+	pub *gost3410.PublicKey,
+	prv *gost3410.PrivateKey,
+) ([]byte, error) {
+	if template.SerialNumber == nil || template.SerialNumber.Sign() < 0 {
+		return nil, errors.New("invalid serial number")
+	}
+	signer, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if parent.PublicKey != nil && !samePublicKey(parent.PublicKey, signer) {
+		return nil, errors.New("private key does not match parent's public key")
+	}
+	algo := template.SignatureAlgorithm
+	if algo == UnknownSignatureAlgorithm {
+		algo = signatureAlgorithmFor(prv)
+	}
+	spki, err := gostasn1.MarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := marshalName(template.Subject, template.RawSubject)
+	if err != nil {
+		return nil, err
+	}
+	issuer := subject
+	if parent != template {
+		if issuer, err = marshalName(parent.Subject, parent.RawSubject); err != nil {
+			return nil, err
+		}
+	}
+
+	ski := template.SubjectKeyID
+	if len(ski) == 0 {
+		ski = keyID(&spki)
+	}
+	aki := template.AuthorityKeyID
+	if len(aki) == 0 {
+		if parent == template {
+			aki = ski
+		} else if len(parent.SubjectKeyID) > 0 {
+			aki = parent.SubjectKeyID
+		} else {
+			spkiSigner, err := gostasn1.MarshalPublicKey(signer)
+			if err != nil {
+				return nil, err
+			}
+			aki = keyID(&spkiSigner)
+		}
+	}
+	var exts []pkix.Extension
+	ext, err := marshalExtension(oidExtensionSubjectKeyID, false, ski)
+	if err != nil {
+		return nil, err
+	}
+	exts = append(exts, ext)
+	if ext, err = marshalExtension(oidExtensionAuthorityKeyID, false, authorityKeyID{aki}); err != nil {
+		return nil, err
+	}
+	exts = append(exts, ext)
+	if template.BasicConstraintsValid {
+		bc := basicConstraints{IsCA: template.IsCA, MaxPathLen: -1}
+		if template.MaxPathLen > 0 || (template.MaxPathLen == 0 && template.MaxPathLenZero) {
+			bc.MaxPathLen = template.MaxPathLen
+		}
+		if ext, err = marshalExtension(oidExtensionBasicConstraints, true, bc); err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	usage, err := usageExtensions(template.KeyUsage, template.ExtKeyUsage)
+	if err != nil {
+		return nil, err
+	}
+	exts = append(exts, usage...)
+	exts = append(exts, template.ExtraExtensions...)
+
+	algoID := pkix.AlgorithmIdentifier{Algorithm: algo.OID()}
+	tbs, err := asn1.Marshal(tbsCertificateOut{
+		Version:            2,
+		SerialNumber:       template.SerialNumber,
+		SignatureAlgorithm: algoID,
+		Issuer:             issuer,
+		Validity:           validity{template.NotBefore.UTC(), template.NotAfter.UTC()},
+		Subject:            subject,
+		PublicKey:          spki,
+		Extensions:         exts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(rand, prv, algo, tbs)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(certificateOut{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: algoID,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+}
+
+// PEM-encoded certificate.
+func (c *Certificate) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+}
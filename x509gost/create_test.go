@@ -0,0 +1,214 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+func genKey(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode) *gost3410.PrivateKey {
+	prv, err := gost3410.GenPrivateKey(curve, mode, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prv
+}
+
+func createRoot(t *testing.T) (*Certificate, *gost3410.PrivateKey) {
+	prv := genKey(t, gost3410.CurveIdtc26gost34102012512paramSetC(), gost3410.Mode2012)
+	pub, err := prv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Корневой УЦ", Country: []string{"RU"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            -1,
+	}
+	der, err := CreateCertificate(rand.Reader, &template, &template, pub, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, prv
+}
+
+func TestCreateSelfSigned(t *testing.T) {
+	root, _ := createRoot(t)
+	if root.SignatureAlgorithm != GostR34102012WithStreebog512 ||
+		root.Subject.CommonName != "Корневой УЦ" ||
+		!bytes.Equal(root.RawSubject, root.RawIssuer) ||
+		!root.IsCA || root.MaxPathLen != -1 ||
+		root.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign ||
+		len(root.SubjectKeyID) != keyIDSize ||
+		!bytes.Equal(root.SubjectKeyID, root.AuthorityKeyID) ||
+		!root.NotBefore.Equal(now.Add(-time.Hour)) {
+		t.FailNow()
+	}
+	if err := root.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+	if _, err := root.Verify(VerifyOptions{Roots: roots, CurrentTime: now}); err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(root.PEM())
+	if block == nil || block.Type != "CERTIFICATE" || !bytes.Equal(block.Bytes, root.Raw) {
+		t.FailNow()
+	}
+}
+
+func TestCreateFromRequest(t *testing.T) {
+	root, rootPrv := createRoot(t)
+	prv := genKey(t, gost3410.CurveIdGostR34102001CryptoProBParamSet(), gost3410.Mode2001)
+	extra := pkix.Extension{
+		Id:    asn1.ObjectIdentifier{1, 2, 643, 100, 111},
+		Value: []byte{0x0c, 0x03, 'C', 'S', 'P'},
+	}
+	der, err := CreateCertificateRequest(rand.Reader, &CertificateRequest{
+		Subject:         pkix.Name{CommonName: "Иванов", Organization: []string{"ООО Тест"}},
+		KeyUsage:        x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
+		ExtKeyUsage:     []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 2}},
+		ExtraExtensions: []pkix.Extension{extra},
+	}, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = csr.CheckSignature(); err != nil {
+		t.Fatal(err)
+	}
+	if csr.SignatureAlgorithm != GostR34102012WithStreebog256 ||
+		csr.Subject.CommonName != "Иванов" ||
+		csr.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyAgreement ||
+		len(csr.ExtKeyUsage) != 1 || len(csr.Extensions) != 3 ||
+		!csr.Extensions[2].Id.Equal(extra.Id) {
+		t.FailNow()
+	}
+	block, _ := pem.Decode(csr.PEM())
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		t.FailNow()
+	}
+	tampered := *csr
+	tampered.Signature = append([]byte{}, csr.Signature...)
+	tampered.Signature[0] ^= 1
+	if tampered.CheckSignature() == nil {
+		t.FailNow()
+	}
+
+	der, err = CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber:    big.NewInt(2),
+		RawSubject:      csr.RawSubject,
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.Add(time.Hour),
+		KeyUsage:        csr.KeyUsage,
+		ExtKeyUsage:     csr.ExtKeyUsage,
+		ExtraExtensions: []pkix.Extension{extra},
+	}, root, csr.PublicKey, rootPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.SignatureAlgorithm != GostR34102012WithStreebog512 ||
+		leaf.Subject.CommonName != "Иванов" ||
+		leaf.Issuer.CommonName != "Корневой УЦ" ||
+		leaf.BasicConstraintsValid ||
+		!bytes.Equal(leaf.AuthorityKeyID, root.SubjectKeyID) ||
+		leaf.PublicKey.X.Cmp(csr.PublicKey.X) != 0 {
+		t.FailNow()
+	}
+	roots := NewCertPool()
+	roots.AddCert(root)
+	chains, err := leaf.Verify(VerifyOptions{Roots: roots, CurrentTime: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 || len(chains[0]) != 2 {
+		t.FailNow()
+	}
+}
+
+func TestCreateMaxPathLen(t *testing.T) {
+	prv := genKey(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001)
+	pub, _ := prv.PublicKey()
+	for _, c := range []struct {
+		maxPathLen int
+		zero       bool
+		expected   int
+	}{{0, false, -1}, {0, true, 0}, {-1, false, -1}, {2, false, 2}} {
+		template := Certificate{
+			SerialNumber:          big.NewInt(1),
+			NotBefore:             now,
+			NotAfter:              now,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLen:            c.maxPathLen,
+			MaxPathLenZero:        c.zero,
+		}
+		der, err := CreateCertificate(rand.Reader, &template, &template, pub, prv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.MaxPathLen != c.expected {
+			t.FailNow()
+		}
+	}
+}
+
+func TestCreateWrongKey(t *testing.T) {
+	root, _ := createRoot(t)
+	prv := genKey(t, gost3410.CurveIdtc26gost34102012512paramSetC(), gost3410.Mode2012)
+	pub, _ := prv.PublicKey()
+	template := Certificate{SerialNumber: big.NewInt(2), NotBefore: now, NotAfter: now}
+	if _, err := CreateCertificate(rand.Reader, &template, root, pub, prv); err == nil {
+		t.FailNow()
+	}
+	// 512-bit key can not sign with Streebog-256
+	template.SignatureAlgorithm = GostR34102012WithStreebog256
+	if _, err := CreateCertificate(rand.Reader, &template, &template, pub, prv); err == nil {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,277 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+var (
+	oidExtensionCRLNumber  = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+// Revoked certificate entry of the CRL.
+type RevocationEntry struct {
+	SerialNumber   *big.Int
+	RevocationTime time.Time
+	// CRLReason, 0 (unspecified) is not encoded
+	ReasonCode int
+	Extensions []pkix.Extension
+}
+
+// X.509 v2 certificate revocation list.
+type RevocationList struct {
+	Raw                  []byte
+	RawTBSRevocationList []byte
+	RawIssuer            []byte
+
+	Issuer             pkix.Name
+	SignatureAlgorithm SignatureAlgorithm
+	Signature          []byte
+
+	ThisUpdate time.Time
+	// Zero if absent
+	NextUpdate          time.Time
+	RevokedCertificates []RevocationEntry
+
+	Number         *big.Int
+	AuthorityKeyID []byte
+
+	Extensions []pkix.Extension
+	// Additional extensions added by CreateRevocationList
+	ExtraExtensions []pkix.Extension
+}
+
+type revokedCertificate struct {
+	SerialNumber   *big.Int
+	RevocationTime time.Time
+	Extensions     []pkix.Extension `asn1:"optional"`
+}
+
+type tbsCertList struct {
+	Raw                 asn1.RawContent
+	Version             int `asn1:"optional,default:0"`
+	SignatureAlgorithm  pkix.AlgorithmIdentifier
+	Issuer              asn1.RawValue
+	ThisUpdate          time.Time
+	NextUpdate          time.Time            `asn1:"optional"`
+	RevokedCertificates []revokedCertificate `asn1:"optional"`
+	Extensions          []pkix.Extension     `asn1:"tag:0,optional,explicit"`
+}
+
+type certificateList struct {
+	Raw                asn1.RawContent
+	TBSCertList        tbsCertList
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// Parse DER-encoded CRL. Its signature is not checked.
+func ParseRevocationList(der []byte) (*RevocationList, error) {
+	var cl certificateList
+	if err := unmarshal(der, &cl); err != nil {
+		return nil, err
+	}
+	tbs := &cl.TBSCertList
+	if !cl.SignatureAlgorithm.Algorithm.Equal(tbs.SignatureAlgorithm.Algorithm) {
+		return nil, errors.New("signature algorithm mismatch")
+	}
+	rl := RevocationList{
+		Raw:                  cl.Raw,
+		RawTBSRevocationList: tbs.Raw,
+		RawIssuer:            tbs.Issuer.FullBytes,
+		SignatureAlgorithm:   SignatureAlgorithmByOID(cl.SignatureAlgorithm.Algorithm),
+		Signature:            cl.SignatureValue.RightAlign(),
+		ThisUpdate:           tbs.ThisUpdate,
+		NextUpdate:           tbs.NextUpdate,
+		Extensions:           tbs.Extensions,
+	}
+	var err error
+	if rl.Issuer, err = parseName(rl.RawIssuer); err != nil {
+		return nil, err
+	}
+	for _, ext := range rl.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionCRLNumber):
+			if err = unmarshal(ext.Value, &rl.Number); err != nil {
+				return nil, err
+			}
+		case ext.Id.Equal(oidExtensionAuthorityKeyID):
+			var aki authorityKeyID
+			if err = unmarshal(ext.Value, &aki); err != nil {
+				return nil, err
+			}
+			rl.AuthorityKeyID = aki.ID
+		default:
+			if ext.Critical {
+				return nil, errors.New("unhandled critical CRL extension")
+			}
+		}
+	}
+	for _, rc := range tbs.RevokedCertificates {
+		entry := RevocationEntry{
+			SerialNumber:   rc.SerialNumber,
+			RevocationTime: rc.RevocationTime,
+			Extensions:     rc.Extensions,
+		}
+		for _, ext := range rc.Extensions {
+			if !ext.Id.Equal(oidExtensionReasonCode) {
+				continue
+			}
+			var reason asn1.Enumerated
+			if err = unmarshal(ext.Value, &reason); err != nil {
+				return nil, err
+			}
+			entry.ReasonCode = int(reason)
+		}
+		rl.RevokedCertificates = append(rl.RevokedCertificates, entry)
+	}
+	return &rl, nil
+}
+
+// Verify that CRL is signed by the issuer.
+func (rl *RevocationList) CheckSignatureFrom(issuer *Certificate) error {
+	if !bytes.Equal(rl.RawIssuer, issuer.RawSubject) {
+		return errors.New("CRL issuer mismatch")
+	}
+	if len(rl.AuthorityKeyID) > 0 && len(issuer.SubjectKeyID) > 0 &&
+		!bytes.Equal(rl.AuthorityKeyID, issuer.SubjectKeyID) {
+		return errors.New("CRL authority key identifier mismatch")
+	}
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return errors.New("issuer is not allowed to sign CRLs")
+	}
+	return issuer.CheckSignature(rl.SignatureAlgorithm, rl.RawTBSRevocationList, rl.Signature)
+}
+
+// Find revoked certificate's entry by its serial number.
+func (rl *RevocationList) Lookup(serial *big.Int) *RevocationEntry {
+	for i := range rl.RevokedCertificates {
+		if rl.RevokedCertificates[i].SerialNumber.Cmp(serial) == 0 {
+			return &rl.RevokedCertificates[i]
+		}
+	}
+	return nil
+}
+
+type tbsCertListOut struct {
+	Version             int
+	SignatureAlgorithm  pkix.AlgorithmIdentifier
+	Issuer              asn1.RawValue
+	ThisUpdate          time.Time
+	NextUpdate          time.Time            `asn1:"optional"`
+	RevokedCertificates []revokedCertificate `asn1:"optional"`
+	Extensions          []pkix.Extension     `asn1:"tag:0,optional,explicit"`
+}
+
+// Create DER-encoded X.509 v2 CRL signed by issuer's private key. Used
+// template fields: SignatureAlgorithm (chosen by the prv's mode if
+// unknown), ThisUpdate, NextUpdate, RevokedCertificates, Number and
+// ExtraExtensions.
+func CreateRevocationList(
+	rand io.Reader,
+	template *RevocationList,
+	issuer *Certificate,
+	prv *gost3410.PrivateKey,
+) ([]byte, error) {
+	if template.Number == nil || template.Number.Sign() < 0 {
+		return nil, errors.New("invalid CRL number")
+	}
+	signer, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if !samePublicKey(issuer.PublicKey, signer) {
+		return nil, errors.New("private key does not match issuer's public key")
+	}
+	algo := template.SignatureAlgorithm
+	if algo == UnknownSignatureAlgorithm {
+		algo = signatureAlgorithmFor(prv)
+	}
+	issuerName, err := marshalName(issuer.Subject, issuer.RawSubject)
+	if err != nil {
+		return nil, err
+	}
+	var exts []pkix.Extension
+	if len(issuer.SubjectKeyID) > 0 {
+		ext, err := marshalExtension(oidExtensionAuthorityKeyID, false, authorityKeyID{issuer.SubjectKeyID})
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	ext, err := marshalExtension(oidExtensionCRLNumber, false, template.Number)
+	if err != nil {
+		return nil, err
+	}
+	exts = append(exts, ext)
+	exts = append(exts, template.ExtraExtensions...)
+	var revoked []revokedCertificate
+	for _, entry := range template.RevokedCertificates {
+		rc := revokedCertificate{
+			SerialNumber:   entry.SerialNumber,
+			RevocationTime: entry.RevocationTime.UTC(),
+			Extensions:     entry.Extensions,
+		}
+		if entry.ReasonCode != 0 {
+			ext, err := marshalExtension(oidExtensionReasonCode, false, asn1.Enumerated(entry.ReasonCode))
+			if err != nil {
+				return nil, err
+			}
+			rc.Extensions = append([]pkix.Extension{ext}, rc.Extensions...)
+		}
+		revoked = append(revoked, rc)
+	}
+	algoID := pkix.AlgorithmIdentifier{Algorithm: algo.OID()}
+	tbs, err := asn1.Marshal(tbsCertListOut{
+		Version:             1,
+		SignatureAlgorithm:  algoID,
+		Issuer:              issuerName,
+		ThisUpdate:          template.ThisUpdate.UTC(),
+		NextUpdate:          template.NextUpdate.UTC(),
+		RevokedCertificates: revoked,
+		Extensions:          exts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(rand, prv, algo, tbs)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(certificateOut{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: algoID,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+}
+
+// PEM-encoded CRL.
+func (rl *RevocationList) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: rl.Raw})
+}
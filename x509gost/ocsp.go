@@ -0,0 +1,508 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/gost341194"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+var (
+	oidOCSPBasic              = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidExtKeyUsageOCSPSigning = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}
+	oidGost341194             = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 9}
+)
+
+type OCSPResponseStatus int
+
+const (
+	OCSPSuccessful       OCSPResponseStatus = 0
+	OCSPMalformedRequest OCSPResponseStatus = 1
+	OCSPInternalError    OCSPResponseStatus = 2
+	OCSPTryLater         OCSPResponseStatus = 3
+	OCSPSigRequired      OCSPResponseStatus = 5
+	OCSPUnauthorized     OCSPResponseStatus = 6
+)
+
+// Unsuccessful OCSP response.
+type OCSPResponseError struct {
+	Status OCSPResponseStatus
+}
+
+func (e OCSPResponseError) Error() string {
+	return fmt.Sprintf("OCSP response status %d", e.Status)
+}
+
+type OCSPCertStatus int
+
+const (
+	OCSPGood OCSPCertStatus = iota
+	OCSPRevoked
+	OCSPUnknown
+)
+
+// Certificate identifier of OCSP requests and responses.
+type CertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+func certIDHash(oid asn1.ObjectIdentifier) hash.Hash {
+	switch {
+	case oid.Equal(gostasn1.OIDGost34112012256):
+		return gost34112012256.New()
+	case oid.Equal(gostasn1.OIDGost34112012512):
+		return gost34112012512.New()
+	case oid.Equal(oidGost341194):
+		return gost341194.New(&gost28147.SboxIdGostR341194CryptoProParamSet)
+	}
+	return nil
+}
+
+func certIDWithHash(oid asn1.ObjectIdentifier, serial *big.Int, issuer *Certificate) (*CertID, error) {
+	h := certIDHash(oid)
+	if h == nil {
+		return nil, errors.New("unsupported CertID hash algorithm")
+	}
+	name, err := marshalName(issuer.Subject, issuer.RawSubject)
+	if err != nil {
+		return nil, err
+	}
+	spki, err := gostasn1.MarshalPublicKey(issuer.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	id := CertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid},
+		SerialNumber:  serial,
+	}
+	h.Write(name.FullBytes)
+	id.IssuerNameHash = h.Sum(nil)
+	h.Reset()
+	h.Write(spki.SubjectPublicKey.Bytes)
+	id.IssuerKeyHash = h.Sum(nil)
+	return &id, nil
+}
+
+// Identifier of the certificate with given serial issued by the issuer.
+// Streebog-256 is used for hashing.
+func NewCertID(serial *big.Int, issuer *Certificate) (*CertID, error) {
+	return certIDWithHash(gostasn1.OIDGost34112012256, serial, issuer)
+}
+
+// Does identifier refer to the certificate with given serial issued by
+// the issuer. Identifier's hash algorithm is used.
+func (id *CertID) Matches(serial *big.Int, issuer *Certificate) bool {
+	if id.SerialNumber.Cmp(serial) != 0 {
+		return false
+	}
+	other, err := certIDWithHash(id.HashAlgorithm.Algorithm, serial, issuer)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(id.IssuerNameHash, other.IssuerNameHash) &&
+		bytes.Equal(id.IssuerKeyHash, other.IssuerKeyHash)
+}
+
+type ocspRequest struct {
+	Raw        asn1.RawContent
+	TBSRequest tbsRequest
+}
+
+type tbsRequest struct {
+	Version       int              `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName pkix.RDNSequence `asn1:"explicit,tag:1,optional"`
+	RequestList   []singleRequest
+	Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type singleRequest struct {
+	CertID     CertID
+	Extensions []pkix.Extension `asn1:"explicit,tag:0,optional"`
+}
+
+// Unsigned OCSP request.
+type OCSPRequest struct {
+	Raw     []byte
+	CertIDs []CertID
+}
+
+// Create DER-encoded unsigned OCSP request.
+func CreateOCSPRequest(ids []CertID) ([]byte, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no certificates in OCSP request")
+	}
+	var req ocspRequest
+	for _, id := range ids {
+		req.TBSRequest.RequestList = append(req.TBSRequest.RequestList, singleRequest{CertID: id})
+	}
+	return asn1.Marshal(req)
+}
+
+func ParseOCSPRequest(der []byte) (*OCSPRequest, error) {
+	var req ocspRequest
+	if err := unmarshal(der, &req); err != nil {
+		return nil, err
+	}
+	r := OCSPRequest{Raw: req.Raw}
+	for _, sr := range req.TBSRequest.RequestList {
+		r.CertIDs = append(r.CertIDs, sr.CertID)
+	}
+	if len(r.CertIDs) == 0 {
+		return nil, errors.New("no certificates in OCSP request")
+	}
+	return &r, nil
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type basicResponseOut struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw         asn1.RawContent
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []singleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type responseDataOut struct {
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []singleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type singleResponse struct {
+	CertID     CertID
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    revokedInfo      `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// Status of single certificate in OCSP response.
+type OCSPSingleResponse struct {
+	CertID CertID
+	Status OCSPCertStatus
+	// Revoked certificate's revocation time and CRLReason
+	RevocationTime   time.Time
+	RevocationReason int
+	ThisUpdate       time.Time
+	// Zero if absent
+	NextUpdate time.Time
+	Extensions []pkix.Extension
+}
+
+// Basic OCSP response.
+type OCSPResponse struct {
+	Raw             []byte
+	RawResponseData []byte
+
+	ProducedAt time.Time
+	// Either responder's name or key hash is set
+	RawResponderName []byte
+	ResponderKeyHash []byte
+	Responses        []OCSPSingleResponse
+	Extensions       []pkix.Extension
+
+	SignatureAlgorithm SignatureAlgorithm
+	Signature          []byte
+	// Certificates included by the responder
+	Certificates []*Certificate
+}
+
+// Parse DER-encoded OCSP response. Unsuccessful responses are returned as
+// OCSPResponseError. Signature is not checked.
+func ParseOCSPResponse(der []byte) (*OCSPResponse, error) {
+	var resp ocspResponse
+	if err := unmarshal(der, &resp); err != nil {
+		return nil, err
+	}
+	if status := OCSPResponseStatus(resp.Status); status != OCSPSuccessful {
+		return nil, OCSPResponseError{status}
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return nil, errors.New("unsupported OCSP response type")
+	}
+	var basic basicResponse
+	if err := unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, err
+	}
+	data := &basic.TBSResponseData
+	r := OCSPResponse{
+		Raw:                der,
+		RawResponseData:    data.Raw,
+		ProducedAt:         data.ProducedAt,
+		Extensions:         data.Extensions,
+		SignatureAlgorithm: SignatureAlgorithmByOID(basic.SignatureAlgorithm.Algorithm),
+		Signature:          basic.Signature.RightAlign(),
+	}
+	rid := data.ResponderID
+	if rid.Class != asn1.ClassContextSpecific || !rid.IsCompound {
+		return nil, errors.New("invalid responder ID")
+	}
+	switch rid.Tag {
+	case 1:
+		var name asn1.RawValue
+		if err := unmarshal(rid.Bytes, &name); err != nil {
+			return nil, err
+		}
+		r.RawResponderName = name.FullBytes
+	case 2:
+		if err := unmarshal(rid.Bytes, &r.ResponderKeyHash); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("invalid responder ID")
+	}
+	for _, sr := range data.Responses {
+		single := OCSPSingleResponse{
+			CertID:     sr.CertID,
+			ThisUpdate: sr.ThisUpdate,
+			NextUpdate: sr.NextUpdate,
+			Extensions: sr.Extensions,
+		}
+		switch {
+		case bool(sr.Good):
+			single.Status = OCSPGood
+		case bool(sr.Unknown):
+			single.Status = OCSPUnknown
+		default:
+			single.Status = OCSPRevoked
+			single.RevocationTime = sr.Revoked.RevocationTime
+			single.RevocationReason = int(sr.Revoked.Reason)
+		}
+		r.Responses = append(r.Responses, single)
+	}
+	for _, raw := range basic.Certificates {
+		cert, err := ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		r.Certificates = append(r.Certificates, cert)
+	}
+	return &r, nil
+}
+
+func (r *OCSPResponse) signedBy(cert *Certificate) bool {
+	if r.RawResponderName != nil {
+		return bytes.Equal(r.RawResponderName, cert.RawSubject)
+	}
+	spki, err := gostasn1.MarshalPublicKey(cert.PublicKey)
+	if err != nil {
+		return false
+	}
+	// RFC 6960 prescribes SHA-1, but Streebog is also met
+	keyHash := sha1.Sum(spki.SubjectPublicKey.Bytes)
+	if bytes.Equal(r.ResponderKeyHash, keyHash[:]) {
+		return true
+	}
+	h := gost34112012256.New()
+	h.Write(spki.SubjectPublicKey.Bytes)
+	return bytes.Equal(r.ResponderKeyHash, h.Sum(nil))
+}
+
+func hasExtKeyUsage(cert *Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, eku := range cert.ExtKeyUsage {
+		if eku.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify that the response is signed either by the issuer itself or by
+// the delegated responder, whose certificate is included in the response,
+// issued by the issuer, valid now and has OCSPSigning extended key usage.
+func (r *OCSPResponse) CheckSignatureFrom(issuer *Certificate) error {
+	return r.CheckSignatureFromAt(issuer, time.Now())
+}
+
+// Same as CheckSignatureFrom, but delegated responder's certificate
+// validity is checked at the given time.
+func (r *OCSPResponse) CheckSignatureFromAt(issuer *Certificate, now time.Time) error {
+	if r.signedBy(issuer) {
+		return issuer.CheckSignature(r.SignatureAlgorithm, r.RawResponseData, r.Signature)
+	}
+	for _, cert := range r.Certificates {
+		if !r.signedBy(cert) {
+			continue
+		}
+		if !hasExtKeyUsage(cert, oidExtKeyUsageOCSPSigning) {
+			return errors.New("responder certificate lacks OCSPSigning usage")
+		}
+		if !bytes.Equal(cert.RawIssuer, issuer.RawSubject) {
+			return errors.New("responder certificate is not issued by the issuer")
+		}
+		if err := issuer.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+			return err
+		}
+		if err := cert.isValid(now); err != nil {
+			return err
+		}
+		return cert.CheckSignature(r.SignatureAlgorithm, r.RawResponseData, r.Signature)
+	}
+	return errors.New("OCSP responder certificate not found")
+}
+
+// Find status of the certificate with the serial number issued by the
+// issuer: CertID's issuer name and key hashes must match too.
+func (r *OCSPResponse) Lookup(serial *big.Int, issuer *Certificate) *OCSPSingleResponse {
+	for i := range r.Responses {
+		if r.Responses[i].CertID.Matches(serial, issuer) {
+			return &r.Responses[i]
+		}
+	}
+	return nil
+}
+
+// Create successful DER-encoded OCSP response signed by responder's
+// private key. Responder is identified by name. Used template fields:
+// ProducedAt, Responses, Extensions, SignatureAlgorithm (chosen by the
+// prv's mode if unknown) and Certificates to include.
+func CreateOCSPResponse(
+	rand io.Reader,
+	template *OCSPResponse,
+	responder *Certificate,
+	prv *gost3410.PrivateKey,
+) ([]byte, error) {
+	signer, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if !samePublicKey(responder.PublicKey, signer) {
+		return nil, errors.New("private key does not match responder's public key")
+	}
+	algo := template.SignatureAlgorithm
+	if algo == UnknownSignatureAlgorithm {
+		algo = signatureAlgorithmFor(prv)
+	}
+	name, err := marshalName(responder.Subject, responder.RawSubject)
+	if err != nil {
+		return nil, err
+	}
+	data := responseDataOut{
+		ResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1,
+			IsCompound: true,
+			Bytes:      name.FullBytes,
+		},
+		ProducedAt: template.ProducedAt.UTC(),
+		Extensions: template.Extensions,
+	}
+	for _, single := range template.Responses {
+		sr := singleResponse{
+			CertID:     single.CertID,
+			ThisUpdate: single.ThisUpdate.UTC(),
+			NextUpdate: single.NextUpdate.UTC(),
+			Extensions: single.Extensions,
+		}
+		switch single.Status {
+		case OCSPGood:
+			sr.Good = true
+		case OCSPRevoked:
+			sr.Revoked = revokedInfo{
+				RevocationTime: single.RevocationTime.UTC(),
+				Reason:         asn1.Enumerated(single.RevocationReason),
+			}
+		case OCSPUnknown:
+			sr.Unknown = true
+		default:
+			return nil, errors.New("invalid certificate status")
+		}
+		data.Responses = append(data.Responses, sr)
+	}
+	tbs, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(rand, prv, algo, tbs)
+	if err != nil {
+		return nil, err
+	}
+	basic := basicResponseOut{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: algo.OID()},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	}
+	for _, cert := range template.Certificates {
+		basic.Certificates = append(basic.Certificates, asn1.RawValue{FullBytes: cert.Raw})
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspResponse{
+		Status:   asn1.Enumerated(OCSPSuccessful),
+		Response: responseBytes{oidOCSPBasic, basicDER},
+	})
+}
+
+// Create unsuccessful DER-encoded OCSP response.
+func CreateOCSPErrorResponse(status OCSPResponseStatus) ([]byte, error) {
+	if status == OCSPSuccessful {
+		return nil, errors.New("successful response must be signed")
+	}
+	return asn1.Marshal(ocspResponse{Status: asn1.Enumerated(status)})
+}
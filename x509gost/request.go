@@ -0,0 +1,201 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+var oidExtensionRequest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 14}
+
+// PKCS #10 certification request.
+type CertificateRequest struct {
+	Raw                      []byte
+	RawTBSCertificateRequest []byte
+	RawSubjectPublicKeyInfo  []byte
+	RawSubject               []byte
+
+	Version            int
+	Subject            pkix.Name
+	SignatureAlgorithm SignatureAlgorithm
+	Signature          []byte
+	PublicKey          *gost3410.PublicKey
+
+	// Requested extensions
+	Extensions []pkix.Extension
+	// Additional extensions added by CreateCertificateRequest
+	ExtraExtensions []pkix.Extension
+
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []asn1.ObjectIdentifier
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type tbsCertificateRequest struct {
+	Raw        asn1.RawContent
+	Version    int
+	Subject    asn1.RawValue
+	PublicKey  asn1.RawValue
+	Attributes []attribute `asn1:"tag:0,set"`
+}
+
+type tbsCertificateRequestOut struct {
+	Version    int
+	Subject    asn1.RawValue
+	PublicKey  gostasn1.SubjectPublicKeyInfo
+	Attributes []attribute `asn1:"tag:0,set"`
+}
+
+type certificateRequest struct {
+	Raw                asn1.RawContent
+	TBSCSR             tbsCertificateRequest
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+// Create DER-encoded certification request signed by prv. Used template
+// fields: Subject (or RawSubject), SignatureAlgorithm (chosen by the
+// prv's mode if unknown), KeyUsage, ExtKeyUsage and ExtraExtensions.
+func CreateCertificateRequest(rand io.Reader, template *CertificateRequest, prv *gost3410.PrivateKey) ([]byte, error) {
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	algo := template.SignatureAlgorithm
+	if algo == UnknownSignatureAlgorithm {
+		algo = signatureAlgorithmFor(prv)
+	}
+	spki, err := gostasn1.MarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := marshalName(template.Subject, template.RawSubject)
+	if err != nil {
+		return nil, err
+	}
+	exts, err := usageExtensions(template.KeyUsage, template.ExtKeyUsage)
+	if err != nil {
+		return nil, err
+	}
+	exts = append(exts, template.ExtraExtensions...)
+	attrs := []attribute{}
+	if len(exts) > 0 {
+		der, err := asn1.Marshal(exts)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attribute{oidExtensionRequest, asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      der,
+		}})
+	}
+	tbs, err := asn1.Marshal(tbsCertificateRequestOut{
+		Subject:    subject,
+		PublicKey:  spki,
+		Attributes: attrs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(rand, prv, algo, tbs)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(certificateOut{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: algo.OID()},
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+}
+
+// Parse DER-encoded certification request with GOST public key. Its
+// signature is not checked.
+func ParseCertificateRequest(der []byte) (*CertificateRequest, error) {
+	var csr certificateRequest
+	if err := unmarshal(der, &csr); err != nil {
+		return nil, err
+	}
+	tbs := &csr.TBSCSR
+	cr := CertificateRequest{
+		Raw:                      csr.Raw,
+		RawTBSCertificateRequest: tbs.Raw,
+		RawSubjectPublicKeyInfo:  tbs.PublicKey.FullBytes,
+		RawSubject:               tbs.Subject.FullBytes,
+		Version:                  tbs.Version,
+		SignatureAlgorithm:       SignatureAlgorithmByOID(csr.SignatureAlgorithm.Algorithm),
+		Signature:                csr.SignatureValue.RightAlign(),
+	}
+	var err error
+	if cr.Subject, err = parseName(cr.RawSubject); err != nil {
+		return nil, err
+	}
+	var spki gostasn1.SubjectPublicKeyInfo
+	if err = unmarshal(cr.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+	if cr.PublicKey, err = gostasn1.ParsePublicKey(&spki); err != nil {
+		return nil, err
+	}
+	for _, attr := range tbs.Attributes {
+		if !attr.Type.Equal(oidExtensionRequest) {
+			continue
+		}
+		var exts []pkix.Extension
+		if err = unmarshal(attr.Values.Bytes, &exts); err != nil {
+			return nil, err
+		}
+		cr.Extensions = append(cr.Extensions, exts...)
+	}
+	// Reuse certificate's extensions parser for usages
+	var c Certificate
+	for _, ext := range cr.Extensions {
+		if err = c.parseExtension(&ext); err != nil {
+			return nil, err
+		}
+	}
+	cr.KeyUsage = c.KeyUsage
+	cr.ExtKeyUsage = c.ExtKeyUsage
+	return &cr, nil
+}
+
+// Verify request's self-signature.
+func (cr *CertificateRequest) CheckSignature() error {
+	return checkSignature(
+		cr.PublicKey,
+		cr.SignatureAlgorithm,
+		cr.RawTBSCertificateRequest,
+		cr.Signature,
+	)
+}
+
+// PEM-encoded certification request.
+func (cr *CertificateRequest) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: cr.Raw})
+}
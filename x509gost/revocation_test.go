@@ -0,0 +1,314 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+func TestRevocationList(t *testing.T) {
+	root, prv := createRoot(t)
+	revoked := now.Add(-time.Minute).Truncate(time.Second)
+	der, err := CreateRevocationList(rand.Reader, &RevocationList{
+		ThisUpdate: now,
+		NextUpdate: now.Add(24 * time.Hour),
+		Number:     big.NewInt(7),
+		RevokedCertificates: []RevocationEntry{
+			{SerialNumber: big.NewInt(10), RevocationTime: revoked, ReasonCode: 1},
+			{SerialNumber: big.NewInt(11), RevocationTime: revoked},
+		},
+	}, root, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = crl.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+	if crl.SignatureAlgorithm != GostR34102012WithStreebog512 ||
+		crl.Issuer.CommonName != "Корневой УЦ" ||
+		crl.Number.Cmp(big.NewInt(7)) != 0 ||
+		!bytes.Equal(crl.AuthorityKeyID, root.SubjectKeyID) ||
+		!crl.ThisUpdate.Equal(now) ||
+		!crl.NextUpdate.Equal(now.Add(24*time.Hour)) ||
+		len(crl.RevokedCertificates) != 2 {
+		t.FailNow()
+	}
+	entry := crl.Lookup(big.NewInt(10))
+	if entry == nil || entry.ReasonCode != 1 || !entry.RevocationTime.Equal(revoked) {
+		t.FailNow()
+	}
+	if entry = crl.Lookup(big.NewInt(11)); entry == nil || entry.ReasonCode != 0 {
+		t.FailNow()
+	}
+	if crl.Lookup(big.NewInt(12)) != nil {
+		t.FailNow()
+	}
+	block, _ := pem.Decode(crl.PEM())
+	if block == nil || block.Type != "X509 CRL" || !bytes.Equal(block.Bytes, der) {
+		t.FailNow()
+	}
+
+	other, otherPrv := createRoot(t)
+	if crl.CheckSignatureFrom(other) == nil {
+		t.FailNow()
+	}
+	if _, err = CreateRevocationList(rand.Reader, &RevocationList{
+		Number: big.NewInt(1),
+	}, root, otherPrv); err == nil {
+		t.FailNow()
+	}
+	if _, err = CreateRevocationList(rand.Reader, &RevocationList{}, root, prv); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestRevocationListNoCRLSign(t *testing.T) {
+	prv := genKey(t, gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001)
+	pub, _ := prv.PublicKey()
+	template := Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    now,
+		NotAfter:     now,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := CreateCertificate(rand.Reader, &template, &template, pub, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = CreateRevocationList(rand.Reader, &RevocationList{
+		ThisUpdate: now,
+		Number:     big.NewInt(1),
+	}, cert, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl.SignatureAlgorithm != GostR34102012WithStreebog256 ||
+		!crl.NextUpdate.IsZero() || len(crl.RevokedCertificates) != 0 {
+		t.FailNow()
+	}
+	if crl.CheckSignatureFrom(cert) == nil {
+		t.FailNow()
+	}
+}
+
+func createResponder(t *testing.T, root *Certificate, rootPrv *gost3410.PrivateKey) (*Certificate, *gost3410.PrivateKey) {
+	prv := genKey(t, gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001)
+	pub, _ := prv.PublicKey()
+	der, err := CreateCertificate(rand.Reader, &Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "OCSP"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []asn1.ObjectIdentifier{oidExtKeyUsageOCSPSigning},
+	}, root, pub, rootPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, prv
+}
+
+func TestOCSP(t *testing.T) {
+	root, rootPrv := createRoot(t)
+	good, err := NewCertID(big.NewInt(10), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := NewCertID(big.NewInt(11), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := CreateOCSPRequest([]CertID{*good, *revoked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := ParseOCSPRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.CertIDs) != 2 ||
+		!req.CertIDs[0].Matches(big.NewInt(10), root) ||
+		!req.CertIDs[1].Matches(big.NewInt(11), root) ||
+		req.CertIDs[1].Matches(big.NewInt(10), root) {
+		t.FailNow()
+	}
+	other, otherPrv := createRoot(t)
+	if req.CertIDs[0].Matches(big.NewInt(10), other) {
+		t.FailNow()
+	}
+
+	revocationTime := now.Add(-time.Minute)
+	template := OCSPResponse{
+		ProducedAt: now,
+		Responses: []OCSPSingleResponse{
+			{CertID: req.CertIDs[0], Status: OCSPGood, ThisUpdate: now},
+			{
+				CertID:           req.CertIDs[1],
+				Status:           OCSPRevoked,
+				RevocationTime:   revocationTime,
+				RevocationReason: 4,
+				ThisUpdate:       now,
+				NextUpdate:       now.Add(time.Hour),
+			},
+		},
+	}
+	der, err = CreateOCSPResponse(rand.Reader, &template, root, rootPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ParseOCSPResponse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = resp.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+	if resp.CheckSignatureFrom(other) == nil {
+		t.FailNow()
+	}
+	if !bytes.Equal(resp.RawResponderName, root.RawSubject) ||
+		!resp.ProducedAt.Equal(now) || len(resp.Responses) != 2 {
+		t.FailNow()
+	}
+	single := resp.Lookup(big.NewInt(10), root)
+	if single == nil || single.Status != OCSPGood || !single.NextUpdate.IsZero() {
+		t.FailNow()
+	}
+	single = resp.Lookup(big.NewInt(11), root)
+	if single == nil || single.Status != OCSPRevoked ||
+		single.RevocationReason != 4 ||
+		!single.RevocationTime.Equal(revocationTime) ||
+		!single.NextUpdate.Equal(now.Add(time.Hour)) ||
+		!single.CertID.Matches(big.NewInt(11), root) {
+		t.FailNow()
+	}
+	if resp.Lookup(big.NewInt(12), root) != nil {
+		t.FailNow()
+	}
+	// Same serial from another issuer
+	if resp.Lookup(big.NewInt(10), other) != nil {
+		t.FailNow()
+	}
+	if _, err = CreateOCSPResponse(rand.Reader, &template, root, otherPrv); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestOCSPDelegatedResponder(t *testing.T) {
+	root, rootPrv := createRoot(t)
+	responder, prv := createResponder(t, root, rootPrv)
+	id, err := NewCertID(big.NewInt(10), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := OCSPResponse{
+		ProducedAt:   now,
+		Responses:    []OCSPSingleResponse{{CertID: *id, Status: OCSPUnknown, ThisUpdate: now}},
+		Certificates: []*Certificate{responder},
+	}
+	der, err := CreateOCSPResponse(rand.Reader, &template, responder, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ParseOCSPResponse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.SignatureAlgorithm != GostR34102012WithStreebog256 ||
+		len(resp.Certificates) != 1 ||
+		resp.Responses[0].Status != OCSPUnknown {
+		t.FailNow()
+	}
+	if err = resp.CheckSignatureFromAt(root, now); err != nil {
+		t.Fatal(err)
+	}
+	// Responder certificate has expired
+	if resp.CheckSignatureFromAt(root, now.Add(2*time.Hour)) == nil {
+		t.FailNow()
+	}
+	if resp.CheckSignatureFrom(root) == nil {
+		t.FailNow()
+	}
+
+	// Responder certificate is not included
+	template.Certificates = nil
+	der, err = CreateOCSPResponse(rand.Reader, &template, responder, prv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = ParseOCSPResponse(der); err != nil {
+		t.Fatal(err)
+	}
+	if resp.CheckSignatureFromAt(root, now) == nil {
+		t.FailNow()
+	}
+
+	// Responder certificate issued by another CA
+	other, otherPrv := createRoot(t)
+	foreign, foreignPrv := createResponder(t, other, otherPrv)
+	template.Certificates = []*Certificate{foreign}
+	der, err = CreateOCSPResponse(rand.Reader, &template, foreign, foreignPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = ParseOCSPResponse(der); err != nil {
+		t.Fatal(err)
+	}
+	if resp.CheckSignatureFromAt(root, now) == nil {
+		t.FailNow()
+	}
+}
+
+func TestOCSPErrorResponse(t *testing.T) {
+	der, err := CreateOCSPErrorResponse(OCSPTryLater)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseOCSPResponse(der)
+	if e, ok := err.(OCSPResponseError); !ok || e.Status != OCSPTryLater {
+		t.FailNow()
+	}
+	if _, err = CreateOCSPErrorResponse(OCSPSuccessful); err == nil {
+		t.FailNow()
+	}
+}
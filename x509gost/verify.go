@@ -0,0 +1,197 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+const maxChainLength = 10
+
+var ErrUnknownAuthority = errors.New("certificate signed by unknown authority")
+
+// Set of certificates indexed by subject.
+type CertPool struct {
+	bySubject map[string][]*Certificate
+}
+
+func NewCertPool() *CertPool {
+	return &CertPool{bySubject: make(map[string][]*Certificate)}
+}
+
+func (p *CertPool) AddCert(cert *Certificate) {
+	if p.Contains(cert) {
+		return
+	}
+	subject := string(cert.RawSubject)
+	p.bySubject[subject] = append(p.bySubject[subject], cert)
+}
+
+// Parse and add DER-encoded certificate.
+func (p *CertPool) AddCertDER(der []byte) error {
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	p.AddCert(cert)
+	return nil
+}
+
+// Copy of the pool, empty one if p is nil.
+func (p *CertPool) Clone() *CertPool {
+	clone := NewCertPool()
+	if p == nil {
+		return clone
+	}
+	for subject, certs := range p.bySubject {
+		clone.bySubject[subject] = append([]*Certificate{}, certs...)
+	}
+	return clone
+}
+
+func (p *CertPool) Contains(cert *Certificate) bool {
+	if p == nil {
+		return false
+	}
+	for _, c := range p.bySubject[string(cert.RawSubject)] {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// Possible issuers of the certificate: subject must be equal to the
+// issuer and key identifiers must match if both present.
+func (p *CertPool) findParents(cert *Certificate) []*Certificate {
+	if p == nil {
+		return nil
+	}
+	var parents []*Certificate
+	for _, c := range p.bySubject[string(cert.RawIssuer)] {
+		if len(cert.AuthorityKeyID) > 0 && len(c.SubjectKeyID) > 0 &&
+			!bytes.Equal(cert.AuthorityKeyID, c.SubjectKeyID) {
+			continue
+		}
+		parents = append(parents, c)
+	}
+	return parents
+}
+
+type VerifyOptions struct {
+	Roots         *CertPool
+	Intermediates *CertPool
+	// Time to check validity periods against, current time if zero
+	CurrentTime time.Time
+}
+
+func (c *Certificate) isValid(now time.Time) error {
+	if now.Before(c.NotBefore) {
+		return errors.New("certificate is not valid yet")
+	}
+	if now.After(c.NotAfter) {
+		return errors.New("certificate has expired")
+	}
+	if len(c.UnhandledCriticalExtensions) > 0 {
+		return errors.New("unhandled critical extension")
+	}
+	return nil
+}
+
+// Build and verify chains from the certificate up to the one of roots.
+// Each returned chain starts with the certificate itself and ends with
+// the root.
+func (c *Certificate) Verify(opts VerifyOptions) ([][]*Certificate, error) {
+	if opts.Roots == nil {
+		return nil, errors.New("no root certificates")
+	}
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if err := c.isValid(now); err != nil {
+		return nil, err
+	}
+	if opts.Roots.Contains(c) {
+		return [][]*Certificate{{c}}, nil
+	}
+	return c.buildChains([]*Certificate{c}, &opts, now)
+}
+
+func inChain(chain []*Certificate, cert *Certificate) bool {
+	for _, c := range chain {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Certificate) checkParent(parent *Certificate, chain []*Certificate, now time.Time) error {
+	if err := parent.isValid(now); err != nil {
+		return err
+	}
+	// Number of intermediate CAs below the parent
+	if parent.MaxPathLen >= 0 && len(chain)-1 > parent.MaxPathLen {
+		return errors.New("path length constraint violated")
+	}
+	return c.CheckSignatureFrom(parent)
+}
+
+func (c *Certificate) buildChains(chain []*Certificate, opts *VerifyOptions, now time.Time) ([][]*Certificate, error) {
+	var chains [][]*Certificate
+	var lastErr error
+	extend := func(parent *Certificate) []*Certificate {
+		return append(append([]*Certificate{}, chain...), parent)
+	}
+	for _, root := range opts.Roots.findParents(c) {
+		if inChain(chain, root) {
+			continue
+		}
+		if err := c.checkParent(root, chain, now); err != nil {
+			lastErr = err
+			continue
+		}
+		chains = append(chains, extend(root))
+	}
+	if len(chain) < maxChainLength {
+		for _, inter := range opts.Intermediates.findParents(c) {
+			if inChain(chain, inter) || opts.Roots.Contains(inter) {
+				continue
+			}
+			if err := c.checkParent(inter, chain, now); err != nil {
+				lastErr = err
+				continue
+			}
+			found, err := inter.buildChains(extend(inter), opts, now)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			chains = append(chains, found...)
+		}
+	}
+	if len(chains) == 0 {
+		if lastErr == nil {
+			lastErr = ErrUnknownAuthority
+		}
+		return nil, lastErr
+	}
+	return chains, nil
+}
@@ -0,0 +1,360 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// X.509 certificates with GOST R 34.10-2001/2012 keys and signatures
+// (RFC 4491, RFC 9215) parsing and verification.
+//
+// Certificate's signatureValue is s||r, both big-endian. Digest of the
+// TBSCertificate is interpreted as little-endian number, so it is
+// reversed before passing to gost3410 functions.
+package x509gost
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"math/big"
+	"time"
+
+	"github.com/ddulesov/gogost/gost28147"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/ddulesov/gogost/gost341194"
+	"github.com/ddulesov/gogost/internal/gostasn1"
+)
+
+type SignatureAlgorithm int
+
+const (
+	UnknownSignatureAlgorithm SignatureAlgorithm = iota
+	GostR34102001WithGostR341194
+	GostR34102012WithStreebog256
+	GostR34102012WithStreebog512
+)
+
+var (
+	oidExtensionSubjectKeyID          = asn1.ObjectIdentifier{2, 5, 29, 14}
+	oidExtensionKeyUsage              = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionBasicConstraints      = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtensionAuthorityKeyID        = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtensionExtendedKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtensionCertificatePolicies   = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidExtensionSubjectAltName        = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionCRLDistributionPoints = asn1.ObjectIdentifier{2, 5, 29, 31}
+
+	signatureAlgorithms = []struct {
+		algo SignatureAlgorithm
+		oid  asn1.ObjectIdentifier
+		name string
+	}{
+		{GostR34102001WithGostR341194, gostasn1.OIDGost341194WithGost34102001, "GOST R 34.10-2001 with GOST R 34.11-94"},
+		{GostR34102012WithStreebog256, gostasn1.OIDSignWithDigestGost34102012256, "GOST R 34.10-2012 with Streebog-256"},
+		{GostR34102012WithStreebog512, gostasn1.OIDSignWithDigestGost34102012512, "GOST R 34.10-2012 with Streebog-512"},
+	}
+)
+
+func (algo SignatureAlgorithm) String() string {
+	for _, a := range signatureAlgorithms {
+		if a.algo == algo {
+			return a.name
+		}
+	}
+	return "unknown"
+}
+
+// Object identifier of the signature algorithm.
+func (algo SignatureAlgorithm) OID() asn1.ObjectIdentifier {
+	for _, a := range signatureAlgorithms {
+		if a.algo == algo {
+			return a.oid
+		}
+	}
+	return nil
+}
+
+// Signature algorithm by its object identifier.
+func SignatureAlgorithmByOID(oid asn1.ObjectIdentifier) SignatureAlgorithm {
+	for _, a := range signatureAlgorithms {
+		if a.oid.Equal(oid) {
+			return a.algo
+		}
+	}
+	return UnknownSignatureAlgorithm
+}
+
+// Hash function used with the signature algorithm.
+func (algo SignatureAlgorithm) Hash() hash.Hash {
+	switch algo {
+	case GostR34102001WithGostR341194:
+		return gost341194.New(&gost28147.SboxIdGostR341194CryptoProParamSet)
+	case GostR34102012WithStreebog256:
+		return gost34112012256.New()
+	case GostR34102012WithStreebog512:
+		return gost34112012512.New()
+	}
+	return nil
+}
+
+// Digest of the data ready for gost3410's SignDigest/VerifyDigest: it is
+// reversed to big-endian form.
+func (algo SignatureAlgorithm) Digest(data []byte) []byte {
+	h := algo.Hash()
+	if h == nil {
+		return nil
+	}
+	h.Write(data)
+	digest := h.Sum(nil)
+	for i, j := 0, len(digest)-1; i < j; i, j = i+1, j-1 {
+		digest[i], digest[j] = digest[j], digest[i]
+	}
+	return digest
+}
+
+// Is the key suitable for the algorithm: 34.10-2012-512 keys must be
+// used with Streebog-512 and 256-bit ones with other algorithms.
+func (algo SignatureAlgorithm) suits(mode gost3410.Mode) bool {
+	switch algo {
+	case GostR34102001WithGostR341194, GostR34102012WithStreebog256:
+		return mode == gost3410.Mode2001
+	case GostR34102012WithStreebog512:
+		return mode == gost3410.Mode2012
+	}
+	return false
+}
+
+type Certificate struct {
+	Raw                     []byte
+	RawTBSCertificate       []byte
+	RawSubjectPublicKeyInfo []byte
+	RawSubject              []byte
+	RawIssuer               []byte
+
+	Version            int
+	SerialNumber       *big.Int
+	Issuer             pkix.Name
+	Subject            pkix.Name
+	NotBefore          time.Time
+	NotAfter           time.Time
+	SignatureAlgorithm SignatureAlgorithm
+	Signature          []byte
+	PublicKey          *gost3410.PublicKey
+
+	Extensions []pkix.Extension
+	// Additional extensions added by CreateCertificate
+	ExtraExtensions []pkix.Extension
+	// Critical extensions that are not parsed by this package
+	UnhandledCriticalExtensions []asn1.ObjectIdentifier
+
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []asn1.ObjectIdentifier
+
+	BasicConstraintsValid bool
+	IsCA                  bool
+	// -1 if not set. Zero value is treated as unset by CreateCertificate
+	// unless MaxPathLenZero is true
+	MaxPathLen     int
+	MaxPathLenZero bool
+
+	SubjectKeyID   []byte
+	AuthorityKeyID []byte
+}
+
+type certificate struct {
+	Raw                asn1.RawContent
+	TBSCertificate     tbsCertificate
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           validity
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	IssuerUniqueID     asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+type validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+type basicConstraints struct {
+	IsCA       bool `asn1:"optional"`
+	MaxPathLen int  `asn1:"optional,default:-1"`
+}
+
+type authorityKeyID struct {
+	ID []byte `asn1:"optional,tag:0"`
+}
+
+func unmarshal(der []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+func parseName(der []byte) (pkix.Name, error) {
+	var rdns pkix.RDNSequence
+	var name pkix.Name
+	if err := unmarshal(der, &rdns); err != nil {
+		return name, err
+	}
+	name.FillFromRDNSequence(&rdns)
+	return name, nil
+}
+
+// Parse single DER-encoded certificate with GOST public key.
+func ParseCertificate(der []byte) (*Certificate, error) {
+	var cert certificate
+	if err := unmarshal(der, &cert); err != nil {
+		return nil, err
+	}
+	tbs := &cert.TBSCertificate
+	if !cert.SignatureAlgorithm.Algorithm.Equal(tbs.SignatureAlgorithm.Algorithm) {
+		return nil, errors.New("signature algorithm mismatch")
+	}
+	c := Certificate{
+		Raw:                     cert.Raw,
+		RawTBSCertificate:       tbs.Raw,
+		RawSubjectPublicKeyInfo: tbs.PublicKey.FullBytes,
+		RawSubject:              tbs.Subject.FullBytes,
+		RawIssuer:               tbs.Issuer.FullBytes,
+		Version:                 tbs.Version + 1,
+		SerialNumber:            tbs.SerialNumber,
+		NotBefore:               tbs.Validity.NotBefore,
+		NotAfter:                tbs.Validity.NotAfter,
+		SignatureAlgorithm:      SignatureAlgorithmByOID(cert.SignatureAlgorithm.Algorithm),
+		Signature:               cert.SignatureValue.RightAlign(),
+		Extensions:              tbs.Extensions,
+		MaxPathLen:              -1,
+	}
+	var err error
+	if c.Issuer, err = parseName(c.RawIssuer); err != nil {
+		return nil, err
+	}
+	if c.Subject, err = parseName(c.RawSubject); err != nil {
+		return nil, err
+	}
+	var spki gostasn1.SubjectPublicKeyInfo
+	if err = unmarshal(c.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+	if c.PublicKey, err = gostasn1.ParsePublicKey(&spki); err != nil {
+		return nil, err
+	}
+	for _, ext := range c.Extensions {
+		if err = c.parseExtension(&ext); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+func (c *Certificate) parseExtension(ext *pkix.Extension) error {
+	switch {
+	case ext.Id.Equal(oidExtensionKeyUsage):
+		var bs asn1.BitString
+		if err := unmarshal(ext.Value, &bs); err != nil {
+			return err
+		}
+		for i := 0; i < 9; i++ {
+			if bs.At(i) != 0 {
+				c.KeyUsage |= 1 << uint(i)
+			}
+		}
+	case ext.Id.Equal(oidExtensionExtendedKeyUsage):
+		if err := unmarshal(ext.Value, &c.ExtKeyUsage); err != nil {
+			return err
+		}
+	case ext.Id.Equal(oidExtensionBasicConstraints):
+		var bc basicConstraints
+		if err := unmarshal(ext.Value, &bc); err != nil {
+			return err
+		}
+		c.BasicConstraintsValid = true
+		c.IsCA = bc.IsCA
+		c.MaxPathLen = bc.MaxPathLen
+		c.MaxPathLenZero = bc.MaxPathLen == 0
+	case ext.Id.Equal(oidExtensionSubjectKeyID):
+		if err := unmarshal(ext.Value, &c.SubjectKeyID); err != nil {
+			return err
+		}
+	case ext.Id.Equal(oidExtensionAuthorityKeyID):
+		var aki authorityKeyID
+		if err := unmarshal(ext.Value, &aki); err != nil {
+			return err
+		}
+		c.AuthorityKeyID = aki.ID
+	case ext.Id.Equal(oidExtensionCertificatePolicies),
+		ext.Id.Equal(oidExtensionSubjectAltName),
+		ext.Id.Equal(oidExtensionCRLDistributionPoints):
+		// Not interpreted, but do not prevent verification
+	default:
+		if ext.Critical {
+			c.UnhandledCriticalExtensions = append(c.UnhandledCriticalExtensions, ext.Id)
+		}
+	}
+	return nil
+}
+
+func checkSignature(pub *gost3410.PublicKey, algo SignatureAlgorithm, signed, signature []byte) error {
+	if !algo.suits(pub.Mode) {
+		return errors.New("signature algorithm does not suit the public key")
+	}
+	valid, err := pub.VerifyDigest(algo.Digest(signed), signature)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Verify signature over signed data made by certificate's public key.
+func (c *Certificate) CheckSignature(algo SignatureAlgorithm, signed, signature []byte) error {
+	return checkSignature(c.PublicKey, algo, signed, signature)
+}
+
+// Verify that the certificate's signature is made by the parent.
+func (c *Certificate) CheckSignatureFrom(parent *Certificate) error {
+	if parent.Version == 3 && !(parent.BasicConstraintsValid && parent.IsCA) {
+		return errors.New("parent certificate is not a CA")
+	}
+	if parent.KeyUsage != 0 && parent.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("parent certificate is not allowed to sign certificates")
+	}
+	return parent.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature)
+}
+
+func (c *Certificate) Equal(other *Certificate) bool {
+	return bytes.Equal(c.Raw, other.Raw)
+}
@@ -0,0 +1,169 @@
+// GoGOST -- Pure Go GOST cryptographic functions library
+// Copyright (C) 2015-2019 Sergey Matveev <stargrave@stargrave.org>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package x509gost
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ddulesov/gogost/gost3410"
+)
+
+// Certificates in testdata are valid from 2020-01-01 till 2049-12-31,
+// except for expired.pem that is valid for only one year.
+var now = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func load(t *testing.T, name string) *Certificate {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	cert, err := ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestParse(t *testing.T) {
+	root := load(t, "root")
+	if root.Version != 3 ||
+		root.SignatureAlgorithm != GostR34102012WithStreebog512 ||
+		root.PublicKey.Mode != gost3410.Mode2012 ||
+		root.PublicKey.C.Name != gost3410.CurveIdtc26gost341012512paramSetA().Name ||
+		!root.BasicConstraintsValid || !root.IsCA || root.MaxPathLen != -1 ||
+		root.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign ||
+		root.Subject.CommonName != "Тестовый корневой УЦ" ||
+		root.Subject.String() != root.Issuer.String() ||
+		len(root.SubjectKeyID) != 20 {
+		t.FailNow()
+	}
+	inter := load(t, "intermediate")
+	if inter.MaxPathLen != 0 ||
+		inter.SignatureAlgorithm != GostR34102012WithStreebog512 ||
+		string(inter.AuthorityKeyID) != string(root.SubjectKeyID) {
+		t.FailNow()
+	}
+	leaf := load(t, "leaf")
+	if leaf.IsCA || len(leaf.ExtKeyUsage) != 2 ||
+		leaf.SignatureAlgorithm != GostR34102012WithStreebog256 ||
+		leaf.PublicKey.C.Name != gost3410.CurveIdGostR34102001CryptoProAParamSet().Name ||
+		leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 ||
+		leaf.Subject.Country[0] != "RU" {
+		t.FailNow()
+	}
+	if len(load(t, "critical").UnhandledCriticalExtensions) != 1 {
+		t.FailNow()
+	}
+}
+
+func TestCheckSignature(t *testing.T) {
+	root := load(t, "root")
+	inter := load(t, "intermediate")
+	leaf := load(t, "leaf")
+	if err := root.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := inter.CheckSignatureFrom(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.CheckSignatureFrom(inter); err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.CheckSignatureFrom(root); err == nil {
+		t.FailNow()
+	}
+	// Leaf is not CA
+	if err := inter.CheckSignatureFrom(leaf); err == nil {
+		t.FailNow()
+	}
+	root2001 := load(t, "root2001")
+	if root2001.SignatureAlgorithm != GostR34102001WithGostR341194 {
+		t.FailNow()
+	}
+	if err := load(t, "leaf2001").CheckSignatureFrom(root2001); err != nil {
+		t.Fatal(err)
+	}
+	tampered := *leaf
+	tampered.RawTBSCertificate = append([]byte{}, leaf.RawTBSCertificate...)
+	tampered.RawTBSCertificate[len(tampered.RawTBSCertificate)-1] ^= 1
+	if err := tampered.CheckSignatureFrom(inter); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestVerify(t *testing.T) {
+	roots := NewCertPool()
+	roots.AddCert(load(t, "root"))
+	roots.AddCert(load(t, "root2001"))
+	inters := NewCertPool()
+	inters.AddCert(load(t, "intermediate"))
+	inters.AddCert(load(t, "subca"))
+	opts := VerifyOptions{Roots: roots, Intermediates: inters, CurrentTime: now}
+
+	chains, err := load(t, "leaf").Verify(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 1 || len(chains[0]) != 3 ||
+		chains[0][1].Subject.CommonName != "Тестовый подчинённый УЦ" ||
+		!chains[0][2].Equal(load(t, "root")) {
+		t.FailNow()
+	}
+	if chains, err = load(t, "leaf2001").Verify(opts); err != nil || len(chains[0]) != 2 {
+		t.Fatal(err)
+	}
+	if chains, err = load(t, "root").Verify(opts); err != nil || len(chains[0]) != 1 {
+		t.Fatal(err)
+	}
+
+	// No intermediate
+	if _, err = load(t, "leaf").Verify(VerifyOptions{Roots: roots, CurrentTime: now}); err != ErrUnknownAuthority {
+		t.Fatal(err)
+	}
+	// Unknown root
+	other := NewCertPool()
+	other.AddCert(load(t, "root2001"))
+	if _, err = load(t, "leaf").Verify(VerifyOptions{
+		Roots: other, Intermediates: inters, CurrentTime: now,
+	}); err == nil {
+		t.FailNow()
+	}
+	if _, err = load(t, "expired").Verify(opts); err == nil {
+		t.FailNow()
+	}
+	if _, err = load(t, "leaf").Verify(VerifyOptions{
+		Roots: roots, Intermediates: inters, CurrentTime: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+	}); err == nil {
+		t.FailNow()
+	}
+	if _, err = load(t, "critical").Verify(opts); err == nil {
+		t.FailNow()
+	}
+	// Intermediate has zero path length
+	if _, err = load(t, "subleaf").Verify(opts); err == nil {
+		t.FailNow()
+	}
+}